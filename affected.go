@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// TestsSelection tests selected by strategy
+type TestsSelection struct {
+	RunAll   bool     `json:"run_all"`
+	Tests    []string `json:"tests"`
+	SubTests []string `json:"subtests"`
+	Packages []string `json:"packages"`
+	// Run is -run arg to go test
+	Run string `json:"run"`
+	// Explanations recorded if strategy explains selection
+	Explanations []Explanation `json:"explanations,omitempty"`
+	// Sources are strategies selected test in hybrid strategy
+	Sources map[string][]string `json:"sources,omitempty"`
+	// SubTestSources are strategies selected subtest in hybrid strategy
+	SubTestSources map[string][]string `json:"subtest_sources,omitempty"`
+}
+
+// affected prints tests affected by changes
+// without running them and returns exit code
+func affected(ctx context.Context, cfg config, logger *log.Logger) int {
+	gitCmd, err := newGitCMD(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "git error %v\n", err)
+		return exitError
+	}
+	// strategies log to stdout, keep it for selection output
+	logger.SetOutput(os.Stderr)
+	sel, err := selectTests(ctx, newStrategy(cfg, gitCmd, logger))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "select tests error %v\n", err)
+		return exitError
+	}
+	err = printSelection(os.Stdout, sel, cfg.format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "print selection error %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// selectTests returns tests selected by strategy
+func selectTests(ctx context.Context, strategy Strategy) (*TestsSelection, error) {
+	runAll, tests, subTests, err := strategy.TestsToRun(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sel := &TestsSelection{
+		RunAll:   runAll,
+		Tests:    append([]string{}, tests...),
+		SubTests: append([]string{}, subTests...),
+		Packages: []string{},
+	}
+	sort.Strings(sel.Tests)
+	sort.Strings(sel.SubTests)
+	var testNames []string
+	for pkg, pkgTests := range testsByPkg(tests) {
+		sel.Packages = append(sel.Packages, pkg)
+		testNames = append(testNames, pkgTests...)
+	}
+	sort.Strings(sel.Packages)
+	// joinTestAndSubtest modifies subtests
+	sel.Run = (&GoTestRunner{}).joinTestAndSubtest(testNames,
+		append([]string{}, subTests...))
+	if ex, ok := strategy.(Explainer); ok {
+		sel.Explanations = ex.Explanations()
+	}
+	if hs, ok := strategy.(*HybridStrategy); ok {
+		sel.Sources = hs.Sources()
+		sel.SubTestSources = hs.SubTestSources()
+	}
+	return sel, nil
+}
+
+// printSelection writes selection in text or json format
+func printSelection(out io.Writer, sel *TestsSelection, format string) error {
+	if format == "json" {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(sel)
+	}
+	printList := func(title string, list []string, sources map[string][]string) {
+		fmt.Fprintln(out, title)
+		for i := range list {
+			if src := sources[list[i]]; len(src) > 0 {
+				fmt.Fprintf(out, "  %s (%s)\n", list[i], strings.Join(src, ", "))
+				continue
+			}
+			fmt.Fprintf(out, "  %s\n", list[i])
+		}
+	}
+	printList("Tests:", sel.Tests, sel.Sources)
+	printList("Subtests:", sel.SubTests, sel.SubTestSources)
+	printList("Packages:", sel.Packages, nil)
+	if len(sel.Explanations) > 0 {
+		fmt.Fprintln(out, "Explanations:")
+		for i := range sel.Explanations {
+			fmt.Fprintf(out, "  %s\n", sel.Explanations[i])
+		}
+	}
+	_, err := fmt.Fprintf(out, "Run: %s\n", sel.Run)
+	return err
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestPrintSelection(t *testing.T) {
+	ds := &dummyStrategy{
+		runAll:   true,
+		tests:    []string{"module/pkga.TestB", "module.TestZ", "module/pkga.TestA"},
+		subtests: []string{"group test 1", "max"},
+	}
+	sel, err := selectTests(context.Background(), ds)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	cases := []struct {
+		format string
+		output string
+	}{
+		{format: "text", output: `Tests:
+  module.TestZ
+  module/pkga.TestA
+  module/pkga.TestB
+Subtests:
+  group test 1
+  max
+Packages:
+  module
+  module/pkga
+Run: TestA$|TestB$|TestZ$/(group_test_1|max)
+`},
+		{format: "json", output: `{
+  "run_all": true,
+  "tests": [
+    "module.TestZ",
+    "module/pkga.TestA",
+    "module/pkga.TestB"
+  ],
+  "subtests": [
+    "group test 1",
+    "max"
+  ],
+  "packages": [
+    "module",
+    "module/pkga"
+  ],
+  "run": "TestA$|TestB$|TestZ$/(group_test_1|max)"
+}
+`},
+	}
+	for i, tc := range cases {
+		var out bytes.Buffer
+		err := printSelection(&out, sel, tc.format)
+		if isUnexpectedErr(t, i, tc.format, nil, err) {
+			continue
+		}
+		if out.String() != tc.output {
+			t.Errorf("case [%d] %s\nexpected %s\ngot %s", i, tc.format, tc.output, out.String())
+		}
+	}
+	// hybrid strategy sources
+	sel.Sources = map[string][]string{"module.TestZ": {"coverage", "analysis"}}
+	sel.SubTestSources = map[string][]string{"group test 1": {"analysis"}}
+	var out bytes.Buffer
+	_ = printSelection(&out, sel, "text")
+	if !strings.Contains(out.String(), "  module.TestZ (coverage, analysis)\n") ||
+		!strings.Contains(out.String(), "  group test 1 (analysis)\n") {
+		t.Errorf("expected test sources, got %s", out.String())
+	}
+	// strategy results are not modified
+	if ds.subtests[0] != "group test 1" {
+		t.Errorf("expected subtests not modified, got %v", ds.subtests)
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var (
+	posType     = reflect.TypeOf(token.NoPos)
+	objectType  = reflect.TypeOf((*ast.Object)(nil))
+	scopeType   = reflect.TypeOf((*ast.Scope)(nil))
+	commentType = reflect.TypeOf((*ast.CommentGroup)(nil))
+)
+
+// semanticBlocks drops changed blocks which ast is same as
+// in version of file before changes ignoring comments and
+// positions, files without changed blocks are dropped too
+func semanticBlocks(
+	ctx context.Context, gitCmd GitCMD,
+	changes []Change, changedBlocks map[string]FileInfo,
+) map[string]FileInfo {
+	out := map[string]FileInfo{}
+	for _, change := range changes {
+		info, ok := changedBlocks[change.fpath]
+		if !ok {
+			continue
+		}
+		if _, ok := out[change.fpath]; ok {
+			continue
+		}
+		oldDecls, newDecls, ok := fileDecls(ctx, gitCmd, change)
+		if !ok {
+			// new or not parsable file
+			out[change.fpath] = info
+			continue
+		}
+		blocks := info.blocks
+		info.blocks = nil
+		for _, block := range blocks {
+			key := blockKey(block)
+			if !equalNodes(oldDecls[key], newDecls[key]) {
+				info.blocks = append(info.blocks, block)
+			}
+		}
+		if len(info.blocks) > 0 {
+			out[change.fpath] = info
+		}
+	}
+	return out
+}
+
+// removedBlocks returns blocks of version of files before
+// changes which declarations are deleted or moved to other
+// file, all blocks of deleted files and files moved to other
+// package are removed, by old file path
+func removedBlocks(ctx context.Context, gitCmd GitCMD, changes []Change) map[string]FileInfo {
+	out := map[string]FileInfo{}
+	seen := map[string]bool{}
+	for _, change := range changes {
+		if !strings.HasSuffix(change.fpathOld, ".go") || seen[change.fpathOld] {
+			continue
+		}
+		seen[change.fpathOld] = true
+		if change.fpath == change.fpathOld && change.start == 0 && change.count == 0 {
+			// new untracked file
+			continue
+		}
+		src, err := gitCmd.Show(ctx, change.fpathOld)
+		if err != nil {
+			// new file
+			continue
+		}
+		info, err := getFileInfo(change.fpathOld, src)
+		if err != nil {
+			continue
+		}
+		var newDecls map[string][]ast.Node
+		if change.fpath != "" && filepath.Dir(change.fpath) == filepath.Dir(change.fpathOld) {
+			cur, err := gitCmd.Content(ctx, change.fpath)
+			if err != nil {
+				continue
+			}
+			f, err := parser.ParseFile(token.NewFileSet(), change.fpath, cur, 0)
+			if err != nil {
+				// build fails anyway
+				continue
+			}
+			newDecls = declNodes(f)
+		}
+		blocks := info.blocks
+		info.blocks = nil
+		for _, block := range blocks {
+			if _, ok := newDecls[blockKey(block)]; !ok {
+				info.blocks = append(info.blocks, block)
+			}
+		}
+		if len(info.blocks) > 0 {
+			out[change.fpathOld] = info
+		}
+	}
+	return out
+}
+
+// semanticChange checks if file ast differs
+// from version before changes ignoring comments
+// and positions
+func semanticChange(ctx context.Context, gitCmd GitCMD, change Change) bool {
+	oldDecls, newDecls, ok := fileDecls(ctx, gitCmd, change)
+	if !ok || len(oldDecls) != len(newDecls) {
+		return true
+	}
+	for key, decl := range newDecls {
+		if !equalNodes(oldDecls[key], decl) {
+			return true
+		}
+	}
+	return false
+}
+
+// fileDecls returns declarations by block key of changed
+// file before and after change, false if file is new
+// or can not be parsed
+func fileDecls(ctx context.Context, gitCmd GitCMD, change Change) (
+	oldDecls, newDecls map[string][]ast.Node, ok bool) {
+	if change.fpath == change.fpathOld && change.start == 0 && change.count == 0 {
+		// new untracked file
+		return nil, nil, false
+	}
+	if filepath.Dir(change.fpath) != filepath.Dir(change.fpathOld) {
+		// moved to other package
+		return nil, nil, false
+	}
+	src, err := gitCmd.Show(ctx, change.fpathOld)
+	if err != nil {
+		return nil, nil, false
+	}
+	oldFile, err := parser.ParseFile(token.NewFileSet(), change.fpathOld, src, 0)
+	if err != nil {
+		return nil, nil, false
+	}
+	cur, err := gitCmd.Content(ctx, change.fpath)
+	if err != nil {
+		return nil, nil, false
+	}
+	newFile, err := parser.ParseFile(token.NewFileSet(), change.fpath, cur, 0)
+	if err != nil {
+		return nil, nil, false
+	}
+	return declNodes(oldFile), declNodes(newFile), true
+}
+
+// declNodes returns nodes of file declarations by block key,
+// all init funcs of file have same key, imports are
+// stored with package name key
+func declNodes(f *ast.File) map[string][]ast.Node {
+	nodes := map[string][]ast.Node{
+		"package": {f.Name},
+	}
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			block, ok := funcDeclBlock(d)
+			if !ok {
+				continue
+			}
+			key := blockKey(block)
+			nodes[key] = append(nodes[key], d)
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch spec := spec.(type) {
+				case *ast.ImportSpec:
+					nodes["package"] = append(nodes["package"], spec)
+				case *ast.TypeSpec:
+					key := blockKey(FileBlock{typ: BlockType, name: spec.Name.Name})
+					nodes[key] = append(nodes[key], spec)
+				case *ast.ValueSpec:
+					typ := BlockVar
+					if d.Tok == token.CONST {
+						typ = BlockConst
+					}
+					for _, ident := range spec.Names {
+						key := blockKey(FileBlock{typ: typ, name: ident.Name})
+						nodes[key] = append(nodes[key], spec)
+					}
+				}
+			}
+		}
+	}
+	return nodes
+}
+
+// funcDeclBlock returns kind and name of func block
+// without lines, false if receiver is not parsable
+func funcDeclBlock(fn *ast.FuncDecl) (FileBlock, bool) {
+	block := FileBlock{typ: BlockFunc, name: fn.Name.Name}
+	if fn.Recv != nil {
+		recv, err := recvTypeName(fn.Recv.List[0].Type)
+		if err != nil {
+			return block, false
+		}
+		block = FileBlock{typ: BlockMethod, name: recv + "." + fn.Name.Name}
+	} else if fn.Name.Name == "init" {
+		block.typ = BlockInit
+	}
+	return block, true
+}
+
+// blockKey returns key of block kind and name
+func blockKey(block FileBlock) string {
+	return strconv.FormatUint(uint64(block.typ), 10) + ":" + block.name
+}
+
+// equalNodes compares ast nodes ignoring
+// positions, comments and resolved objects
+func equalNodes(a, b []ast.Node) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !equalValues(reflect.ValueOf(a[i]), reflect.ValueOf(b[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+func equalValues(a, b reflect.Value) bool {
+	if a.Type() != b.Type() {
+		return false
+	}
+	switch a.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		return equalValues(a.Elem(), b.Elem())
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			switch a.Type().Field(i).Type {
+			case posType, objectType, scopeType, commentType:
+				continue
+			}
+			if !equalValues(a.Field(i), b.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Slice:
+		if a.Len() != b.Len() {
+			return false
+		}
+		for i := 0; i < a.Len(); i++ {
+			if !equalValues(a.Index(i), b.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.String:
+		return a.String() == b.String()
+	case reflect.Bool:
+		return a.Bool() == b.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() == b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return a.Uint() == b.Uint()
+	}
+	return false
+}
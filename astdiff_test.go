@@ -0,0 +1,307 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSemanticBlocks(t *testing.T) {
+	testDir := filepath.Join(os.TempDir(), "test_semantic_blocks")
+	gitCmdRun := NewGitCmd(testDir)
+	fileA := []byte(`package main
+
+const limit = 10
+
+func add(a, b int) int {
+	return a + b
+}
+
+func sub(a, b int) int {
+	return a - b
+}
+`)
+	fileB := []byte(`package main
+
+func mul(a, b int) int {
+	return a * b
+}
+`)
+	setupTestGitDir(t, testDir,
+		map[string][]byte{"go.mod": gomod, "file_a.go": fileA, "file_b.go": fileB},
+		[]string{"go.mod", "file_a.go", "file_b.go"})
+	defer func() {
+		if !t.Failed() {
+			_ = os.RemoveAll(testDir)
+		}
+	}()
+	cases := []struct {
+		desc  string
+		files map[string][]byte
+		// not staged files, changes are diffed
+		// against staged if not empty
+		unstaged map[string][]byte
+		outNames map[string][]string
+	}{
+		{
+			desc: "Comments and formatting only",
+			files: map[string][]byte{
+				"file_a.go": []byte(`package main
+
+// limit of values
+const limit = 10
+
+func add(a, b int) int {
+	// sum
+	return a +
+		b
+}
+
+func sub(a, b int) int {
+
+
+	return a - b // difference
+}
+`)},
+		},
+		{
+			desc: "Comment and change in same file",
+			files: map[string][]byte{
+				"file_a.go": []byte(`package main
+
+const limit = 10
+
+// add sums
+func add(a, b int) int {
+	return a + b
+}
+
+func sub(a, b int) int {
+	return b - a
+}
+`)},
+			outNames: map[string][]string{"file_a.go": {"sub"}},
+		},
+		{
+			desc: "Change of const value and new file",
+			files: map[string][]byte{
+				"file_a.go": []byte(`package main
+
+const limit = 0x10
+
+func add(a, b int) int {
+	return a + b
+}
+
+func sub(a, b int) int {
+	return b - a
+}
+`),
+				"file_c.go": []byte(`package main
+
+func div(a, b int) int {
+	return a / b
+}
+`)},
+			outNames: map[string][]string{"file_a.go": {"limit"}, "file_c.go": {"div"}},
+		},
+		{
+			desc: "Staged comments and not staged change",
+			files: map[string][]byte{
+				"file_b.go": []byte(`package main
+
+func mul(a, b int) int {
+	// product
+	return a * b
+}
+`)},
+			unstaged: map[string][]byte{
+				"file_b.go": []byte(`package main
+
+func mul(a, b int) int {
+	// product
+	return b * a * 1
+}
+`)},
+		},
+	}
+	writeFiles := func(files map[string][]byte) error {
+		for fname, data := range files {
+			err := ioutil.WriteFile(filepath.Join(testDir, fname), data, 0600)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for i, tc := range cases {
+		gitCmd := NewGitCMD(testDir)
+		execTestHelper(t, i, tc.desc, func() error { return writeFiles(tc.files) })
+		if tc.unstaged != nil {
+			gitCmd = NewGitCMDWithBase(testDir, "staged")
+			execTestHelper(t, i, tc.desc, func() error {
+				if err := gitCmdRun("add", "."); err != nil {
+					return err
+				}
+				return writeFiles(tc.unstaged)
+			})
+		}
+		changes, err := gitCmd.Diff(context.Background())
+		if isUnexpectedErr(t, i, tc.desc, nil, err) {
+			continue
+		}
+		fileInfos := map[string]FileInfo{}
+		for _, change := range changes {
+			src, err := gitCmd.Content(context.Background(), change.fpath)
+			if err != nil {
+				t.Fatalf("case [%d] %s\nContent error %v", i, tc.desc, err)
+			}
+			info, err := getFileInfo(filepath.Join(testDir, change.fpath), src)
+			if err != nil {
+				t.Fatalf("case [%d] %s\ngetFileInfo error %v", i, tc.desc, err)
+			}
+			fileInfos[change.fpath] = info
+		}
+		changedBlocks, err := changesToFileBlocks(changes, fileInfos)
+		if isUnexpectedErr(t, i, tc.desc, nil, err) {
+			continue
+		}
+		out := semanticBlocks(context.Background(), gitCmd, changes, changedBlocks)
+		var outNames map[string][]string
+		for fname, info := range out {
+			if outNames == nil {
+				outNames = map[string][]string{}
+			}
+			for _, block := range info.blocks {
+				outNames[fname] = append(outNames[fname], block.name)
+			}
+		}
+		if !reflect.DeepEqual(tc.outNames, outNames) {
+			t.Errorf("case [%d] %s\nexpected blocks %v\ngot %v", i, tc.desc, tc.outNames, outNames)
+		}
+		execTestHelper(t, i, tc.desc, func() error {
+			if err := gitCmdRun("add", "."); err != nil {
+				return err
+			}
+			return gitCmdRun("commit", "-m", "commit changes")
+		})
+	}
+}
+
+func TestRemovedBlocks(t *testing.T) {
+	testDir := filepath.Join(os.TempDir(), "test_removed_blocks")
+	gitCmdRun := NewGitCmd(testDir)
+	fileA := []byte(`package main
+
+func add(a, b int) int {
+	return a + b
+}
+
+func sub(a, b int) int {
+	return a - b
+}
+`)
+	fileB := []byte(`package main
+
+func mul(a, b int) int {
+	return a * b
+}
+`)
+	fileAMoveSub := []byte(`package main
+
+func add(a, b int) int {
+	return a + b
+}
+`)
+	fileBMoveSub := []byte(`package main
+
+func mul(a, b int) int {
+	return a * b
+}
+
+func sub(a, b int) int {
+	return a - b
+}
+`)
+	setupTestGitDir(t, testDir,
+		map[string][]byte{"go.mod": gomod, "file_a.go": fileA, "file_b.go": fileB},
+		[]string{"go.mod", "file_a.go", "file_b.go"})
+	defer func() {
+		if !t.Failed() {
+			_ = os.RemoveAll(testDir)
+		}
+	}()
+	cases := []struct {
+		desc     string
+		setup    func() error
+		base     string
+		outNames map[string][]string
+	}{
+		{
+			desc: "Move sub to file_b.go",
+			setup: func() error {
+				err := ioutil.WriteFile(filepath.Join(testDir, "file_a.go"), fileAMoveSub, 0600)
+				if err != nil {
+					return err
+				}
+				return ioutil.WriteFile(filepath.Join(testDir, "file_b.go"), fileBMoveSub, 0600)
+			},
+			outNames: map[string][]string{"file_a.go": {"sub"}},
+		},
+		{
+			desc: "Delete file_b.go",
+			setup: func() error {
+				return os.Remove(filepath.Join(testDir, "file_b.go"))
+			},
+			outNames: map[string][]string{"file_b.go": {"mul", "sub"}},
+		},
+		{
+			desc: "Rename file_a.go to other package",
+			setup: func() error {
+				err := os.Mkdir(filepath.Join(testDir, "pkga"), 0700)
+				if err != nil {
+					return err
+				}
+				err = gitCmdRun("mv", "file_a.go", filepath.Join("pkga", "file_a.go"))
+				if err != nil {
+					return err
+				}
+				return gitCmdRun("commit", "-m", "move file_a.go")
+			},
+			base:     "HEAD~1",
+			outNames: map[string][]string{"file_a.go": {"add"}},
+		},
+	}
+	for i, tc := range cases {
+		execTestHelper(t, i, tc.desc, tc.setup)
+		gitCmd := NewGitCMD(testDir)
+		if tc.base != "" {
+			gitCmd = NewGitCMDWithBase(testDir, tc.base)
+		}
+		changes, err := gitCmd.Diff(context.Background())
+		if isUnexpectedErr(t, i, tc.desc, nil, err) {
+			continue
+		}
+		var outNames map[string][]string
+		for fname, info := range removedBlocks(context.Background(), gitCmd, changes) {
+			if outNames == nil {
+				outNames = map[string][]string{}
+			}
+			for _, block := range info.blocks {
+				outNames[fname] = append(outNames[fname], block.name)
+			}
+		}
+		if !reflect.DeepEqual(tc.outNames, outNames) {
+			t.Errorf("case [%d] %s\nexpected blocks %v\ngot %v", i, tc.desc, tc.outNames, outNames)
+		}
+		execTestHelper(t, i, tc.desc, func() error {
+			if err := gitCmdRun("add", "-A"); err != nil {
+				return err
+			}
+			return gitCmdRun("commit", "-m", "commit changes", "--allow-empty")
+		})
+	}
+}
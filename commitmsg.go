@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// defaultCommitTemplate is message of auto commits
+// listing changed blocks
+const defaultCommitTemplate = `auto_commit! {{join .Blocks " "}}`
+
+// autoCommitTrailer is appended to messages of auto commits,
+// squash matches auto commits by it whatever the template is
+const autoCommitTrailer = "Gtr-Auto-Commit: true"
+
+// CommitMessage is data of auto commit message template
+type CommitMessage struct {
+	// Files changed go files
+	Files []string
+	// Blocks names of changed funcs, methods, types, vars and consts
+	Blocks []string
+	// Kinds block names by kind func, method, type, var, const and init
+	Kinds map[string][]string
+	// Tests run as pkg.TestName
+	Tests []string
+	// Passed, Failed and Skipped count tests and subtests
+	Passed, Failed, Skipped int
+	// Duration of tests run
+	Duration time.Duration
+}
+
+// CommitOptions of auto commits
+type CommitOptions struct {
+	Template *template.Template
+	Sign     bool     // gpg sign, -S
+	Author   string   // "Name <email>" overrides author
+	NoVerify bool     // skip pre-commit and commit-msg hooks
+	Trailers []string // "Key: value" lines appended to message
+}
+
+// NewCommitOptions returns options with parsed message
+// template, default template if empty
+func NewCommitOptions(
+	tmpl string,
+	sign bool,
+	author string,
+	noVerify bool,
+	trailers []string,
+) (CommitOptions, error) {
+	if tmpl == "" {
+		tmpl = defaultCommitTemplate
+	}
+	t, err := parseCommitTemplate(tmpl)
+	if err != nil {
+		return CommitOptions{}, err
+	}
+	return CommitOptions{
+		Template: t,
+		Sign:     sign,
+		Author:   author,
+		NoVerify: noVerify,
+		Trailers: trailers,
+	}, nil
+}
+
+// parseCommitTemplate parses message template,
+// join func is strings.Join
+func parseCommitTemplate(tmpl string) (*template.Template, error) {
+	return template.New("commit").
+		Funcs(template.FuncMap{"join": strings.Join}).
+		Parse(tmpl)
+}
+
+// message returns rendered message with tests, configured
+// trailers and auto commit trailer in last paragraph
+func (opts CommitOptions) message(data CommitMessage) (string, error) {
+	var buf bytes.Buffer
+	err := opts.Template.Execute(&buf, data)
+	if err != nil {
+		return "", err
+	}
+	msg := strings.TrimSpace(buf.String())
+	var trailers []string
+	if len(data.Tests) > 0 {
+		// passed tests, collected by squash
+		trailers = append(trailers, testsTrailer+strings.Join(data.Tests, " "))
+	}
+	trailers = append(trailers, opts.Trailers...)
+	trailers = append(trailers, autoCommitTrailer)
+	msg += "\n\n" + strings.Join(trailers, "\n")
+	return msg, nil
+}
+
+// args returns git commit args of options
+func (opts CommitOptions) args() []string {
+	var args []string
+	if opts.Sign {
+		args = append(args, "-S")
+	}
+	if opts.NoVerify {
+		args = append(args, "--no-verify")
+	}
+	if opts.Author != "" {
+		args = append(args, "--author", opts.Author)
+	}
+	return args
+}
+
+// newCommitMessage returns template data of changed blocks
+// by file and tests result
+func newCommitMessage(changedBlocks map[string]FileInfo, res *TaskResult) CommitMessage {
+	data := CommitMessage{Kinds: map[string][]string{}}
+	blocks := map[string]bool{}
+	kinds := map[string]map[string]bool{}
+	for fname, info := range changedBlocks {
+		data.Files = append(data.Files, fname)
+		for _, block := range info.blocks {
+			blocks[block.name] = true
+			kind := blockKindName(block.typ)
+			if kinds[kind] == nil {
+				kinds[kind] = map[string]bool{}
+			}
+			kinds[kind][block.name] = true
+		}
+	}
+	sort.Strings(data.Files)
+	data.Blocks = mapStrToSlice(blocks)
+	sort.Strings(data.Blocks)
+	for kind, names := range kinds {
+		data.Kinds[kind] = mapStrToSlice(names)
+		sort.Strings(data.Kinds[kind])
+	}
+	if res == nil {
+		return data
+	}
+	data.Duration = res.Duration
+	if tests, ok := res.Meta[metaTests].([]string); ok {
+		data.Tests = tests
+	}
+	if report, ok := res.Meta[metaTestsReport].(*TestsReport); ok {
+		data.Passed = len(report.Passed())
+		data.Failed = len(report.Failed())
+		data.Skipped = len(report.Skipped())
+	}
+	return data
+}
+
+// blockKindName returns name of block kind
+func blockKindName(kind BlockKind) string {
+	switch kind {
+	case BlockType:
+		return "type"
+	case BlockFunc:
+		return "func"
+	case BlockMethod:
+		return "method"
+	case BlockVar:
+		return "var"
+	case BlockConst:
+		return "const"
+	case BlockInit:
+		return "init"
+	}
+	return "unknown"
+}
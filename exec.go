@@ -74,6 +74,8 @@ type MockCommand struct {
 	success        bool
 	error          error
 	execLog        []string
+	// output written to stdout on Run
+	output []byte
 }
 
 // NewMockCommand returns preconfigured command
@@ -99,6 +101,9 @@ func (c *MockCommand) GetArgs() []string {
 
 // Run --
 func (c *MockCommand) Run() error {
+	if c.stdOut != nil && len(c.output) > 0 {
+		_, _ = c.stdOut.Write(c.output)
+	}
 	return c.error
 }
 
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Explanation describes why test was selected by strategy
+type Explanation struct {
+	// Test is pkg.TestName or subtest name
+	Test string `json:"test"`
+	// Changed is changed file block as file:name
+	Changed string `json:"changed"`
+	// Path is callgraph path from test to changed func
+	Path []string `json:"path,omitempty"`
+	// CoverProfile is profile file with block covering change
+	CoverProfile string `json:"cover_profile,omitempty"`
+	// CoverBlock is covered lines [start, end]
+	CoverBlock [2]int `json:"cover_block,omitempty"`
+	// Source is strategy name in hybrid strategy
+	Source string `json:"source,omitempty"`
+}
+
+func (e Explanation) String() string {
+	if e.Source != "" {
+		src := e
+		src.Source = ""
+		return e.Source + ": " + src.String()
+	}
+	if e.CoverProfile != "" {
+		return fmt.Sprintf("%s covers %s lines %d-%d in %s",
+			e.Test, e.Changed, e.CoverBlock[0], e.CoverBlock[1], e.CoverProfile)
+	}
+	if len(e.Path) > 0 {
+		return fmt.Sprintf("%s reaches %s: %s",
+			e.Test, e.Changed, strings.Join(e.Path, " -> "))
+	}
+	return fmt.Sprintf("%s changed %s", e.Test, e.Changed)
+}
+
+// Explainer is implemented by strategies which can
+// explain why tests were selected
+type Explainer interface {
+	// SetExplain enables recording of explanations
+	SetExplain(bool)
+	// Explanations returns explanations of last TestsToRun call
+	Explanations() []Explanation
+}
+
+// explainedBlock returns changed block description
+func explainedBlock(fname string, block FileBlock) string {
+	return fname + ":" + block.name
+}
+
+// explainedRemovedBlock returns name of block removed
+// from old version of file
+func explainedRemovedBlock(fname string, block FileBlock) string {
+	return "removed " + explainedBlock(fname, block)
+}
+
+// sortExplanations sorts explanations by test, change and path
+func sortExplanations(list []Explanation) {
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Test != list[j].Test {
+			return list[i].Test < list[j].Test
+		}
+		if list[i].Changed != list[j].Changed {
+			return list[i].Changed < list[j].Changed
+		}
+		return strings.Join(list[i].Path, " ") < strings.Join(list[j].Path, " ")
+	})
+}
+
+// explain prints why tests from cfg.cmdArgs were selected
+// or not, all selected tests explained if none provided
+func explain(ctx context.Context, cfg config, logger *log.Logger) int {
+	gitCmd, err := newGitCMD(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "git error %v\n", err)
+		return exitError
+	}
+	logger.SetOutput(os.Stderr)
+	cfg.explain = true
+	sel, err := selectTests(ctx, newStrategy(cfg, gitCmd, logger))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "select tests error %v\n", err)
+		return exitError
+	}
+	printExplanations(os.Stdout, sel, cfg.cmdArgs)
+	return exitOK
+}
+
+// printExplanations writes explanations of tests selection,
+// names are full pkg.TestName, test or subtest names
+func printExplanations(out io.Writer, sel *TestsSelection, names []string) {
+	if len(names) == 0 {
+		names = append(names, sel.Tests...)
+		names = append(names, sel.SubTests...)
+	}
+	if len(names) == 0 {
+		fmt.Fprintln(out, "no tests selected")
+		return
+	}
+	for _, name := range names {
+		var found []Explanation
+		for _, e := range sel.Explanations {
+			if matchTestName(e.Test, name) {
+				found = append(found, e)
+			}
+		}
+		if len(found) > 0 {
+			for _, e := range found {
+				fmt.Fprintln(out, e)
+			}
+			continue
+		}
+		selected := false
+		for _, test := range append(sel.Tests, sel.SubTests...) {
+			if matchTestName(test, name) {
+				selected = true
+				break
+			}
+		}
+		if selected {
+			fmt.Fprintf(out, "%s selected, no explanation recorded\n", name)
+		} else {
+			fmt.Fprintf(out, "%s not selected, no path to changed code found\n", name)
+		}
+	}
+}
+
+// matchTestName checks if test is pkg.TestName or subtest with
+// provided full or short name
+func matchTestName(test, name string) bool {
+	return test == name || strings.HasSuffix(test, "."+name)
+}
+
+// logExplanations logs explanations of selected tests
+func logExplanations(log *log.Logger, list []Explanation) {
+	log.Println("=============")
+	log.Println("Selected because")
+	for i := range list {
+		log.Printf("-> %s\n", list[i])
+	}
+	log.Println("=============")
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPrintExplanations(t *testing.T) {
+	sel := &TestsSelection{
+		Tests:    []string{"module.TestAdd", "module/pkga.TestSub", "module.TestInit"},
+		SubTests: []string{"max"},
+		Explanations: []Explanation{
+			{Test: "module.TestAdd", Changed: "file_a.go:add",
+				Path: []string{"module.TestAdd", "module.helper", "module.add"}},
+			{Test: "module/pkga.TestSub", Changed: "pkga/file_a.go:Sub",
+				CoverProfile: ".gtr/module_pkga.TestSub", CoverBlock: [2]int{7, 9}},
+			{Test: "max", Changed: "file_b.go:max",
+				Path: []string{"module.helperMax", "module.max"}},
+		},
+	}
+	cases := []struct {
+		desc   string
+		sel    *TestsSelection
+		names  []string
+		output string
+	}{
+		{desc: "no tests selected", sel: &TestsSelection{},
+			output: "no tests selected\n"},
+		{desc: "all selected tests", sel: sel,
+			output: `module.TestAdd reaches file_a.go:add: module.TestAdd -> module.helper -> module.add
+module/pkga.TestSub covers pkga/file_a.go:Sub lines 7-9 in .gtr/module_pkga.TestSub
+module.TestInit selected, no explanation recorded
+max reaches file_b.go:max: module.helperMax -> module.max
+`},
+		{desc: "short and not selected test names", sel: sel,
+			names: []string{"TestSub", "pkga.TestAdd"},
+			output: `module/pkga.TestSub covers pkga/file_a.go:Sub lines 7-9 in .gtr/module_pkga.TestSub
+pkga.TestAdd not selected, no path to changed code found
+`},
+	}
+	for i, tc := range cases {
+		var out bytes.Buffer
+		printExplanations(&out, tc.sel, tc.names)
+		if out.String() != tc.output {
+			t.Errorf("case [%d] %s\nexpected %s\ngot %s", i, tc.desc, tc.output, out.String())
+		}
+	}
+}
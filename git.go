@@ -2,10 +2,9 @@ package main
 
 import (
 	"bytes"
-	"errors"
+	"io/ioutil"
 	"log"
 	"path/filepath"
-	"regexp"
 	"sort"
 
 	"context"
@@ -14,41 +13,90 @@ import (
 	"strings"
 )
 
-// regex to get untracked go file names from git status --short
-var reFnameUntrackedFiles = regexp.MustCompile(`\?\? (?P<fname>[a-zA-Z0-9_\-/]+\.go)`)
+// GitCMD returns changes of working tree or commits
+// and content of files before and after changes
+type GitCMD interface {
+	// Diff returns file changes against base
+	Diff(ctx context.Context) ([]Change, error)
+	// Show returns content of file before changes
+	Show(ctx context.Context, fpath string) ([]byte, error)
+	// Content returns content of file after changes
+	Content(ctx context.Context, fpath string) ([]byte, error)
+	// WorkDir returns dir changes are relative to
+	WorkDir() string
+}
+
+var _ GitCMD = (*ExecGitCMD)(nil)
 
-// GitCMD git wrapper
-type GitCMD struct {
+// ExecGitCMD git wrapper, runs git binary
+type ExecGitCMD struct {
 	workDir string
+	// base to diff against, index if empty, staged,
+	// ref or commit range A..B, A...B
+	base string
 }
 
 // NewGitCMD returns git wrapper
-func NewGitCMD(workDir string) *GitCMD {
-	return &GitCMD{workDir}
+func NewGitCMD(workDir string) GitCMD {
+	return &ExecGitCMD{workDir: workDir}
+}
+
+// NewGitCMDWithBase returns git wrapper which diffs
+// working tree against index if base is empty or "index",
+// against merge base of base ref and HEAD, e.g. HEAD for all
+// not committed changes or main for all changes of branch,
+// diffs index against HEAD if base is "staged"
+// or diffs commits of range A..B or A...B
+func NewGitCMDWithBase(workDir, base string) GitCMD {
+	return &ExecGitCMD{workDir: workDir, base: base}
 }
 
-// Diff returns file changes
+// WorkDir returns dir changes are relative to
+func (g *ExecGitCMD) WorkDir() string {
+	return g.workDir
+}
+
+// Diff returns file changes against base, untracked
+// files are included if working tree is diffed
 // TODO pass CommandExecutor
-func (g *GitCMD) Diff(ctx context.Context) ([]Change, error) {
+func (g *ExecGitCMD) Diff(ctx context.Context) ([]Change, error) {
+	if isCommitRange(g.base) {
+		return g.diffCommitted(ctx, g.base)
+	}
+	if g.base == "staged" {
+		return g.diffCommitted(ctx, "--cached")
+	}
+	rev, err := g.baseRev(ctx)
+	if err != nil {
+		return nil, err
+	}
 	var gitOut bytes.Buffer
 	var results []Change
-	// get not yet committed go files in a workdir
-	gitCmd := exec.CommandContext(ctx, "git", "-C", g.workDir, "status", "--short", g.workDir)
+	// get not yet committed files in a workdir, NUL separated
+	// names are not quoted
+	gitCmd := exec.CommandContext(ctx, "git", "-C", g.workDir, "ls-files",
+		"--others", "--exclude-standard", "-z")
 	gitCmd.Stdout = &gitOut
-	err := gitCmd.Run()
+	err = gitCmd.Run()
 	if err != nil {
 		return nil, err
 	}
-	matches := reFnameUntrackedFiles.FindAllString(gitOut.String(), -1)
-	for i := range matches {
-		fname := reFnameUntrackedFiles.ReplaceAllString(matches[i], "${fname}")
-		results = append(results, Change{fname, fname, 0, 0})
+	for _, fname := range strings.Split(gitOut.String(), "\x00") {
+		if fname != "" {
+			fname = filepath.FromSlash(fname)
+			results = append(results, Change{fname, fname, 0, 0})
+		}
 	}
 	gitOut.Reset()
 	// get git diff changes only in workDir (--relative)
 	// -U0 zero lines around changes
 	// Disallow external diff drivers.
-	gitCmd = exec.CommandContext(ctx, "git", "-C", g.workDir, "diff", "-U0", "--no-ext-diff", "--relative")
+	args := []string{"-C", g.workDir, "-c", "core.quotepath=off",
+		"diff", "-U0", "--no-ext-diff", "--relative"}
+	if rev != "" {
+		args = append(args, rev)
+	}
+	gitCmd = exec.CommandContext(ctx, "git", args...)
 	gitCmd.Stdout = &gitOut
 	err = gitCmd.Run()
 	if err != nil {
@@ -62,22 +110,122 @@ func (g *GitCMD) Diff(ctx context.Context) ([]Change, error) {
 	return results, nil
 }
 
+// diffCommitted returns changes committed in commit range
+// or staged changes with --cached
+func (g *ExecGitCMD) diffCommitted(ctx context.Context, rev string) ([]Change, error) {
+	var gitOut bytes.Buffer
+	gitCmd := exec.CommandContext(ctx, "git", "-C", g.workDir, "-c", "core.quotepath=off",
+		"diff", "-U0", "--no-ext-diff", "--relative", rev)
+	gitCmd.Stdout = &gitOut
+	err := gitCmd.Run()
+	if err != nil {
+		return nil, fmt.Errorf("git diff %s error %v", rev, err)
+	}
+	return changesFromGitDiff(gitOut)
+}
+
+// baseRev returns commit changes are diffed against,
+// empty if diffed against index
+func (g *ExecGitCMD) baseRev(ctx context.Context) (string, error) {
+	if g.base == "" || g.base == "index" {
+		return "", nil
+	}
+	if g.base == "staged" {
+		return "HEAD", nil
+	}
+	from, to, mergeBase := splitBase(g.base)
+	if !mergeBase {
+		return from, nil
+	}
+	var gitOut bytes.Buffer
+	gitCmd := exec.CommandContext(ctx, "git", "-C", g.workDir, "merge-base", from, to)
+	gitCmd.Stdout = &gitOut
+	err := gitCmd.Run()
+	if err != nil {
+		return "", fmt.Errorf("git merge-base %s %s error %v", from, to, err)
+	}
+	return strings.TrimSpace(gitOut.String()), nil
+}
+
+// isCommitRange checks if base is A..B or A...B
+func isCommitRange(base string) bool {
+	return strings.Contains(base, "..")
+}
+
+// splitBase returns revisions of ref or commit range base,
+// HEAD if omitted, and if merge base of them is diffed against
+func splitBase(base string) (from, to string, mergeBase bool) {
+	if !isCommitRange(base) {
+		return base, "HEAD", true
+	}
+	sep := ".."
+	if strings.Contains(base, "...") {
+		sep = "..."
+	}
+	i := strings.Index(base, sep)
+	from, to = base[:i], base[i+len(sep):]
+	if from == "" {
+		from = "HEAD"
+	}
+	if to == "" {
+		to = "HEAD"
+	}
+	return from, to, sep == "..."
+}
+
+// Show returns content of file before changes, from
+// index or from commit changes are diffed against
+func (g *ExecGitCMD) Show(ctx context.Context, fpath string) ([]byte, error) {
+	rev, err := g.baseRev(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return g.show(ctx, rev, fpath)
+}
+
+// Content returns content of file after changes, from
+// working tree, index if staged or last commit of range
+func (g *ExecGitCMD) Content(ctx context.Context, fpath string) ([]byte, error) {
+	if g.base == "staged" {
+		return g.show(ctx, "", fpath)
+	}
+	if isCommitRange(g.base) {
+		_, to, _ := splitBase(g.base)
+		return g.show(ctx, to, fpath)
+	}
+	return ioutil.ReadFile(filepath.Join(g.workDir, fpath))
+}
+
+// show returns content of file at rev, index if empty
+func (g *ExecGitCMD) show(ctx context.Context, rev, fpath string) ([]byte, error) {
+	var gitOut bytes.Buffer
+	// path relative to workDir
+	gitCmd := exec.CommandContext(ctx, "git", "-C", g.workDir, "show",
+		rev+":./"+filepath.ToSlash(fpath))
+	gitCmd.Stdout = &gitOut
+	err := gitCmd.Run()
+	if err != nil {
+		return nil, fmt.Errorf("git show %s error %v", fpath, err)
+	}
+	return gitOut.Bytes(), nil
+}
+
 // CommitChanges returns task to git commit file changes
-// TODO maybe use branch as config gtr-no-commit, will suspend from committing
+// to checked out branch, see CommitSnapshot to keep branch intact
 func CommitChanges(
 	workDir string,
+	opts CommitOptions,
 	newCmd CommandCreator,
-) func(*log.Logger, context.Context) (string, error) {
+) func(*log.Logger, context.Context) (*TaskResult, error) {
 	gitcmd := NewGitCMD(workDir)
-	return func(log *log.Logger, ctx context.Context) (string, error) {
-		in := ctx.Value(prevTaskOutputKey).(string)
-		if !strings.HasPrefix(in, "Tests PASS:") {
-			return "", errors.New("nothing to commit")
+	return func(log *log.Logger, ctx context.Context) (*TaskResult, error) {
+		if !prevTaskResult(ctx).Success() {
+			return NewTaskResult(TaskSkipped, "nothing to commit"), nil
 		}
 		// get types changed changed, used as commit message
 		changes, err := gitcmd.Diff(ctx)
 		if err != nil {
-			return fmt.Sprintf("%s %s", "Commit error %v", err), nil
+			return NewTaskResult(TaskError, fmt.Sprintf("Commit error %v", err)), nil
 		}
 
 		// filter go files
@@ -92,14 +240,14 @@ func CommitChanges(
 		}
 		changes = changes[:n]
 		if len(changes) == 0 {
-			return "", errors.New("nothing to commit")
+			return NewTaskResult(TaskSkipped, "nothing to commit"), nil
 		}
 		fileInfos := map[string]FileInfo{}
 		for _, change := range changes {
 			if _, ok := fileInfos[change.fpath]; !ok {
 				info, err := getFileInfo(filepath.Join(workDir, change.fpath), nil)
 				if err != nil {
-					return fmt.Sprintf("Commit add error %v\n", err), nil
+					return NewTaskResult(TaskError, fmt.Sprintf("Commit add error %v", err)), nil
 				}
 				fileInfos[change.fpath] = info
 			}
@@ -107,13 +255,7 @@ func CommitChanges(
 
 		changedBlocks, err := changesToFileBlocks(changes, fileInfos)
 		if err != nil {
-			return fmt.Sprintf("Commit add error %v\n", err), nil
-		}
-		effectedBlocks := map[string]bool{}
-		for _, info := range changedBlocks {
-			for _, block := range info.blocks {
-				effectedBlocks[block.name] = true
-			}
+			return NewTaskResult(TaskError, fmt.Sprintf("Commit add error %v", err)), nil
 		}
 		list := mapStrToSlice(fileNames)
 		sort.Strings(list)
@@ -122,18 +264,19 @@ func CommitChanges(
 		cmd := newCmd(ctx, "git", append([]string{"-C", workDir, "add"}, list...)...)
 		err = cmd.Run()
 		if err != nil {
-			return fmt.Sprintf("Commit add error %v\n", err), nil
+			return NewTaskResult(TaskError, fmt.Sprintf("Commit add error %v", err)), nil
 		}
-		list = mapStrToSlice(effectedBlocks)
-		sort.Strings(list)
-
-		out := "'auto_commit! " + strings.Join(list, " ") + "'"
+		out, err := opts.message(newCommitMessage(changedBlocks, prevTaskResult(ctx)))
+		if err != nil {
+			return NewTaskResult(TaskError, fmt.Sprintf("Commit message error %v", err)), nil
+		}
+		args := append([]string{"-C", workDir, "commit"}, opts.args()...)
 		// commit changes
-		cmd = newCmd(ctx, "git", append([]string{"-C", workDir, "commit", "-m"}, out)...)
+		cmd = newCmd(ctx, "git", append(args, "-m", out)...)
 		err = cmd.Run()
 		if err != nil {
-			return fmt.Sprintf("Commit commit error %v\n", err), nil
+			return NewTaskResult(TaskError, fmt.Sprintf("Commit commit error %v", err)), nil
 		}
-		return out, nil
+		return NewTaskResult(TaskSuccess, out), nil
 	}
 }
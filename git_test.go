@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"io/ioutil"
 	"log"
@@ -251,7 +252,7 @@ func TestGetDiff(t *testing.T) {
 			tearDown: func() error {
 				return gitCmdRun("commit", "-am", "changes")
 			},
-			output: []Change{{"geo.go", "geo.go", 0, 0}},
+			output: []Change{{"geo.go", "geo.go", 0, 0}, {"main.go", "", 0, 0}},
 		},
 		{
 			desc: "Change untracked file geo.go, add func Area",
@@ -323,24 +324,163 @@ func TestGetDiff(t *testing.T) {
 			},
 			output: []Change{{"geo.go", "geo.go", 8, 0}},
 		},
+		{
+			desc: "Add untracked file with space in name",
+			setup: func() error {
+				return ioutil.WriteFile(filePath("geo area.go"), geogo, 0600)
+			},
+			output: []Change{{"geo area.go", "geo area.go", 0, 0},
+				{"geo.go", "geo.go", 8, 0}},
+		},
+	}
+	goGitCmd, err := NewGoGitCMD(testDir, "")
+	if err != nil {
+		t.Fatalf("NewGoGitCMD error %v", err)
 	}
-	gitcmd := &GitCMD{testDir}
+	backends := map[string]GitCMD{"exec": NewGitCMD(testDir), "go": goGitCmd}
 	for i, tc := range cases {
 		// setup()
 		execTestHelper(t, i, tc.desc, tc.setup)
 
 		// should get line numbers by file and namespace
-		output, err := gitcmd.Diff(context.Background())
+		for name, gitcmd := range backends {
+			output, err := gitcmd.Diff(context.Background())
+			if isUnexpectedErr(t, i, name+" "+tc.desc, tc.expectedErr, err) {
+				continue
+			}
+			diffs := pretty.Diff(tc.output, output)
+			if len(diffs) > 0 {
+				t.Errorf("case [%d] %s %s\nexpected %# v\ngot %# v", i, name, tc.desc, tc.output, output)
+			}
+		}
 
 		// teardown()
 		execTestHelper(t, i, tc.desc, tc.tearDown)
-		if isUnexpectedErr(t, i, tc.desc, tc.expectedErr, err) {
+	}
+}
+
+func TestGetDiffWithBase(t *testing.T) {
+	testDir := filepath.Join(os.TempDir(), "test_get_diff_with_base")
+	filePath := func(fname string) string {
+		return filepath.Join(testDir, fname)
+	}
+	gitCmdRun := NewGitCmd(testDir)
+	setupTestGitDir(t, testDir,
+		map[string][]byte{"math.go": mathgo, "geo.go": geogo},
+		[]string{"math.go", "geo.go"},
+	)
+	defer func() {
+		if !t.Failed() {
+			// clean tmp dir on test success
+			_ = os.RemoveAll(testDir)
+		}
+	}()
+	steps := []func() error{
+		func() error { return gitCmdRun("branch", "base") },
+		func() error { return gitCmdRun("checkout", "-b", "feature") },
+		func() error { return ioutil.WriteFile(filePath("math.go"), mathgo_add_func, 0600) },
+		func() error { return gitCmdRun("commit", "-am", "add max") },
+		// diverge base branch, its changes should not be used
+		func() error { return gitCmdRun("checkout", "base") },
+		func() error { return ioutil.WriteFile(filePath("geo.go"), geo_add_area, 0600) },
+		func() error { return gitCmdRun("commit", "-am", "add area") },
+		func() error { return gitCmdRun("checkout", "feature") },
+		// not committed changes
+		func() error { return ioutil.WriteFile(filePath("main.go"), maingo, 0600) },
+		func() error { return ioutil.WriteFile(filePath("geo.go"), geo_add_area, 0600) },
+		func() error { return gitCmdRun("add", "geo.go") },
+	}
+	for i, step := range steps {
+		execTestHelper(t, i, "setup", step)
+	}
+	cases := []struct {
+		base   string
+		output []Change
+	}{
+		{base: "", output: []Change{{"main.go", "main.go", 0, 0}}},
+		{base: "index", output: []Change{{"main.go", "main.go", 0, 0}}},
+		{base: "staged", output: []Change{{"geo.go", "geo.go", 7, 4}}},
+		{base: "HEAD", output: []Change{
+			{"main.go", "main.go", 0, 0}, {"geo.go", "geo.go", 7, 4}}},
+		{base: "base", output: []Change{
+			{"main.go", "main.go", 0, 0}, {"geo.go", "geo.go", 7, 4},
+			{"math.go", "math.go", 15, 7}}},
+		{base: "base...feature", output: []Change{{"math.go", "math.go", 15, 7}}},
+		{base: "base..feature", output: []Change{
+			{"geo.go", "geo.go", 6, 0}, {"math.go", "math.go", 15, 7}}},
+	}
+	for i, tc := range cases {
+		goGitCmd, err := NewGoGitCMD(testDir, tc.base)
+		if isUnexpectedErr(t, i, tc.base, nil, err) {
 			continue
 		}
+		backends := map[string]GitCMD{
+			"exec": NewGitCMDWithBase(testDir, tc.base), "go": goGitCmd}
+		for name, gitcmd := range backends {
+			output, err := gitcmd.Diff(context.Background())
+			if isUnexpectedErr(t, i, name+" "+tc.base, nil, err) {
+				continue
+			}
+			if diffs := pretty.Diff(tc.output, output); len(diffs) > 0 {
+				t.Errorf("case [%d] %s %s\nexpected %# v\ngot %# v", i, name, tc.base, tc.output, output)
+			}
+		}
+	}
+}
 
-		diffs := pretty.Diff(tc.output, output)
-		if len(diffs) > 0 {
-			t.Errorf("case [%d] %s\nexpected %# v\ngot %# v", i, tc.desc, tc.output, output)
+func TestGitCMDContent(t *testing.T) {
+	testDir := filepath.Join(os.TempDir(), "test_git_cmd_content")
+	filePath := func(fname string) string {
+		return filepath.Join(testDir, fname)
+	}
+	gitCmdRun := NewGitCmd(testDir)
+	setupTestGitDir(t, testDir,
+		map[string][]byte{"math.go": mathgo},
+		[]string{"math.go"},
+	)
+	defer func() {
+		if !t.Failed() {
+			_ = os.RemoveAll(testDir)
+		}
+	}()
+	steps := []func() error{
+		func() error { return ioutil.WriteFile(filePath("math.go"), mathgo_add_func, 0600) },
+		func() error { return gitCmdRun("commit", "-am", "add max") },
+		func() error { return ioutil.WriteFile(filePath("math.go"), mathgo_update_min_func, 0600) },
+		func() error { return gitCmdRun("add", "math.go") },
+		// not staged changes
+		func() error {
+			return ioutil.WriteFile(filePath("math.go"), mathgo_update_pkg_lvl_var_add_comment_change_func, 0600)
+		},
+	}
+	for i, step := range steps {
+		execTestHelper(t, i, "setup", step)
+	}
+	cases := []struct {
+		base   string
+		output []byte
+	}{
+		{base: "", output: mathgo_update_pkg_lvl_var_add_comment_change_func},
+		{base: "HEAD~1", output: mathgo_update_pkg_lvl_var_add_comment_change_func},
+		{base: "staged", output: mathgo_update_min_func},
+		{base: "HEAD~1..HEAD", output: mathgo_add_func},
+		{base: "HEAD~1...HEAD", output: mathgo_add_func},
+	}
+	for i, tc := range cases {
+		goGitCmd, err := NewGoGitCMD(testDir, tc.base)
+		if isUnexpectedErr(t, i, tc.base, nil, err) {
+			continue
+		}
+		backends := map[string]GitCMD{
+			"exec": NewGitCMDWithBase(testDir, tc.base), "go": goGitCmd}
+		for name, gitcmd := range backends {
+			output, err := gitcmd.Content(context.Background(), "math.go")
+			if isUnexpectedErr(t, i, name+" "+tc.base, nil, err) {
+				continue
+			}
+			if !bytes.Equal(tc.output, output) {
+				t.Errorf("case [%d] %s %s\nexpected %s\ngot %s", i, name, tc.base, tc.output, output)
+			}
 		}
 	}
 }
@@ -376,10 +516,11 @@ func TestCommitChangesTask(t *testing.T) {
 	cases := []struct {
 		desc            string
 		ctx             context.Context
-		in              string
+		in              *TaskResult
 		cmdErr          error
 		cmdSuccess      bool
 		setup, tearDown func() error
+		opts            func() (CommitOptions, error)
 		commitCmdLine   string
 		output          string
 		expectedErr     error
@@ -387,7 +528,7 @@ func TestCommitChangesTask(t *testing.T) {
 		{
 			desc:   "Add new file, math.go, geo.go, math_test.go",
 			ctx:    context.Background(),
-			in:     "Tests PASS: TestA$",
+			in:     NewTaskResult(TaskSuccess, "Tests PASS: TestA$"),
 			cmdErr: nil, cmdSuccess: true,
 			setup: func() error {
 				_ = ioutil.WriteFile(filePath("math.go"), mathgo, 0600)
@@ -398,11 +539,38 @@ func TestCommitChangesTask(t *testing.T) {
 				_ = gitCmdRun("add", "math.go", "math_test.go")
 				return gitCmdRun("commit", "-m", "add files")
 			},
-			commitCmdLine: "git -C /tmp/test_commit_changes_task commit -m 'auto_commit! Perimeter TestMin min sub'",
-			output:        "'auto_commit! Perimeter TestMin min sub'",
+			commitCmdLine: "git -C /tmp/test_commit_changes_task commit -m auto_commit! PI Perimeter TestMin min sub\n\n" + autoCommitTrailer,
+			output:        "auto_commit! PI Perimeter TestMin min sub\n\n" + autoCommitTrailer,
 			expectedErr:   nil,
 		},
-		// TODO add more test case
+		{
+			desc: "Template message with options and trailers",
+			ctx:  context.Background(),
+			in: func() *TaskResult {
+				res := NewTaskResult(TaskSuccess, "Tests PASS: TestMax$")
+				res.Meta[metaTests] = []string{"module.TestMax"}
+				report := NewTestsReport()
+				report.AddEvent(TestEvent{Action: "pass", Package: "module", Test: "TestMax"})
+				res.Meta[metaTestsReport] = report
+				return res
+			}(),
+			cmdErr: nil, cmdSuccess: true,
+			setup: func() error {
+				return ioutil.WriteFile(filePath("math.go"), mathgo_add_func, 0600)
+			},
+			tearDown: func() error {
+				return gitCmdRun("commit", "-am", "changes")
+			},
+			opts: func() (CommitOptions, error) {
+				return NewCommitOptions(
+					`wip({{join .Files ","}}): {{join (index .Kinds "func") " "}} passed {{.Passed}}`,
+					true, "Bot <bot@example.com>", true, []string{"Refs: #42"})
+			},
+			commitCmdLine: "git -C /tmp/test_commit_changes_task commit -S --no-verify " +
+				"--author Bot <bot@example.com> -m wip(geo.go,math.go): Perimeter max passed 1\n\n" +
+				"Tests: module.TestMax\nRefs: #42\n" + autoCommitTrailer,
+			output: "wip(geo.go,math.go): Perimeter max passed 1\n\nTests: module.TestMax\nRefs: #42\n" + autoCommitTrailer,
+		},
 	}
 	logger := log.New(os.Stdout, "gtr-test:", log.Ltime)
 	for i, tc := range cases {
@@ -410,7 +578,14 @@ func TestCommitChangesTask(t *testing.T) {
 		execTestHelper(t, i, tc.desc, tc.setup)
 		tc.ctx = context.WithValue(tc.ctx, prevTaskOutputKey, tc.in)
 		cmd := NewMockCommand(tc.cmdErr, tc.cmdSuccess)
-		output, err := CommitChanges(testDir, cmd.New)(logger, tc.ctx)
+		opts, err := NewCommitOptions("", false, "", false, nil)
+		if tc.opts != nil {
+			opts, err = tc.opts()
+		}
+		if isUnexpectedErr(t, i, tc.desc, nil, err) {
+			continue
+		}
+		output, err := CommitChanges(testDir, opts, cmd.New)(logger, tc.ctx)
 
 		// teardown()
 		execTestHelper(t, i, tc.desc, tc.tearDown)
@@ -421,7 +596,7 @@ func TestCommitChangesTask(t *testing.T) {
 		if tc.commitCmdLine != cmdLineStr {
 			t.Errorf("case [%d] %s\nexpected %# v\ngot %# v", i, tc.desc, tc.commitCmdLine, cmdLineStr)
 		}
-		if tc.output != output {
+		if tc.output != output.Summary {
 			t.Errorf("case [%d] %s\nexpected %# v\ngot %# v", i, tc.desc, tc.output, output)
 		}
 	}
@@ -4,7 +4,9 @@ go 1.13
 
 require (
 	github.com/fsnotify/fsnotify v1.4.7
+	github.com/go-git/go-git/v5 v5.1.0
 	github.com/kr/pretty v0.2.0
-	golang.org/x/sys v0.0.0-20200117145432-59e60aa80a0c // indirect
-	golang.org/x/tools v0.0.0-20200213200052-63d1300efe97
+	github.com/sergi/go-diff v1.1.0
+	golang.org/x/mod v0.9.0
+	golang.org/x/tools v0.7.0
 )
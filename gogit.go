@@ -0,0 +1,579 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/diff"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+var _ GitCMD = (*GoGitCMD)(nil)
+
+// GoGitCMD reads index, objects and working tree in process,
+// without git binary and index lock, base is same as of ExecGitCMD
+type GoGitCMD struct {
+	workDir string
+	base    string
+	repo    *git.Repository
+	root    string // working tree root
+	prefix  string // workDir relative to root, slash separated
+}
+
+// NewGoGitCMD returns in process git of repository with workDir
+func NewGoGitCMD(workDir, base string) (GitCMD, error) {
+	repo, err := git.PlainOpenWithOptions(workDir,
+		&git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, fmt.Errorf("git open %s error %v", workDir, err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("git worktree error %v", err)
+	}
+	absDir, err := filepath.Abs(workDir)
+	if err != nil {
+		return nil, err
+	}
+	root := wt.Filesystem.Root()
+	prefix, err := filepath.Rel(root, absDir)
+	if err != nil {
+		return nil, err
+	}
+	prefix = filepath.ToSlash(prefix)
+	if prefix == "." {
+		prefix = ""
+	}
+	return &GoGitCMD{
+		workDir: workDir,
+		base:    base,
+		repo:    repo,
+		root:    root,
+		prefix:  prefix,
+	}, nil
+}
+
+// WorkDir returns dir changes are relative to
+func (g *GoGitCMD) WorkDir() string {
+	return g.workDir
+}
+
+// Diff returns file changes against base, untracked
+// files are included if working tree is diffed
+func (g *GoGitCMD) Diff(ctx context.Context) ([]Change, error) {
+	if isCommitRange(g.base) || g.base == "staged" {
+		return g.diffCommitted()
+	}
+	base, err := g.baseCommit()
+	if err != nil {
+		return nil, err
+	}
+	// tracked files by blob hash before changes
+	tracked, err := g.indexFiles()
+	if err != nil {
+		return nil, err
+	}
+	oldFiles := tracked
+	if base != nil {
+		oldFiles, err = g.treeFiles(base)
+		if err != nil {
+			return nil, err
+		}
+	}
+	wt, err := g.repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("git worktree error %v", err)
+	}
+	status, err := wt.Status()
+	if err != nil {
+		return nil, fmt.Errorf("git status error %v", err)
+	}
+	var untracked []string
+	for name, st := range status {
+		if st.Worktree == git.Untracked && g.inWorkDir(name) {
+			untracked = append(untracked, name)
+		}
+	}
+	sort.Strings(untracked)
+	var results []Change
+	for _, name := range untracked {
+		fname := g.relPath(name)
+		results = append(results, Change{fname, fname, 0, 0})
+	}
+
+	var diffs []fileDiff
+	for _, name := range sortedNames(oldFiles, tracked) {
+		oldHash, inOld := oldFiles[name]
+		data, err := ioutil.ReadFile(filepath.Join(g.root, filepath.FromSlash(name)))
+		if os.IsNotExist(err) {
+			if inOld {
+				diffs = append(diffs, fileDiff{name: name, oldHash: oldHash, inOld: true})
+			}
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if inOld && oldHash == plumbing.ComputeHash(plumbing.BlobObject, data) {
+			continue
+		}
+		// new file in index or changed file
+		diffs = append(diffs, fileDiff{name: name, oldHash: oldHash,
+			inOld: inOld, inNew: true, cur: string(data)})
+	}
+	changes, err := g.fileChanges(diffs)
+	if err != nil {
+		return nil, err
+	}
+	return append(results, changes...), nil
+}
+
+// diffCommitted returns changes committed in commit
+// range or staged changes
+func (g *GoGitCMD) diffCommitted() ([]Change, error) {
+	from, err := g.baseCommit()
+	if err != nil {
+		return nil, err
+	}
+	oldFiles, err := g.treeFiles(from)
+	if err != nil {
+		return nil, err
+	}
+	var newFiles map[string]plumbing.Hash
+	if g.base == "staged" {
+		newFiles, err = g.indexFiles()
+	} else {
+		var to *object.Commit
+		_, toRev, _ := splitBase(g.base)
+		to, err = g.commit(toRev)
+		if err != nil {
+			return nil, err
+		}
+		newFiles, err = g.treeFiles(to)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var diffs []fileDiff
+	for _, name := range sortedNames(oldFiles, newFiles) {
+		oldHash, inOld := oldFiles[name]
+		newHash, inNew := newFiles[name]
+		if oldHash == newHash {
+			continue
+		}
+		d := fileDiff{name: name, oldHash: oldHash, inOld: inOld, inNew: inNew}
+		if inNew {
+			d.cur, err = g.blobContent(newHash)
+			if err != nil {
+				return nil, err
+			}
+		}
+		diffs = append(diffs, d)
+	}
+	return g.fileChanges(diffs)
+}
+
+// fileDiff is changed file, cur is content after changes
+type fileDiff struct {
+	name         string
+	oldHash      plumbing.Hash
+	inOld, inNew bool
+	cur          string
+}
+
+// fileChanges returns line changes of files in order of
+// diffs, deleted and added files with the same or similar
+// content are renames as git diff detects by default
+func (g *GoGitCMD) fileChanges(diffs []fileDiff) ([]Change, error) {
+	renames, err := g.renames(diffs)
+	if err != nil {
+		return nil, err
+	}
+	renamed := map[string]bool{}
+	for _, old := range renames {
+		renamed[old.name] = true
+	}
+	var results []Change
+	for _, d := range diffs {
+		fname := g.relPath(d.name)
+		if !d.inNew {
+			if !renamed[d.name] {
+				results = append(results, Change{fpathOld: fname})
+			}
+			continue
+		}
+		oldName, oldHash := d.name, d.oldHash
+		if old, ok := renames[d.name]; ok {
+			oldName, oldHash = old.name, old.oldHash
+		} else if !d.inOld {
+			results = append(results, lineHunks(fname, "", d.cur)...)
+			continue
+		}
+		old, err := g.blobContent(oldHash)
+		if err != nil {
+			return nil, err
+		}
+		hunks := lineHunks(fname, old, d.cur)
+		oldFname := g.relPath(oldName)
+		if len(hunks) == 0 {
+			// renamed without changes
+			hunks = []Change{{fpath: fname}}
+		}
+		for i := range hunks {
+			hunks[i].fpathOld = oldFname
+		}
+		results = append(results, hunks...)
+	}
+	return results, nil
+}
+
+// renameScore is min similarity of renamed file
+// content, git diff default is 50%
+const renameScore = 0.5
+
+// renames returns deleted files by added file name with
+// the same content or the most similar one
+func (g *GoGitCMD) renames(diffs []fileDiff) (map[string]fileDiff, error) {
+	var deleted, added []fileDiff
+	for _, d := range diffs {
+		if d.inOld && !d.inNew {
+			deleted = append(deleted, d)
+		} else if !d.inOld && d.inNew {
+			added = append(added, d)
+		}
+	}
+	out := map[string]fileDiff{}
+	if len(deleted) == 0 || len(added) == 0 {
+		return out, nil
+	}
+	used := map[string]bool{}
+	// exact renames first
+	for _, a := range added {
+		hash := plumbing.ComputeHash(plumbing.BlobObject, []byte(a.cur))
+		for _, d := range deleted {
+			if !used[d.name] && d.oldHash == hash {
+				out[a.name], used[d.name] = d, true
+				break
+			}
+		}
+	}
+	contents := map[string]string{}
+	for _, a := range added {
+		if _, ok := out[a.name]; ok {
+			continue
+		}
+		best, bestScore := -1, renameScore
+		for i, d := range deleted {
+			if used[d.name] {
+				continue
+			}
+			old, ok := contents[d.name]
+			if !ok {
+				var err error
+				old, err = g.blobContent(d.oldHash)
+				if err != nil {
+					return nil, err
+				}
+				contents[d.name] = old
+			}
+			if score := similarity(old, a.cur); score >= bestScore {
+				best, bestScore = i, score
+			}
+		}
+		if best >= 0 {
+			out[a.name], used[deleted[best].name] = deleted[best], true
+		}
+	}
+	return out, nil
+}
+
+// similarity returns share of not changed content
+// of larger version of file
+func similarity(src, dst string) float64 {
+	size := len(src)
+	if len(dst) > size {
+		size = len(dst)
+	}
+	if size == 0 {
+		return 1
+	}
+	same := 0
+	for _, d := range diff.Do(src, dst) {
+		if d.Type == diffmatchpatch.DiffEqual {
+			same += len(d.Text)
+		}
+	}
+	return float64(same) / float64(size)
+}
+
+// Show returns content of file before changes, from
+// index or from commit changes are diffed against
+func (g *GoGitCMD) Show(ctx context.Context, fpath string) ([]byte, error) {
+	name := path.Join(g.prefix, filepath.ToSlash(fpath))
+	base, err := g.baseCommit()
+	if err != nil {
+		return nil, err
+	}
+	var hash plumbing.Hash
+	if base == nil {
+		idx, err := g.repo.Storer.Index()
+		if err != nil {
+			return nil, fmt.Errorf("git index error %v", err)
+		}
+		e, err := idx.Entry(name)
+		if err != nil {
+			return nil, fmt.Errorf("git show %s error %v", fpath, err)
+		}
+		hash = e.Hash
+	} else {
+		f, err := base.File(name)
+		if err != nil {
+			return nil, fmt.Errorf("git show %s error %v", fpath, err)
+		}
+		hash = f.Hash
+	}
+	data, err := g.blobContent(hash)
+	if err != nil {
+		return nil, fmt.Errorf("git show %s error %v", fpath, err)
+	}
+	return []byte(data), nil
+}
+
+// Content returns content of file after changes, from
+// working tree, index if staged or last commit of range
+func (g *GoGitCMD) Content(ctx context.Context, fpath string) ([]byte, error) {
+	name := path.Join(g.prefix, filepath.ToSlash(fpath))
+	var hash plumbing.Hash
+	switch {
+	case g.base == "staged":
+		idx, err := g.repo.Storer.Index()
+		if err != nil {
+			return nil, fmt.Errorf("git index error %v", err)
+		}
+		e, err := idx.Entry(name)
+		if err != nil {
+			return nil, fmt.Errorf("git show %s error %v", fpath, err)
+		}
+		hash = e.Hash
+	case isCommitRange(g.base):
+		_, to, _ := splitBase(g.base)
+		c, err := g.commit(to)
+		if err != nil {
+			return nil, err
+		}
+		f, err := c.File(name)
+		if err != nil {
+			return nil, fmt.Errorf("git show %s error %v", fpath, err)
+		}
+		hash = f.Hash
+	default:
+		return ioutil.ReadFile(filepath.Join(g.workDir, fpath))
+	}
+	data, err := g.blobContent(hash)
+	if err != nil {
+		return nil, fmt.Errorf("git show %s error %v", fpath, err)
+	}
+	return []byte(data), nil
+}
+
+// baseCommit returns commit changes are diffed against,
+// nil if diffed against index
+func (g *GoGitCMD) baseCommit() (*object.Commit, error) {
+	if g.base == "" || g.base == "index" {
+		return nil, nil
+	}
+	if g.base == "staged" {
+		return g.commit("HEAD")
+	}
+	from, to, mergeBase := splitBase(g.base)
+	fromCommit, err := g.commit(from)
+	if err != nil || !mergeBase {
+		return fromCommit, err
+	}
+	toCommit, err := g.commit(to)
+	if err != nil {
+		return nil, err
+	}
+	bases, err := fromCommit.MergeBase(toCommit)
+	if err != nil {
+		return nil, fmt.Errorf("git merge-base %s %s error %v", from, to, err)
+	}
+	if len(bases) == 0 {
+		return nil, fmt.Errorf("git merge-base %s %s not found", from, to)
+	}
+	return bases[0], nil
+}
+
+// commit returns commit of revision
+func (g *GoGitCMD) commit(rev string) (*object.Commit, error) {
+	hash, err := g.repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return nil, fmt.Errorf("git resolve %s error %v", rev, err)
+	}
+	return g.repo.CommitObject(*hash)
+}
+
+// indexFiles returns blob hashes of index entries in workDir
+func (g *GoGitCMD) indexFiles() (map[string]plumbing.Hash, error) {
+	idx, err := g.repo.Storer.Index()
+	if err != nil {
+		return nil, fmt.Errorf("git index error %v", err)
+	}
+	out := map[string]plumbing.Hash{}
+	for _, e := range idx.Entries {
+		if g.inWorkDir(e.Name) {
+			out[e.Name] = e.Hash
+		}
+	}
+	return out, nil
+}
+
+// treeFiles returns blob hashes of commit files in workDir
+func (g *GoGitCMD) treeFiles(c *object.Commit) (map[string]plumbing.Hash, error) {
+	files, err := c.Files()
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]plumbing.Hash{}
+	err = files.ForEach(func(f *object.File) error {
+		if g.inWorkDir(f.Name) {
+			out[f.Name] = f.Hash
+		}
+		return nil
+	})
+	return out, err
+}
+
+// blobContent returns content of blob
+func (g *GoGitCMD) blobContent(hash plumbing.Hash) (string, error) {
+	blob, err := g.repo.BlobObject(hash)
+	if err != nil {
+		return "", err
+	}
+	r, err := blob.Reader()
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	data, err := ioutil.ReadAll(r)
+	return string(data), err
+}
+
+// inWorkDir checks if repository path is in workDir
+func (g *GoGitCMD) inWorkDir(name string) bool {
+	return g.prefix == "" || strings.HasPrefix(name, g.prefix+"/")
+}
+
+// relPath returns repository path relative to workDir
+func (g *GoGitCMD) relPath(name string) string {
+	if g.prefix != "" {
+		name = name[len(g.prefix)+1:]
+	}
+	return filepath.FromSlash(name)
+}
+
+// sortedNames returns sorted union of file names
+func sortedNames(a, b map[string]plumbing.Hash) []string {
+	set := map[string]bool{}
+	for k := range a {
+		set[k] = true
+	}
+	for k := range b {
+		set[k] = true
+	}
+	list := mapStrToSlice(set)
+	sort.Strings(list)
+	return list
+}
+
+// lineHunks returns changes of dst against src as
+// git diff -U0 hunks, start is line in dst, count of
+// one line is 0, removed lines are after start line
+func lineHunks(fpath, src, dst string) []Change {
+	var changes []Change
+	// next line in dst, first line of current hunk
+	line, start := 1, 0
+	added, inHunk := 0, false
+	flush := func() {
+		if !inHunk {
+			return
+		}
+		if added == 0 {
+			// only removed lines
+			start--
+		}
+		if added == 1 {
+			added = 0
+		}
+		changes = append(changes, Change{fpath, fpath, start, added})
+		added, inHunk = 0, false
+	}
+	for _, d := range slideDown(diff.Do(src, dst)) {
+		if d.Type == diffmatchpatch.DiffEqual {
+			flush()
+			line += lineCount(d.Text)
+			continue
+		}
+		if !inHunk {
+			start, inHunk = line, true
+		}
+		if d.Type == diffmatchpatch.DiffInsert {
+			n := lineCount(d.Text)
+			added += n
+			line += n
+		}
+	}
+	flush()
+	return changes
+}
+
+// slideDown moves inserted or removed lines down past equal
+// lines they start with, git prefers last of ambiguous hunks
+func slideDown(diffs []diffmatchpatch.Diff) []diffmatchpatch.Diff {
+	var out []diffmatchpatch.Diff
+	for i := 0; i < len(diffs); i++ {
+		d := diffs[i]
+		oneSided := len(out) == 0 || out[len(out)-1].Type == diffmatchpatch.DiffEqual
+		if d.Type != diffmatchpatch.DiffEqual && oneSided &&
+			i+1 < len(diffs) && diffs[i+1].Type == diffmatchpatch.DiffEqual {
+			next := &diffs[i+1]
+			for {
+				j := strings.IndexByte(d.Text, '\n')
+				if j < 0 || !strings.HasPrefix(next.Text, d.Text[:j+1]) {
+					break
+				}
+				first := d.Text[:j+1]
+				d.Text = d.Text[j+1:] + first
+				next.Text = next.Text[j+1:]
+				if len(out) == 0 {
+					out = append(out, diffmatchpatch.Diff{Type: diffmatchpatch.DiffEqual})
+				}
+				out[len(out)-1].Text += first
+			}
+			out = append(out, d)
+			if next.Text == "" {
+				i++
+			}
+			continue
+		}
+		out = append(out, d)
+	}
+	return out
+}
+
+// lineCount returns number of lines in text
+func lineCount(text string) int {
+	n := strings.Count(text, "\n")
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kr/pretty"
+)
+
+func TestLineHunks(t *testing.T) {
+	cases := []struct {
+		desc     string
+		src, dst string
+		output   []Change
+	}{
+		{desc: "No changes", src: "a\nb\n", dst: "a\nb\n"},
+		{
+			desc:   "New file",
+			dst:    "a\nb\n",
+			output: []Change{{"f.go", "f.go", 1, 2}},
+		},
+		{
+			desc:   "Changed line",
+			src:    "a\nb\nc\n",
+			dst:    "a\nB\nc\n",
+			output: []Change{{"f.go", "f.go", 2, 0}},
+		},
+		{
+			desc:   "Removed lines after line",
+			src:    "a\nb\nc\nd\n",
+			dst:    "a\nd\n",
+			output: []Change{{"f.go", "f.go", 1, 0}},
+		},
+		{
+			desc:   "Ambiguous insert is last",
+			src:    "}\n\nfunc a() {}\n",
+			dst:    "}\n\n\nfunc a() {}\n",
+			output: []Change{{"f.go", "f.go", 3, 0}},
+		},
+		{
+			desc:   "Last line without new line",
+			src:    "a\nb",
+			dst:    "a\nb\nc\nd",
+			output: []Change{{"f.go", "f.go", 2, 3}},
+		},
+	}
+	for i, tc := range cases {
+		output := lineHunks("f.go", tc.src, tc.dst)
+		if diffs := pretty.Diff(tc.output, output); len(diffs) > 0 {
+			t.Errorf("case [%d] %s\nexpected %# v\ngot %# v", i, tc.desc, tc.output, output)
+		}
+	}
+}
+
+func TestGoGitCMDInSubDir(t *testing.T) {
+	testDir := filepath.Join(os.TempDir(), "test_go_git_cmd_sub_dir")
+	setupTestGitDir(t, testDir,
+		map[string][]byte{
+			"main.go":                    maingo,
+			filepath.Join("pkg", "m.go"): mathgo,
+		},
+		[]string{"main.go", filepath.Join("pkg", "m.go")},
+	)
+	defer func() {
+		if !t.Failed() {
+			_ = os.RemoveAll(testDir)
+		}
+	}()
+	pkgDir := filepath.Join(testDir, "pkg")
+	steps := []func() error{
+		func() error { return ioutil.WriteFile(filepath.Join(testDir, "main.go"), mathgo, 0600) },
+		func() error { return ioutil.WriteFile(filepath.Join(pkgDir, "m.go"), mathgo_add_func, 0600) },
+		func() error { return ioutil.WriteFile(filepath.Join(pkgDir, "new.go"), geogo, 0600) },
+	}
+	for i, step := range steps {
+		execTestHelper(t, i, "setup", step)
+	}
+	gitCmd, err := NewGoGitCMD(pkgDir, "HEAD")
+	if err != nil {
+		t.Fatalf("NewGoGitCMD error %v", err)
+	}
+	output, err := gitCmd.Diff(context.Background())
+	if err != nil {
+		t.Fatalf("Diff error %v", err)
+	}
+	expected := []Change{{"new.go", "new.go", 0, 0}, {"m.go", "m.go", 15, 7}}
+	if diffs := pretty.Diff(expected, output); len(diffs) > 0 {
+		t.Errorf("expected %# v\ngot %# v", expected, output)
+	}
+	data, err := gitCmd.Show(context.Background(), "m.go")
+	if err != nil {
+		t.Fatalf("Show error %v", err)
+	}
+	if string(data) != string(mathgo) {
+		t.Errorf("expected content %s\ngot %s", mathgo, data)
+	}
+	_, err = gitCmd.Show(context.Background(), "new.go")
+	if err == nil {
+		t.Error("expected Show error of untracked file")
+	}
+}
+
+func TestGoGitCMDRenames(t *testing.T) {
+	testDir := filepath.Join(os.TempDir(), "test_go_git_cmd_renames")
+	setupTestGitDir(t, testDir,
+		map[string][]byte{"main.go": maingo, "math.go": mathgo, "geo.go": geogo},
+		[]string{"main.go", "math.go", "geo.go"},
+	)
+	defer func() {
+		if !t.Failed() {
+			_ = os.RemoveAll(testDir)
+		}
+	}()
+	gitCmdRun := NewGitCmd(testDir)
+	steps := []func() error{
+		func() error { return gitCmdRun("mv", "geo.go", "shapes.go") },
+		func() error { return gitCmdRun("mv", "math.go", "calc.go") },
+		func() error { return ioutil.WriteFile(filepath.Join(testDir, "calc.go"), mathgo_add_func, 0600) },
+		func() error { return gitCmdRun("commit", "-am", "rename files") },
+		// not similar file
+		func() error { return gitCmdRun("mv", "main.go", "app.go") },
+		func() error { return ioutil.WriteFile(filepath.Join(testDir, "app.go"), geo_add_area, 0600) },
+		func() error { return gitCmdRun("add", "app.go") },
+	}
+	for i, step := range steps {
+		execTestHelper(t, i, "setup", step)
+	}
+	cases := []struct {
+		base   string
+		output []Change
+	}{
+		{base: "HEAD~1..HEAD", output: []Change{
+			{"math.go", "calc.go", 15, 7}, {"geo.go", "shapes.go", 0, 0}}},
+		{base: "HEAD", output: []Change{
+			{"app.go", "app.go", 1, 10}, {"main.go", "", 0, 0}}},
+	}
+	for i, tc := range cases {
+		goGitCmd, err := NewGoGitCMD(testDir, tc.base)
+		if isUnexpectedErr(t, i, tc.base, nil, err) {
+			continue
+		}
+		backends := map[string]GitCMD{
+			"exec": NewGitCMDWithBase(testDir, tc.base), "go": goGitCmd}
+		for name, gitcmd := range backends {
+			output, err := gitcmd.Diff(context.Background())
+			if isUnexpectedErr(t, i, name+" "+tc.base, nil, err) {
+				continue
+			}
+			if diffs := pretty.Diff(tc.output, output); len(diffs) > 0 {
+				t.Errorf("case [%d] %s %s\nexpected %# v\ngot %# v", i, name, tc.base, tc.output, output)
+			}
+		}
+	}
+}
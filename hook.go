@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// hookMarker marks hooks installed by gtr
+const hookMarker = "# installed by gtr hook install"
+
+// hookNames are git hooks gtr installs
+var hookNames = []string{"pre-commit", "pre-push"}
+
+// zeroSha is sha of missing ref in pre-push hook input
+const zeroSha = "0000000000000000000000000000000000000000"
+
+// hook installs git hooks or runs tests of hook
+// by cfg.cmdArgs and returns exit code, osArgs are
+// passed to installed hooks
+func hook(ctx context.Context, cfg config, osArgs []string,
+	stdin io.Reader, logger *log.Logger) int {
+	if len(cfg.cmdArgs) == 0 {
+		fmt.Fprintln(os.Stderr, "hook action install or run expected")
+		return exitError
+	}
+	action, args := cfg.cmdArgs[0], cfg.cmdArgs[1:]
+	switch action {
+	case "install":
+		names := args
+		if len(names) == 0 {
+			names = hookNames
+		}
+		bin, err := os.Executable()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "hook install error %v\n", err)
+			return exitError
+		}
+		fnames, err := installHooks(ctx, cfg.workDir, bin,
+			hookFlags(osArgs, cfg.cmdArgs), names)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "hook install error %v\n", err)
+			return exitError
+		}
+		for _, fname := range fnames {
+			fmt.Println("installed", fname)
+		}
+		return exitOK
+	case "run":
+		if len(args) == 0 {
+			fmt.Fprintln(os.Stderr, "hook name expected")
+			return exitError
+		}
+		switch args[0] {
+		case "pre-commit":
+			// tests affected by changes to commit
+			cfg.base = "staged"
+			return runOnce(ctx, cfg, logger)
+		case "pre-push":
+			// remote name is first arg of hook
+			remote := "origin"
+			if len(args) > 1 {
+				remote = args[1]
+			}
+			bases, err := pushBases(ctx, cfg.workDir, remote, stdin)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "pre-push error %v\n", err)
+				return exitError
+			}
+			for _, base := range bases {
+				logger.Println("pre-push testing", base)
+				cfg.base = base
+				if code := runOnce(ctx, cfg, logger); code != exitOK {
+					return code
+				}
+			}
+			return exitOK
+		}
+		fmt.Fprintf(os.Stderr, "unknown hook %s\n", args[0])
+		return exitError
+	}
+	fmt.Fprintf(os.Stderr, "unknown hook action %s\n", action)
+	return exitError
+}
+
+// installHooks writes hooks running bin with flags to hooks
+// dir of repository and returns their paths, hooks not
+// installed by gtr are not overwritten
+func installHooks(ctx context.Context, workDir, bin string,
+	flags, names []string) ([]string, error) {
+	dir, err := gitOutput(ctx, NewOsCommand, workDir, nil, "rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return nil, err
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(workDir, dir)
+	}
+	absDir, err := filepath.Abs(workDir)
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+	var fnames []string
+	for _, name := range names {
+		if !isHookName(name) {
+			return fnames, fmt.Errorf("unknown hook %s", name)
+		}
+		fname := filepath.Join(dir, name)
+		data, err := ioutil.ReadFile(fname)
+		if err == nil && !strings.Contains(string(data), hookMarker) {
+			return fnames, fmt.Errorf("%s exists and not installed by gtr", fname)
+		}
+		err = ioutil.WriteFile(fname, []byte(hookScript(bin, absDir, name, flags)), 0700)
+		if err != nil {
+			return fnames, err
+		}
+		fnames = append(fnames, fname)
+	}
+	return fnames, nil
+}
+
+// hookScript returns shell script of hook, hook args
+// are passed before flags
+func hookScript(bin, workDir, name string, flags []string) string {
+	args := []string{shellQuote(bin), "hook", "run", name, `"$@"`,
+		"-C", shellQuote(workDir)}
+	for _, flag := range flags {
+		args = append(args, shellQuote(flag))
+	}
+	return "#!/bin/sh\n" + hookMarker + "\nexec " + strings.Join(args, " ") + "\n"
+}
+
+// hookFlags returns flags of gtr command args without
+// command, its args and -C, hooks set work dir
+func hookFlags(osArgs, cmdArgs []string) []string {
+	var flags []string
+	if len(osArgs) < 2 {
+		return nil
+	}
+	args := osArgs[2:]
+	for i := 0; i < len(args); i++ {
+		if len(cmdArgs) > 0 && args[i] == cmdArgs[0] {
+			cmdArgs = cmdArgs[1:]
+			continue
+		}
+		if args[i] == "-C" || args[i] == "--C" {
+			i++
+			continue
+		}
+		if strings.HasPrefix(args[i], "-C=") || strings.HasPrefix(args[i], "--C=") {
+			continue
+		}
+		flags = append(flags, args[i])
+	}
+	return flags
+}
+
+// pushBases returns commit ranges of refs pushed to remote
+// read from pre-push hook input, deleted refs are skipped
+func pushBases(ctx context.Context, workDir, remote string, stdin io.Reader) ([]string, error) {
+	var bases []string
+	scanner := bufio.NewScanner(stdin)
+	for scanner.Scan() {
+		// <local ref> <local sha> <remote ref> <remote sha>
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 4 || fields[1] == zeroSha {
+			continue
+		}
+		localSha, remoteSha := fields[1], fields[3]
+		if remoteSha != zeroSha {
+			bases = append(bases, remoteSha+"..."+localSha)
+			continue
+		}
+		// new remote ref, commits not on remote
+		out, err := gitOutput(ctx, NewOsCommand, workDir, nil,
+			"rev-list", "--reverse", localSha, "--not", "--remotes="+remote)
+		if err != nil {
+			return nil, err
+		}
+		if out == "" {
+			continue
+		}
+		oldest := strings.SplitN(out, "\n", 2)[0]
+		_, err = gitOutput(ctx, NewOsCommand, workDir, nil,
+			"rev-parse", "-q", "--verify", oldest+"^")
+		if err != nil {
+			return nil, fmt.Errorf("%s has no parent to diff against", oldest)
+		}
+		bases = append(bases, oldest+"^.."+localSha)
+	}
+	return bases, scanner.Err()
+}
+
+func isHookName(name string) bool {
+	for _, hook := range hookNames {
+		if name == hook {
+			return true
+		}
+	}
+	return false
+}
+
+// shellQuote quotes arg for sh
+func shellQuote(arg string) string {
+	return "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+}
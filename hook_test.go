@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInstallHooks(t *testing.T) {
+	testDir := filepath.Join(os.TempDir(), "test_install_hooks")
+	setupTestGitDir(t, testDir, map[string][]byte{"main.go": maingo}, []string{"main.go"})
+	defer func() {
+		if !t.Failed() {
+			_ = os.RemoveAll(testDir)
+		}
+	}()
+	hooksDir := filepath.Join(testDir, ".git", "hooks")
+	cases := []struct {
+		desc   string
+		names  []string
+		setup  func() error
+		fnames []string
+		err    string
+	}{
+		{
+			desc:   "Install all hooks",
+			names:  hookNames,
+			fnames: []string{filepath.Join(hooksDir, "pre-commit"), filepath.Join(hooksDir, "pre-push")},
+		},
+		{
+			desc:   "Reinstall gtr hook",
+			names:  []string{"pre-push"},
+			fnames: []string{filepath.Join(hooksDir, "pre-push")},
+		},
+		{
+			desc:  "Keep not gtr hook",
+			names: []string{"pre-commit"},
+			setup: func() error {
+				return ioutil.WriteFile(filepath.Join(hooksDir, "pre-commit"), []byte("#!/bin/sh\nexit 0\n"), 0700)
+			},
+			err: filepath.Join(hooksDir, "pre-commit") + " exists and not installed by gtr",
+		},
+		{
+			desc:  "Unknown hook",
+			names: []string{"post-merge"},
+			err:   "unknown hook post-merge",
+		},
+	}
+	for i, tc := range cases {
+		execTestHelper(t, i, tc.desc, tc.setup)
+		fnames, err := installHooks(context.Background(), testDir, "/bin/gtr",
+			[]string{"-strategy", "import", "-args", "-v"}, tc.names)
+		var errStr string
+		if err != nil {
+			errStr = err.Error()
+		}
+		if errStr != tc.err {
+			t.Errorf("case [%d] %s\nexpected error %q\ngot %q", i, tc.desc, tc.err, errStr)
+			continue
+		}
+		if !reflect.DeepEqual(tc.fnames, fnames) {
+			t.Errorf("case [%d] %s\nexpected %v\ngot %v", i, tc.desc, tc.fnames, fnames)
+		}
+		for _, fname := range fnames {
+			info, err := os.Stat(fname)
+			if err != nil || info.Mode()&0100 == 0 {
+				t.Errorf("case [%d] %s\nexpected executable %s, got %v", i, tc.desc, fname, err)
+				continue
+			}
+			data, _ := ioutil.ReadFile(fname)
+			expected := "exec '/bin/gtr' hook run " + filepath.Base(fname) + ` "$@" -C '` +
+				testDir + `' '-strategy' 'import' '-args' '-v'`
+			if !strings.Contains(string(data), expected) {
+				t.Errorf("case [%d] %s\nexpected script with %s\ngot %s", i, tc.desc, expected, data)
+			}
+		}
+	}
+}
+
+func TestHookFlags(t *testing.T) {
+	cases := []struct {
+		desc    string
+		osArgs  []string
+		cmdArgs []string
+		flags   []string
+	}{
+		{
+			desc:    "Install all",
+			osArgs:  []string{"gtr", "hook", "install"},
+			cmdArgs: []string{"install"},
+		},
+		{
+			desc: "Flags between args without work dir",
+			osArgs: []string{"gtr", "hook", "install", "-strategy", "import", "pre-push",
+				"-C", "pkg", "--C=pkg", "-args", "-v"},
+			cmdArgs: []string{"install", "pre-push"},
+			flags:   []string{"-strategy", "import", "-args", "-v"},
+		},
+	}
+	for i, tc := range cases {
+		flags := hookFlags(tc.osArgs, tc.cmdArgs)
+		if !reflect.DeepEqual(tc.flags, flags) {
+			t.Errorf("case [%d] %s\nexpected %v\ngot %v", i, tc.desc, tc.flags, flags)
+		}
+	}
+}
+
+func TestPushBases(t *testing.T) {
+	testDir := filepath.Join(os.TempDir(), "test_push_bases")
+	setupTestGitDir(t, testDir, map[string][]byte{"main.go": maingo}, []string{"main.go"})
+	defer func() {
+		if !t.Failed() {
+			_ = os.RemoveAll(testDir)
+		}
+	}()
+	gitCmdRun := NewGitCmd(testDir)
+	ctx := context.Background()
+	rev := func(name string) string {
+		out, err := gitOutput(ctx, NewOsCommand, testDir, nil, "rev-parse", name)
+		if err != nil {
+			t.Fatalf("rev-parse %s error %v", name, err)
+		}
+		return out
+	}
+	steps := []func() error{
+		func() error { return gitCmdRun("update-ref", "refs/remotes/origin/master", "HEAD") },
+		func() error { return ioutil.WriteFile(filepath.Join(testDir, "math.go"), mathgo, 0600) },
+		func() error { return gitCmdRun("add", "math.go") },
+		func() error { return gitCmdRun("commit", "-m", "add math") },
+		func() error { return ioutil.WriteFile(filepath.Join(testDir, "math.go"), mathgo_add_func, 0600) },
+		func() error { return gitCmdRun("commit", "-am", "add max") },
+	}
+	for i, step := range steps {
+		execTestHelper(t, i, "setup", step)
+	}
+	c2, c3 := rev("HEAD~1"), rev("HEAD")
+	stdin := strings.Join([]string{
+		"refs/heads/master " + c3 + " refs/heads/master " + c2,
+		"refs/heads/feature " + c3 + " refs/heads/feature " + zeroSha,
+		"(delete) " + zeroSha + " refs/heads/old " + c2,
+	}, "\n")
+	bases, err := pushBases(ctx, testDir, "origin", strings.NewReader(stdin))
+	if err != nil {
+		t.Fatalf("pushBases error %v", err)
+	}
+	expected := []string{c2 + "..." + c3, c2 + "^.." + c3}
+	if !reflect.DeepEqual(expected, bases) {
+		t.Errorf("expected %v\ngot %v", expected, bases)
+	}
+	changes, err := NewGitCMDWithBase(testDir, bases[1]).Diff(ctx)
+	if err != nil {
+		t.Fatalf("Diff error %v", err)
+	}
+	if len(changes) != 1 || changes[0].fpath != "math.go" {
+		t.Errorf("expected math.go added in pushed commits, got %v", changes)
+	}
+}
@@ -0,0 +1,448 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/go/packages"
+)
+
+// isInputFile checks if not go file may change result of
+// module tests, go.mod, go.sum, files in testdata dirs
+// and files embedded in packages
+func isInputFile(workDir, fname string) bool {
+	rel, err := filepath.Rel(workDir, fname)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	switch filepath.Base(rel) {
+	case "go.mod", "go.sum":
+		return true
+	}
+	if _, ok := testdataPkgDir(rel); ok {
+		return true
+	}
+	return len(embedBlocks(workDir, rel)) > 0
+}
+
+// inputBlocks returns blocks of go files reading changed
+// embedded and testdata files, by go file path relative
+// to workDir
+func inputBlocks(workDir string, changes []Change) map[string]FileInfo {
+	out := map[string]FileInfo{}
+	seen := map[string]bool{}
+	for _, change := range changes {
+		fname := change.fpath
+		if fname == "" {
+			fname = change.fpathOld
+		}
+		if strings.HasSuffix(fname, ".go") || seen[fname] {
+			continue
+		}
+		seen[fname] = true
+		if dir, ok := testdataPkgDir(fname); ok {
+			mergeBlocks(out, testdataBlocks(workDir, dir, fname))
+			continue
+		}
+		mergeBlocks(out, embedBlocks(workDir, fname))
+	}
+	return out
+}
+
+// mergeBlocks adds blocks of src files missing in dst
+func mergeBlocks(dst, src map[string]FileInfo) {
+	for fname, info := range src {
+		cur, ok := dst[fname]
+		if !ok {
+			dst[fname] = info
+			continue
+		}
+		for _, block := range info.blocks {
+			found := false
+			for _, b := range cur.blocks {
+				if b.typ == block.typ && b.name == block.name {
+					found = true
+					break
+				}
+			}
+			if !found {
+				cur.blocks = append(cur.blocks, block)
+			}
+		}
+		dst[fname] = cur
+	}
+}
+
+// testdataPkgDir returns dir of package which testdata
+// dir contains file, false if file is not in testdata
+func testdataPkgDir(fname string) (string, bool) {
+	parts := strings.Split(filepath.ToSlash(fname), "/")
+	for i := range parts[:len(parts)-1] {
+		if parts[i] == "testdata" {
+			return filepath.FromSlash(path.Join(append([]string{"."}, parts[:i]...)...)), true
+		}
+	}
+	return "", false
+}
+
+// testdataBlocks returns blocks of funcs in package test files
+// with string literals naming testdata file, if there is none,
+// funcs naming testdata dir are returned
+func testdataBlocks(workDir, pkgDir, fname string) map[string]FileInfo {
+	pkg, err := build.ImportDir(filepath.Join(workDir, pkgDir), 0)
+	if err != nil {
+		return nil
+	}
+	name := path.Base(filepath.ToSlash(fname))
+	namedFile := map[string]map[string]bool{}
+	namedDir := map[string]map[string]bool{}
+	for _, file := range append(pkg.TestGoFiles, pkg.XTestGoFiles...) {
+		fpath := filepath.Join(pkgDir, file)
+		f, err := parser.ParseFile(token.NewFileSet(), filepath.Join(workDir, fpath), nil, 0)
+		if err != nil {
+			continue
+		}
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Body == nil {
+				continue
+			}
+			block, ok := funcDeclBlock(fn)
+			if !ok {
+				continue
+			}
+			key := blockKey(block)
+			ast.Inspect(fn.Body, func(n ast.Node) bool {
+				lit, ok := n.(*ast.BasicLit)
+				if !ok || lit.Kind != token.STRING {
+					return true
+				}
+				val, err := strconv.Unquote(lit.Value)
+				if err != nil {
+					return true
+				}
+				if strings.Contains(val, name) {
+					addKey(namedFile, fpath, key)
+				} else if strings.Contains(val, "testdata") {
+					addKey(namedDir, fpath, key)
+				}
+				return true
+			})
+		}
+	}
+	if len(namedFile) == 0 {
+		namedFile = namedDir
+	}
+	return fileBlocksByKey(workDir, namedFile)
+}
+
+// embedBlocks returns blocks of package level vars with
+// go:embed directives matching file, by go file path
+func embedBlocks(workDir, fname string) map[string]FileInfo {
+	fname = filepath.ToSlash(fname)
+	for dir := path.Dir(fname); ; dir = path.Dir(dir) {
+		rel := strings.TrimPrefix(fname, dir+"/")
+		pkg, err := build.ImportDir(filepath.Join(workDir, filepath.FromSlash(dir)), 0)
+		if err == nil && embedMatchAny(pkg, rel) {
+			return embedVarBlocks(workDir, filepath.FromSlash(dir), pkg, rel)
+		}
+		if dir == "." {
+			return nil
+		}
+	}
+}
+
+// embedMatchAny checks if any embed pattern of package
+// or its tests matches file
+func embedMatchAny(pkg *build.Package, fname string) bool {
+	for _, patterns := range [][]string{
+		pkg.EmbedPatterns, pkg.TestEmbedPatterns, pkg.XTestEmbedPatterns,
+	} {
+		for _, pattern := range patterns {
+			if embedMatch(pattern, fname) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// embedVarBlocks returns blocks of vars which go:embed
+// directives match file relative to package dir
+func embedVarBlocks(workDir, pkgDir string, pkg *build.Package, fname string) map[string]FileInfo {
+	vars := map[string]map[string]bool{}
+	files := append(append(append([]string{}, pkg.GoFiles...), pkg.TestGoFiles...), pkg.XTestGoFiles...)
+	for _, file := range files {
+		fpath := filepath.Join(pkgDir, file)
+		f, err := parser.ParseFile(token.NewFileSet(), filepath.Join(workDir, fpath), nil, parser.ParseComments)
+		if err != nil {
+			continue
+		}
+		for _, decl := range f.Decls {
+			d, ok := decl.(*ast.GenDecl)
+			if !ok || d.Tok != token.VAR {
+				continue
+			}
+			for _, spec := range d.Specs {
+				spec := spec.(*ast.ValueSpec)
+				patterns := embedPatterns(spec.Doc)
+				if len(d.Specs) == 1 {
+					patterns = append(patterns, embedPatterns(d.Doc)...)
+				}
+				for _, pattern := range patterns {
+					if embedMatch(pattern, fname) {
+						for _, ident := range spec.Names {
+							addKey(vars, fpath, blockKey(FileBlock{typ: BlockVar, name: ident.Name}))
+						}
+						break
+					}
+				}
+			}
+		}
+	}
+	return fileBlocksByKey(workDir, vars)
+}
+
+// embedPatterns returns patterns of go:embed directives
+func embedPatterns(doc *ast.CommentGroup) []string {
+	if doc == nil {
+		return nil
+	}
+	var patterns []string
+	for _, c := range doc.List {
+		if !strings.HasPrefix(c.Text, "//go:embed ") {
+			continue
+		}
+		for _, field := range strings.Fields(strings.TrimPrefix(c.Text, "//go:embed ")) {
+			if p, err := strconv.Unquote(field); err == nil {
+				field = p
+			}
+			patterns = append(patterns, field)
+		}
+	}
+	return patterns
+}
+
+// embedMatch checks if embed pattern matches slash
+// separated file path or one of its parent dirs
+func embedMatch(pattern, fname string) bool {
+	pattern = strings.TrimPrefix(pattern, "all:")
+	for {
+		if ok, _ := path.Match(pattern, fname); ok {
+			return true
+		}
+		i := strings.LastIndexByte(fname, '/')
+		if i < 0 {
+			return false
+		}
+		fname = fname[:i]
+	}
+}
+
+func addKey(set map[string]map[string]bool, fname, key string) {
+	if set[fname] == nil {
+		set[fname] = map[string]bool{}
+	}
+	set[fname][key] = true
+}
+
+// fileBlocksByKey returns blocks of files with block keys
+func fileBlocksByKey(workDir string, keys map[string]map[string]bool) map[string]FileInfo {
+	out := map[string]FileInfo{}
+	for fname, set := range keys {
+		info, err := getFileInfo(filepath.Join(workDir, fname), nil)
+		if err != nil {
+			continue
+		}
+		blocks := info.blocks
+		info.blocks = nil
+		for _, block := range blocks {
+			if set[blockKey(block)] {
+				info.blocks = append(info.blocks, block)
+			}
+		}
+		if len(info.blocks) > 0 {
+			out[fname] = info
+		}
+	}
+	return out
+}
+
+// changedModules returns paths of modules which required
+// version, replacement or go.sum entries differ from
+// version before changes
+func changedModules(ctx context.Context, gitCmd GitCMD, changes []Change) ([]string, error) {
+	// files by if exist after changes
+	files := map[string]bool{}
+	for _, change := range changes {
+		for _, fname := range []string{change.fpath, change.fpathOld} {
+			if fname == "go.mod" || fname == "go.sum" {
+				files[fname] = files[fname] || fname == change.fpath
+			}
+		}
+	}
+	modules := map[string]bool{}
+	for fname, exists := range files {
+		// file may be new or deleted
+		old, _ := gitCmd.Show(ctx, fname)
+		var cur []byte
+		var err error
+		if exists {
+			cur, err = gitCmd.Content(ctx, fname)
+			if err != nil {
+				return nil, err
+			}
+		}
+		var oldMods, curMods map[string]string
+		if fname == "go.mod" {
+			oldMods, err = modRequirements(old)
+			if err != nil {
+				return nil, err
+			}
+			curMods, err = modRequirements(cur)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			oldMods, curMods = sumEntries(old), sumEntries(cur)
+		}
+		for mod, v := range curMods {
+			if oldMods[mod] != v {
+				modules[mod] = true
+			}
+		}
+		for mod := range oldMods {
+			if _, ok := curMods[mod]; !ok {
+				modules[mod] = true
+			}
+		}
+	}
+	out := mapStrToSlice(modules)
+	sort.Strings(out)
+	return out, nil
+}
+
+// modRequirements returns required version and
+// replacement by module path
+func modRequirements(data []byte) (map[string]string, error) {
+	f, err := modfile.Parse("go.mod", data, nil)
+	if err != nil {
+		return nil, err
+	}
+	mods := map[string]string{}
+	for _, r := range f.Require {
+		mods[r.Mod.Path] = r.Mod.Version
+	}
+	for _, r := range f.Replace {
+		mods[r.Old.Path] += " " + r.Old.String() + " => " + r.New.String()
+	}
+	return mods, nil
+}
+
+// sumEntries returns sorted go.sum lines by module path
+func sumEntries(data []byte) map[string]string {
+	lines := map[string][]string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		lines[fields[0]] = append(lines[fields[0]], strings.Join(fields, " "))
+	}
+	mods := map[string]string{}
+	for mod, list := range lines {
+		sort.Strings(list)
+		mods[mod] = strings.Join(list, "\n")
+	}
+	return mods
+}
+
+// modImporters returns module packages which import packages
+// of modules directly or through dependencies, with shortest
+// import path from package to package of module
+func modImporters(ctx context.Context, dir, moduleName string, modules []string) (
+	map[string][]string, error) {
+	if len(modules) == 0 {
+		return nil, nil
+	}
+	cfg := &packages.Config{
+		Context: ctx,
+		Dir:     dir,
+		Mode: packages.NeedName |
+			packages.NeedImports |
+			packages.NeedDeps,
+		Tests: true,
+	}
+	pkgs, err := packages.Load(cfg, "./...")
+	if err != nil {
+		return nil, err
+	}
+	for i := range pkgs {
+		if len(pkgs[i].Errors) > 0 {
+			if ctx.Err() != nil {
+				return nil, errors.New("task canceled")
+			}
+			return nil, errors.New("packages.Load error")
+		}
+	}
+	inModules := func(pkgPath string) bool {
+		for _, mod := range modules {
+			if pkgPath == mod || strings.HasPrefix(pkgPath, mod+"/") {
+				return true
+			}
+		}
+		return false
+	}
+	paths := map[*packages.Package][]string{}
+	var importPath func(pkg *packages.Package) []string
+	importPath = func(pkg *packages.Package) []string {
+		if p, ok := paths[pkg]; ok {
+			return p
+		}
+		paths[pkg] = nil
+		if inModules(pkg.PkgPath) {
+			paths[pkg] = []string{pkg.PkgPath}
+			return paths[pkg]
+		}
+		var imports []string
+		for imp := range pkg.Imports {
+			imports = append(imports, imp)
+		}
+		sort.Strings(imports)
+		var shortest []string
+		for _, imp := range imports {
+			p := importPath(pkg.Imports[imp])
+			if p != nil && (shortest == nil || len(p)+1 < len(shortest)) {
+				shortest = append([]string{pkg.PkgPath}, p...)
+			}
+		}
+		paths[pkg] = shortest
+		return shortest
+	}
+	out := map[string][]string{}
+	for _, pkg := range pkgs {
+		if !(pkg.PkgPath == moduleName || strings.HasPrefix(pkg.PkgPath, moduleName+"/")) ||
+			strings.HasSuffix(pkg.PkgPath, ".test") {
+			continue
+		}
+		p := importPath(pkg)
+		if p == nil {
+			continue
+		}
+		root := testRootPkg(pkg.PkgPath)
+		if prev, ok := out[root]; !ok || len(p) < len(prev) {
+			out[root] = p
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestInputBlocks(t *testing.T) {
+	testDir := filepath.Join(os.TempDir(), "test_input_blocks")
+	files := map[string][]byte{
+		"go.mod": []byte(`module input-blocks
+
+go 1.16
+`),
+		filepath.Join("pkga", "f.go"): []byte(`package pkga
+
+import "embed"
+
+//go:embed static/*.txt
+var static embed.FS
+
+var (
+	//go:embed "version.txt"
+	version string
+	name    = "pkga"
+)
+
+func Version() string {
+	return version
+}
+`),
+		filepath.Join("pkga", "f_test.go"): []byte(`package pkga
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+	_, _ = ioutil.ReadFile("testdata/in.json")
+}
+
+func TestGolden(t *testing.T) {
+	for _, name := range []string{"a", "b"} {
+		_, _ = ioutil.ReadFile(filepath.Join("testdata", name+".golden"))
+	}
+}
+`),
+	}
+	_ = os.RemoveAll(testDir)
+	for fname, data := range files {
+		err := os.MkdirAll(filepath.Join(testDir, filepath.Dir(fname)), 0700)
+		if err != nil {
+			t.Fatalf("setup MkdirAll error %s", err)
+		}
+		err = ioutil.WriteFile(filepath.Join(testDir, fname), data, 0600)
+		if err != nil {
+			t.Fatalf("setup WriteFile error %s", err)
+		}
+	}
+	defer func() {
+		if !t.Failed() {
+			_ = os.RemoveAll(testDir)
+		}
+	}()
+	cases := []struct {
+		desc     string
+		fname    string
+		input    bool
+		outNames map[string][]string
+	}{
+		{
+			desc:     "Embedded by pattern",
+			fname:    filepath.Join("pkga", "static", "a.txt"),
+			input:    true,
+			outNames: map[string][]string{filepath.Join("pkga", "f.go"): {"static"}},
+		},
+		{
+			desc:     "Embedded by quoted name in var group",
+			fname:    filepath.Join("pkga", "version.txt"),
+			input:    true,
+			outNames: map[string][]string{filepath.Join("pkga", "f.go"): {"version"}},
+		},
+		{
+			desc:     "Testdata file named in test",
+			fname:    filepath.Join("pkga", "testdata", "in.json"),
+			input:    true,
+			outNames: map[string][]string{filepath.Join("pkga", "f_test.go"): {"TestRead"}},
+		},
+		{
+			desc:  "Testdata file not named in tests",
+			fname: filepath.Join("pkga", "testdata", "a.golden"),
+			input: true,
+			outNames: map[string][]string{
+				filepath.Join("pkga", "f_test.go"): {"TestRead", "TestGolden"}},
+		},
+		{
+			desc:  "Not embedded file",
+			fname: filepath.Join("pkga", "static", "a.md"),
+		},
+		{
+			desc:  "go.sum",
+			fname: "go.sum",
+			input: true,
+		},
+	}
+	for i, tc := range cases {
+		if input := isInputFile(testDir, filepath.Join(testDir, tc.fname)); input != tc.input {
+			t.Errorf("case [%d] %s\nexpected input file %v, got %v", i, tc.desc, tc.input, input)
+		}
+		var outNames map[string][]string
+		for fname, info := range inputBlocks(testDir, []Change{{tc.fname, tc.fname, 0, 0}}) {
+			if outNames == nil {
+				outNames = map[string][]string{}
+			}
+			for _, block := range info.blocks {
+				outNames[fname] = append(outNames[fname], block.name)
+			}
+		}
+		if !reflect.DeepEqual(tc.outNames, outNames) {
+			t.Errorf("case [%d] %s\nexpected blocks %v\ngot %v", i, tc.desc, tc.outNames, outNames)
+		}
+	}
+}
+
+func TestChangedModules(t *testing.T) {
+	testDir := filepath.Join(os.TempDir(), "test_changed_modules")
+	gitCmdRun := NewGitCmd(testDir)
+	files := map[string][]byte{
+		"go.mod": []byte(`module changed-modules
+
+go 1.13
+
+require (
+	example.com/dep v0.1.0
+	example.com/other v0.1.0
+)
+
+replace (
+	example.com/dep => ./dep
+	example.com/other => ./other
+)
+`),
+		"go.sum": []byte(`example.com/other v0.1.0 h1:abc=
+example.com/other v0.1.0/go.mod h1:def=
+`),
+		"f.go": []byte(`package main
+
+import "example.com/dep"
+
+func main() {
+	dep.F()
+}
+`),
+		filepath.Join("pkga", "f.go"): []byte(`package pkga
+
+import "changed-modules/pkgb"
+
+func F() int {
+	return pkgb.F()
+}
+`),
+		filepath.Join("pkga", "f_test.go"): []byte(`package pkga
+
+import "testing"
+
+func TestF(t *testing.T) {
+	F()
+}
+`),
+		filepath.Join("pkgb", "f.go"): []byte(`package pkgb
+
+import "example.com/dep/sub"
+
+func F() int {
+	return sub.F()
+}
+`),
+		filepath.Join("dep", "go.mod"): []byte(`module example.com/dep
+
+go 1.13
+`),
+		filepath.Join("other", "go.mod"): []byte(`module example.com/other
+
+go 1.13
+`),
+		filepath.Join("dep", "f.go"): []byte(`package dep
+
+func F() {}
+`),
+		filepath.Join("dep", "sub", "f.go"): []byte(`package sub
+
+func F() int { return 1 }
+`),
+	}
+	var fnames []string
+	for fname := range files {
+		fnames = append(fnames, fname)
+	}
+	setupTestGitDir(t, testDir, files, fnames)
+	defer func() {
+		if !t.Failed() {
+			_ = os.RemoveAll(testDir)
+		}
+	}()
+	cases := []struct {
+		desc      string
+		fname     string
+		data      []byte
+		modules   []string
+		importers map[string][]string
+	}{
+		{desc: "No changes"},
+		{
+			desc:  "Update go.sum",
+			fname: "go.sum",
+			data: []byte(`example.com/other v0.1.0/go.mod h1:def=
+example.com/other v0.1.0 h1:abcd=
+`),
+			modules: []string{"example.com/other"},
+		},
+		{
+			desc:  "Update replace and require in go.mod",
+			fname: "go.mod",
+			data: []byte(`module changed-modules
+
+go 1.13
+
+require (
+	example.com/dep v0.1.0
+	example.com/other v0.2.0
+)
+
+replace (
+	// local copy
+	example.com/dep => ./dep/
+	example.com/other => ./other
+)
+`),
+			modules: []string{"example.com/dep", "example.com/other"},
+			importers: map[string][]string{
+				"changed-modules":      {"changed-modules", "example.com/dep"},
+				"changed-modules/pkga": {"changed-modules/pkga", "changed-modules/pkgb", "example.com/dep/sub"},
+				"changed-modules/pkgb": {"changed-modules/pkgb", "example.com/dep/sub"},
+			},
+		},
+	}
+	gitCmd := NewGitCMD(testDir)
+	for i, tc := range cases {
+		if tc.fname != "" {
+			execTestHelper(t, i, tc.desc, func() error {
+				return ioutil.WriteFile(filepath.Join(testDir, tc.fname), tc.data, 0600)
+			})
+		}
+		changes, err := gitCmd.Diff(context.Background())
+		if isUnexpectedErr(t, i, tc.desc, nil, err) {
+			continue
+		}
+		modules, err := changedModules(context.Background(), gitCmd, changes)
+		if isUnexpectedErr(t, i, tc.desc, nil, err) {
+			continue
+		}
+		if !reflect.DeepEqual(tc.modules, modules) {
+			t.Errorf("case [%d] %s\nexpected modules %v\ngot %v", i, tc.desc, tc.modules, modules)
+		}
+		if tc.importers != nil {
+			// other module is not required by packages
+			importers, err := modImporters(context.Background(), testDir, "changed-modules", modules)
+			if isUnexpectedErr(t, i, tc.desc, nil, err) {
+				continue
+			}
+			if !reflect.DeepEqual(tc.importers, importers) {
+				t.Errorf("case [%d] %s\nexpected importers %v\ngot %v", i, tc.desc, tc.importers, importers)
+			}
+		}
+		execTestHelper(t, i, tc.desc, func() error {
+			return gitCmdRun("commit", "-am", "commit changes", "--allow-empty")
+		})
+	}
+}
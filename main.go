@@ -1,26 +1,48 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
 	"runtime"
+	"time"
 )
 
+// timeout of desktop notification task
+const notifyTimeout = 5 * time.Second
+
 func main() {
+	if analysis := os.Getenv(analysisEnv); analysis != "" {
+		// analysis process of ssa strategy
+		os.Exit(runAnalysis(context.Background(), analysis, os.Args[1:]))
+	}
 	cfg, err := parseFlags(os.Args)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 	logger := log.New(os.Stdout, "gtr: ", 0)
-	var strategy Strategy
-	if cfg.strategy == "coverage" {
-		strategy = NewCoverStrategy(cfg.runInit, cfg.workDir, logger)
-	} else {
-		strategy = NewSSAStrategy(cfg.analysis, cfg.workDir, logger)
+	switch cfg.command {
+	case "run":
+		os.Exit(runOnce(context.Background(), cfg, logger))
+	case "affected":
+		os.Exit(affected(context.Background(), cfg, logger))
+	case "explain":
+		os.Exit(explain(context.Background(), cfg, logger))
+	case "snapshots":
+		os.Exit(snapshots(context.Background(), cfg, os.Stdout))
+	case "squash":
+		os.Exit(squash(context.Background(), cfg, os.Stdout))
+	case "hook":
+		os.Exit(hook(context.Background(), cfg, os.Args, os.Stdin, logger))
 	}
-
+	gitCmd, err := newGitCMD(cfg)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	strategy := newStrategy(cfg, gitCmd, logger)
 	notifier := NewDesktopNotificator(true, 2000)
 	testRunner := NewGoTestRunner(
 		strategy,
@@ -28,17 +50,51 @@ func main() {
 		cfg.argsToTestBinary,
 		logger,
 	)
-	tasks := []Task{testRunner, notifier}
+	// notify tests results and
+	// on pass commit changes and notify
+	tasks := []PipelineTask{
+		{Task: testRunner},
+		{ID: "NotifyTests", Task: notifier, DependsOn: []string{testRunner.ID()},
+			When: Always, Timeout: notifyTimeout},
+	}
 	if cfg.autoCommit {
-		autoCommitTask := NewTask("AutoCommit",
-			CommitChanges(cfg.workDir, NewOsCommand),
+		opts, err := NewCommitOptions(cfg.commitTemplate, cfg.commitSign,
+			cfg.commitAuthor, cfg.commitNoVerify, cfg.commitTrailers)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		commit := CommitChanges(cfg.workDir, opts, NewOsCommand)
+		if cfg.autoCommitMode == "wip" {
+			commit = CommitSnapshot(cfg.workDir, NewOsCommand)
+		}
+		autoCommitTask := NewTask("AutoCommit", commit, logger)
+		tasks = append(tasks,
+			PipelineTask{Task: autoCommitTask, DependsOn: []string{testRunner.ID()},
+				When: OnSuccess},
+			PipelineTask{ID: "NotifyCommit", Task: notifier, DependsOn: []string{autoCommitTask.ID()},
+				When: Always, Timeout: notifyTimeout},
+		)
+	}
+	if cfg.autoRevert {
+		revertTask := NewTask("AutoRevert",
+			RevertChanges(cfg.workDir, NewOsCommand),
 			logger)
-		tasks = append(tasks, autoCommitTask)
-		tasks = append(tasks, notifier)
+		tasks = append(tasks,
+			PipelineTask{Task: revertTask, DependsOn: []string{testRunner.ID()},
+				When: OnFailure},
+			PipelineTask{ID: "NotifyRevert", Task: notifier, DependsOn: []string{revertTask.ID()},
+				When: OnSuccess, Timeout: notifyTimeout},
+		)
+	}
+	pipeline, err := NewPipeline(true, tasks...)
+	if err != nil {
+		fmt.Printf("NewPipeline error %+v\n", err) // output for debug
+		os.Exit(1)
 	}
 	watcher, err := NewWatcher(
 		cfg.workDir,
-		tasks,
+		pipeline,
 		cfg.delay,
 		cfg.excludeFilePrefix,
 		cfg.excludeDirs,
@@ -48,6 +104,8 @@ func main() {
 		fmt.Printf("NewWatcher error %+v\n", err) // output for debug
 		os.Exit(1)
 	}
+	// in process git does not take index lock
+	watcher.SetGitLockWait(cfg.git == "exec")
 	// limit cpu usage
 	runtime.GOMAXPROCS(runtime.NumCPU() / 2)
 	err = watcher.Run()
@@ -57,39 +115,156 @@ func main() {
 	}
 }
 
+// newGitCMD returns git backend configured by cfg
+func newGitCMD(cfg config) (GitCMD, error) {
+	if cfg.git == "go" {
+		return NewGoGitCMD(cfg.workDir, cfg.base)
+	}
+	return NewGitCMDWithBase(cfg.workDir, cfg.base), nil
+}
+
+// newStrategy returns strategy configured by cfg
+func newStrategy(cfg config, gitCmd GitCMD, logger *log.Logger) Strategy {
+	var strategy interface {
+		Strategy
+		Explainer
+	}
+	switch cfg.strategy {
+	case "coverage":
+		strategy = NewCoverStrategy(cfg.runInit, cfg.workDir, gitCmd, logger)
+	case "hybrid":
+		strategy = NewHybridStrategy(cfg.hybridMode == "intersect",
+			NewCoverStrategy(cfg.runInit, cfg.workDir, gitCmd, logger),
+			newSSAStrategy(cfg, gitCmd, logger),
+			logger)
+	case "import":
+		strategy = NewImportGraphStrategy(cfg.workDir, gitCmd, logger)
+	default:
+		strategy = newSSAStrategy(cfg, gitCmd, logger)
+	}
+	strategy.SetExplain(cfg.explain)
+	return strategy
+}
+
+// newSSAStrategy returns analysis strategy with
+// import graph strategy as fallback when
+// all analyses exceed budget
+func newSSAStrategy(cfg config, gitCmd GitCMD, logger *log.Logger) *SSAStrategy {
+	ss := NewSSAStrategy(cfg.analysis, cfg.workDir, gitCmd, logger)
+	ss.SetFallback(NewImportGraphStrategy(cfg.workDir, gitCmd, logger))
+	ss.SetBudget(cfg.analysisBudget, cfg.analysisMemory<<20)
+	return ss
+}
+
 type config struct {
+	command           string // command to run, watch if empty
 	workDir           string
 	delay             int
 	strategy          string
+	hybridMode        string // union or intersect tests of hybrid strategy
 	analysis          string
-	runInit           bool // run init in strategies
+	analysisBudget    time.Duration // downgrade analysis after
+	analysisMemory    uint64        // downgrade analysis over heap MB
+	runInit           bool          // run init in strategies
 	excludeFilePrefix []string
 	excludeDirs       []string
 	autoCommit        bool
+	autoCommitMode    string // commit to checked out branch or wip ref
+	autoRevert        bool   // revert working tree on tests failure
+	commitTemplate    string // text/template of auto commit message
+	commitSign        bool
+	commitAuthor      string
+	commitNoVerify    bool
+	commitTrailers    []string
 	argsToTestBinary  string
+	base              string // index, git ref or commit range to diff against
+	git               string // git backend exec or go
+	format            string // output format text or json
+	explain           bool   // record why tests are selected
+	cmdArgs           []string
 }
 
 func flagUsage() string {
 	return `
 Usage of gtr:
+  gtr [flags]
+        watch file changes and run affected tests
+  gtr run [flags]
+        run tests affected by changes once and exit
+        with non zero status if tests fail
+  gtr affected [flags]
+        list tests affected by changes without running them
+  gtr explain [flags] [test...]
+        show why tests are selected or not, all selected tests
+        if none provided
+  gtr hook install [pre-commit|pre-push] [flags]
+        install git hooks running affected tests with flags, pre-commit tests
+        staged changes, pre-push tests pushed commits, both by default
+  gtr squash [base]
+        squash auto commits since last manual commit or base into one
+        commit with changed funcs, types and passed tests, refuses if
+        base..HEAD has other commits
+  gtr snapshots [list|diff|restore] [snapshot]
+        list snapshots committed with -auto-commit-mode wip, diff
+        snapshot against working tree or restore its files, snapshot
+        is number in list or commit (default latest)
+
   -C string
         directory to watch (default ".")
   -strategy string
-        strategy analysis or coverage (default analysis)
+        strategy analysis, coverage, hybrid or import (default analysis)
+  -hybrid-mode string
+        union or intersect tests selected by coverage and analysis in hybrid strategy (default union)
   -analysis string
-        source code analysis to use pointer, static, rta, cha (default pointer)
+        source code analysis to use pointer, static, rta, cha, vta (default pointer)
+  -analysis-budget duration
+        time to wait for each analysis before downgrading pointer or vta to rta,
+        then cha and then selecting tests by package imports, 0 to always wait (default 30s)
+  -analysis-memory int
+        heap size in MB analysis may use before downgrading, 0 is unlimited (default 0)
   -run-init bool
         runs init steps like on first run get coverage for all tests on coverage strategy (default true)
   -args string
     	args to the test binary
   -auto-commit bool
     	auto commit on tests pass (default false)
+  -auto-revert bool
+    	on tests failure revert working tree to latest wip snapshot, requires
+    	-auto-commit-mode wip, reverted changes are saved as patch in .gtr/rejected
+    	(default false)
+  -commit-template string
+    	text/template of auto commit message with .Files, .Blocks, .Kinds (block
+    	names by func, method, type, var, const, init), .Tests, .Passed, .Failed,
+    	.Skipped, .Duration and join func (default "auto_commit! {{join .Blocks " "}}")
+  -commit-sign bool
+    	gpg sign auto commits (default false)
+  -commit-author string
+    	author of auto commits, "Name <email>"
+  -commit-no-verify bool
+    	skip pre-commit and commit-msg hooks on auto commits (default false)
+  -commit-trailer string
+    	trailer added to auto commit message, e.g. "Refs: #42", repeatable
+  -auto-commit-mode string
+    	branch commits to checked out branch, wip commits working tree snapshot
+    	to refs/gtr/wip/<branch> without changing index and branch (default branch)
   -delay int
     	delay in Milliseconds (default 1000)
   -exclude-dirs string
     	prefixes to exclude sep by comma (default "vendor,node_modules")
   -exclude-file-prefix string
     	prefixes to exclude sep by comma (default "#")
+  -base string
+    	index, staged, git ref or commit range to diff against, with ref working tree
+    	is diffed against merge base of ref and HEAD, e.g. HEAD for staged and not staged
+    	changes or main for all changes of branch, staged diffs index against HEAD, with
+    	A..B or A...B only committed changes are used (default index, not staged changes)
+  -git string
+    	git backend, exec runs git binary, go reads repository in process
+    	without git binary and index lock (default exec)
+  -format string
+    	output format of affected command text or json (default text)
+  -explain bool
+    	print callgraph path or cover block which selected each test (default false)
 `
 }
 
@@ -98,11 +273,17 @@ func newConfig() config {
 		workDir:           ".",
 		delay:             1000,
 		strategy:          "analysis",
+		hybridMode:        "union",
 		runInit:           true,
 		analysis:          "pointer",
+		analysisBudget:    30 * time.Second,
 		excludeFilePrefix: []string{"#"},
 		excludeDirs:       []string{"vendor", "node_modules"},
 		autoCommit:        false,
+		autoCommitMode:    "branch",
+		autoRevert:        false,
 		argsToTestBinary:  "",
+		format:            "text",
+		git:               "exec",
 	}
 }
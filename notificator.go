@@ -30,25 +30,35 @@ func (n *DesktopNotificator) ID() string {
 }
 
 // Run implements Task interface
-func (n *DesktopNotificator) Run(ctx context.Context) (string, error) {
-	in := ctx.Value(prevTaskOutputKey).(string)
-	return in, n.Send(ctx, in)
+// notifies with summary of the previous task result
+func (n *DesktopNotificator) Run(ctx context.Context) (*TaskResult, error) {
+	in := prevTaskResult(ctx)
+	if in == nil {
+		return NewTaskResult(TaskSkipped, "nothing to notify"), nil
+	}
+	title := "gtr: " + in.Status.String()
+	err := n.Send(ctx, title, in.Summary)
+	if err != nil {
+		return nil, err
+	}
+	// pass previous result to the next task
+	return in, nil
 }
 
 // Send desktop notification
-func (n *DesktopNotificator) Send(ctx context.Context, msg string) error {
+func (n *DesktopNotificator) Send(ctx context.Context, title, msg string) error {
 	var cmd *exec.Cmd
 	switch runtime.GOOS {
 	case "darwin":
 		cmd = exec.CommandContext(ctx, "osascript", "-e",
-			fmt.Sprintf("display notification \"%s\" with title \"%s\"", msg, msg))
+			fmt.Sprintf("display notification \"%s\" with title \"%s\"", msg, title))
 
 	case "linux":
 		cmd = exec.CommandContext(ctx, "notify-send", "-t", n.expireTime)
 		if n.transient {
 			cmd.Args = append(cmd.Args, []string{"--hint", "int:transient:1"}...)
 		}
-		cmd.Args = append(cmd.Args, msg)
+		cmd.Args = append(cmd.Args, title, msg)
 	default:
 		return fmt.Errorf("unsupported os %s", runtime.GOOS)
 	}
@@ -23,7 +23,8 @@ func TestDesktopNotificator(t *testing.T) {
 
 	// Task should be cancelable
 	ctx, _ := context.WithDeadline(context.Background(), time.Now())
-	ctx = context.WithValue(ctx, prevTaskOutputKey, "prev task msg")
+	ctx = context.WithValue(ctx, prevTaskOutputKey,
+		NewTaskResult(TaskSuccess, "prev task msg"))
 	_, err := notifier.Run(ctx)
 	if err == nil {
 		t.Error("expected not nil error")
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// exit codes of one-shot commands
+const (
+	exitOK          = 0
+	exitTestsFailed = 1
+	exitError       = 2
+)
+
+// runOnce runs tests affected by changes against
+// cfg.base and returns exit code
+func runOnce(ctx context.Context, cfg config, logger *log.Logger) int {
+	gitCmd, err := newGitCMD(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "git error %v\n", err)
+		return exitError
+	}
+	testRunner := NewGoTestRunner(
+		newStrategy(cfg, gitCmd, logger),
+		NewOsCommand,
+		cfg.argsToTestBinary,
+		logger,
+	)
+	return runTask(ctx, testRunner, os.Stdout)
+}
+
+// runTask runs test task, prints summary to out
+// and returns exit code
+func runTask(ctx context.Context, task Task, out io.Writer) int {
+	res, err := task.Run(ctx)
+	if err != nil {
+		fmt.Fprintf(out, "%s error %v\n", task.ID(), err)
+		return exitError
+	}
+	printSummary(out, res)
+	switch res.Status {
+	case TaskSuccess, TaskSkipped:
+		return exitOK
+	case TaskFailure:
+		return exitTestsFailed
+	}
+	return exitError
+}
+
+// printSummary prints tests results
+func printSummary(out io.Writer, res *TaskResult) {
+	fmt.Fprintln(out, "=============")
+	fmt.Fprintln(out, res.Summary)
+	report := res.TestsReport()
+	if report != nil {
+		fmt.Fprintf(out, "passed %d, failed %d, skipped %d, build failed %d in %s\n",
+			len(report.Passed()), len(report.Failed()), len(report.Skipped()),
+			len(report.BuildFailed()), res.Duration.Round(time.Millisecond))
+		for _, pkg := range report.BuildFailed() {
+			fmt.Fprintf(out, "BUILD FAIL %s\n", pkg.Name)
+		}
+		for _, test := range report.Failed() {
+			fmt.Fprintf(out, "FAIL %s.%s (%s)\n", test.Package, test.Name, test.Elapsed)
+		}
+	}
+	fmt.Fprintln(out, "=============")
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRunTask(t *testing.T) {
+	cases := []struct {
+		desc        string
+		strategyErr error
+		cmdSuccess  bool
+		tests       []string
+		cmdOutput   string
+		exitCode    int
+		output      []string
+	}{
+		{
+			desc:       "No tests to run",
+			cmdSuccess: true,
+			exitCode:   exitOK,
+			output:     []string{"No test found to run"},
+		},
+		{
+			desc:       "Tests pass",
+			cmdSuccess: true,
+			tests:      []string{"module.TestA"},
+			cmdOutput: `{"Action":"run","Package":"module","Test":"TestA"}
+{"Action":"pass","Package":"module","Test":"TestA","Elapsed":0.01}
+`,
+			exitCode: exitOK,
+			output: []string{"Tests PASS: TestA$",
+				"passed 1, failed 0, skipped 0, build failed 0"},
+		},
+		{
+			desc:       "Tests fail",
+			cmdSuccess: false,
+			tests:      []string{"module.TestA", "module.TestB"},
+			cmdOutput: `{"Action":"run","Package":"module","Test":"TestA"}
+{"Action":"pass","Package":"module","Test":"TestA","Elapsed":0.01}
+{"Action":"run","Package":"module","Test":"TestB"}
+{"Action":"fail","Package":"module","Test":"TestB","Elapsed":0.5}
+`,
+			exitCode: exitTestsFailed,
+			output: []string{"Tests FAIL: TestB",
+				"passed 1, failed 1, skipped 0, build failed 0",
+				"FAIL module.TestB (500ms)"},
+		},
+		{
+			desc:        "Strategy error",
+			strategyErr: errors.New("git diff error"),
+			exitCode:    exitError,
+			output:      []string{"GoTestRunner error strategy error git diff error"},
+		},
+	}
+	logger := log.New(os.Stdout, "gtr-test:", log.Ltime)
+	for i, tc := range cases {
+		ds := &dummyStrategy{runAll: true, tests: tc.tests, err: tc.strategyErr}
+		mockCmd := NewMockCommand(nil, tc.cmdSuccess)
+		mockCmd.output = []byte(tc.cmdOutput)
+		var out bytes.Buffer
+		code := runTask(context.Background(),
+			NewGoTestRunner(ds, mockCmd.New, "", logger), &out)
+		if code != tc.exitCode {
+			t.Errorf("case [%d] %s\nexpected exit code %d, got %d", i, tc.desc, tc.exitCode, code)
+		}
+		for _, line := range tc.output {
+			if !strings.Contains(out.String(), line) {
+				t.Errorf("case [%d] %s\nexpected output %q\ngot %q", i, tc.desc, line, out.String())
+			}
+		}
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/kr/pretty"
 )
@@ -22,7 +23,7 @@ import (
 // Change of file lines
 type Change struct {
 	fpathOld string // a/
-	fpath    string // b/ current
+	fpath    string // b/ current, empty if file deleted
 	start    int
 	count    int
 }
@@ -68,16 +69,23 @@ func changesFromGitDiff(diff bytes.Buffer) ([]Change, error) {
 		}
 		return string(line[start:i]), i
 	}
+	// readFileNames reads paths of diff --git header, paths
+	// with spaces are not quoted, not renamed file has equal
+	// paths, paths of renamed file are read from rename lines
 	readFileNames := func() (a string, b string) {
-		var prev rune
-		for i := 1; i < len(line); i++ {
-			prev = line[i-1]
-			if prev == 'a' && line[i] == '/' {
-				a, i = readTokenInLineAt(i + 1)
-			} else if prev == 'b' && line[i] == '/' {
-				b, i = readTokenInLineAt(i + 1)
+		names := strings.TrimPrefix(string(line), "diff --git ")
+		if !strings.HasPrefix(names, "a/") {
+			return
+		}
+		if n := len(names); n%2 == 1 {
+			half := (n - 1) / 2
+			if names[half:half+3] == " b/" && names[2:half] == names[half+3:] {
+				return names[2:half], names[half+3:]
 			}
 		}
+		if idx := strings.Index(names, " b/"); idx > -1 {
+			return names[2:idx], names[idx+3:]
+		}
 		return
 	}
 	readStartLineAndCount := func() (d1, d2 int) {
@@ -101,12 +109,16 @@ func changesFromGitDiff(diff bytes.Buffer) ([]Change, error) {
 						return
 					}
 				}
+				// skip func context after range
+				return
 			}
 		}
 		return
 	}
 	var r rune
 	var f1, f2 string
+	// renamed file without changes has no hunks
+	renamed := false
 	for {
 		r, _, serr = diff.ReadRune()
 		if serr != nil {
@@ -123,13 +135,24 @@ func changesFromGitDiff(diff bytes.Buffer) ([]Change, error) {
 		}
 		consumeLine()
 		if line[0] == 'd' && line[1] == 'e' { // deleted
-			// skip deleted files
+			// deleted file has only old path
+			changes = append(changes, Change{fpathOld: f1})
 			f1, f2 = "", ""
 			continue
 		}
 		if line[0] == 'd' {
+			if renamed {
+				changes = append(changes, Change{f1, f2, 0, 0})
+				renamed = false
+			}
 			f1, f2 = readFileNames()
+		} else if strings.HasPrefix(string(line), "rename from ") {
+			f1 = strings.TrimPrefix(string(line), "rename from ")
+		} else if strings.HasPrefix(string(line), "rename to ") {
+			f2 = strings.TrimPrefix(string(line), "rename to ")
+			renamed = true
 		} else if line[0] == '@' && f2 != "" {
+			renamed = false
 			d1, d2 := readStartLineAndCount()
 			changes = append(changes, Change{f1, f2, d1, d2})
 		}
@@ -138,6 +161,9 @@ func changesFromGitDiff(diff bytes.Buffer) ([]Change, error) {
 	if serr == io.EOF {
 		serr = nil
 	}
+	if renamed {
+		changes = append(changes, Change{f1, f2, 0, 0})
+	}
 
 	return changes, serr
 }
@@ -152,7 +178,8 @@ type FileInfo struct {
 }
 
 // FileBlock defines blocks of entities
-// in a file, func/method [start, end] line
+// in a file, func/method body or var/const
+// spec [start, end] line
 type FileBlock struct {
 	typ        BlockKind
 	name       string
@@ -163,12 +190,18 @@ type FileBlock struct {
 type BlockKind uint32
 
 const (
-	// BlockType Type definition
+	// BlockType Type definition or alias
 	BlockType BlockKind = 1 << iota
 	// BlockFunc Func def
 	BlockFunc
 	// BlockMethod Method def
 	BlockMethod
+	// BlockVar package level var
+	BlockVar
+	// BlockConst package level const
+	BlockConst
+	// BlockInit init func def
+	BlockInit
 )
 
 // getFileInfo returns FileInfo struct
@@ -195,17 +228,28 @@ func getFileInfo(fname string, src interface{}) (FileInfo, error) {
 			case *ast.ImportSpec:
 				// TODO handle
 			case *ast.TypeSpec:
+				// struct, interface, alias and other named types
 				block.name = spec.Name.Name
 				block.typ = BlockType
-				// handle struct type
-				typ, ok := spec.Type.(*ast.StructType)
-				if ok {
-					block.start = fset.Position(typ.Fields.Opening).Line
-					block.end = fset.Position(typ.Fields.Closing).Line
-					blocks = append(blocks, block)
-				}
+				block.start = fset.Position(spec.Pos()).Line
+				block.end = fset.Position(spec.End()).Line
+				blocks = append(blocks, block)
 			case *ast.ValueSpec:
-				// TODO handle variable decl
+				typ := BlockVar
+				if d.Tok == token.CONST {
+					typ = BlockConst
+				}
+				for _, ident := range spec.Names {
+					if ident.Name == "_" {
+						continue
+					}
+					blocks = append(blocks, FileBlock{
+						typ:   typ,
+						name:  ident.Name,
+						start: fset.Position(spec.Pos()).Line,
+						end:   fset.Position(spec.End()).Line,
+					})
+				}
 			default:
 				fmt.Printf("[WARN] unhandled GenDecl Spec case %# v\n", pretty.Formatter(spec)) // output for debug
 
@@ -225,21 +269,14 @@ func getFileInfo(fname string, src interface{}) (FileInfo, error) {
 		if fn.Recv != nil {
 			// method
 			block.typ = BlockMethod
-			fld := fn.Recv.List[0]
-			switch v := fld.Type.(type) {
-			case *ast.Ident:
-				block.name = v.Name + "." + block.name
-			case *ast.StarExpr:
-				ident, ok := v.X.(*ast.Ident)
-				if ok {
-					block.name = ident.Name + "." + block.name
-				} else {
-					return block, fmt.Errorf("unexpected ast type %T", v.X)
-				}
-			default:
-				fmt.Printf("[WARN] unhandled method reciver case %T\n", v) // output for debug
-
+			recv, err := recvTypeName(fn.Recv.List[0].Type)
+			if err != nil {
+				return block, err
 			}
+			block.name = recv + "." + block.name
+		} else if block.name == "init" {
+			// runs on package load, may be many in package
+			block.typ = BlockInit
 		}
 		return block, nil
 	}
@@ -265,6 +302,24 @@ func getFileInfo(fname string, src interface{}) (FileInfo, error) {
 	return fileInfo, nil
 }
 
+// recvTypeName returns type name of method receiver,
+// T, *T, T[P] or *T[P1, P2] of generic types
+func recvTypeName(expr ast.Expr) (string, error) {
+	switch v := expr.(type) {
+	case *ast.Ident:
+		return v.Name, nil
+	case *ast.StarExpr:
+		return recvTypeName(v.X)
+	case *ast.ParenExpr:
+		return recvTypeName(v.X)
+	case *ast.IndexExpr:
+		return recvTypeName(v.X)
+	case *ast.IndexListExpr:
+		return recvTypeName(v.X)
+	}
+	return "", fmt.Errorf("unexpected ast type %T", expr)
+}
+
 // splitStr splits string by sep and trims each entry
 func splitStr(str, sep string) []string {
 	out := strings.Split(str, sep)
@@ -286,16 +341,39 @@ func mapStrToSlice(set map[string]bool) []string {
 // parseFlags parses provided args and returns config
 func parseFlags(args []string) (config, error) {
 	var err error
-	args = args[1:]
+	// copy to not modify os.Args normalising flags
+	args = append([]string(nil), args[1:]...)
 	if len(args) > 0 && (args[0] == "help" || args[0] == "-help") {
 		return config{}, errors.New(flagUsage())
 	}
 	cfg := newConfig()
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		if !isValidCommand(args[0]) {
+			return config{}, fmt.Errorf("unknown command %s", args[0])
+		}
+		cfg.command = args[0]
+		args = args[1:]
+	}
 	var flagName, nextArg string
 LOOP:
 	for i := 0; i < len(args); i++ {
+		if (cfg.command == "explain" || cfg.command == "snapshots" ||
+			cfg.command == "squash" || cfg.command == "hook") &&
+			!strings.HasPrefix(args[i], "-") {
+			// test names, snapshots action, squash base or hook args
+			cfg.cmdArgs = append(cfg.cmdArgs, args[i])
+			continue
+		}
+		if strings.HasPrefix(args[i], "--") {
+			// accept --flag form
+			args[i] = args[i][1:]
+		}
 		eqID := strings.IndexByte(args[i], '=')
-		if eqID >= 0 {
+		if args[i] == "-explain" &&
+			(i+1 >= len(args) || strings.HasPrefix(args[i+1], "-")) {
+			// bool flag without value
+			flagName, nextArg = args[i], "true"
+		} else if eqID >= 0 {
 			flagName, nextArg = args[i][:eqID], args[i][eqID+1:]
 		} else {
 			if i+1 >= len(args) {
@@ -314,12 +392,27 @@ LOOP:
 			} else {
 				return config{}, fmt.Errorf("-strategy invalid value %v", nextArg)
 			}
+		case "-hybrid-mode":
+			if nextArg != "union" && nextArg != "intersect" {
+				return config{}, fmt.Errorf("-hybrid-mode invalid value %v", nextArg)
+			}
+			cfg.hybridMode = nextArg
 		case "-analysis":
 			if isValidAnalysis(nextArg) {
 				cfg.analysis = nextArg
 			} else {
 				return config{}, fmt.Errorf("-analysis invalid value %v", nextArg)
 			}
+		case "-analysis-memory":
+			cfg.analysisMemory, err = strconv.ParseUint(nextArg, 10, 64)
+			if err != nil {
+				return config{}, fmt.Errorf("-analysis-memory invalid value %v", nextArg)
+			}
+		case "-analysis-budget":
+			cfg.analysisBudget, err = time.ParseDuration(nextArg)
+			if err != nil || cfg.analysisBudget < 0 {
+				return config{}, fmt.Errorf("-analysis-budget invalid value %v", nextArg)
+			}
 		case "-run-init":
 			cfg.runInit, err = strconv.ParseBool(nextArg)
 			if err != nil {
@@ -339,6 +432,54 @@ LOOP:
 			if err != nil {
 				return config{}, fmt.Errorf("-auto-commit invalid value %v", nextArg)
 			}
+		case "-auto-revert":
+			cfg.autoRevert, err = strconv.ParseBool(nextArg)
+			if err != nil {
+				return config{}, fmt.Errorf("-auto-revert invalid value %v", nextArg)
+			}
+		case "-auto-commit-mode":
+			if nextArg != "branch" && nextArg != "wip" {
+				return config{}, fmt.Errorf("-auto-commit-mode invalid value %v", nextArg)
+			}
+			cfg.autoCommitMode = nextArg
+		case "-commit-template":
+			_, err = parseCommitTemplate(nextArg)
+			if err != nil {
+				return config{}, fmt.Errorf("-commit-template invalid value %v", err)
+			}
+			cfg.commitTemplate = nextArg
+		case "-commit-sign":
+			cfg.commitSign, err = strconv.ParseBool(nextArg)
+			if err != nil {
+				return config{}, fmt.Errorf("-commit-sign invalid value %v", nextArg)
+			}
+		case "-commit-author":
+			cfg.commitAuthor = nextArg
+		case "-commit-no-verify":
+			cfg.commitNoVerify, err = strconv.ParseBool(nextArg)
+			if err != nil {
+				return config{}, fmt.Errorf("-commit-no-verify invalid value %v", nextArg)
+			}
+		case "-commit-trailer":
+			// repeatable
+			cfg.commitTrailers = append(cfg.commitTrailers, nextArg)
+		case "-base":
+			cfg.base = nextArg
+		case "-git":
+			if nextArg != "exec" && nextArg != "go" {
+				return config{}, fmt.Errorf("-git invalid value %v", nextArg)
+			}
+			cfg.git = nextArg
+		case "-format":
+			if nextArg != "text" && nextArg != "json" {
+				return config{}, fmt.Errorf("-format invalid value %v", nextArg)
+			}
+			cfg.format = nextArg
+		case "-explain":
+			cfg.explain, err = strconv.ParseBool(nextArg)
+			if err != nil {
+				return config{}, fmt.Errorf("-explain invalid value %v", nextArg)
+			}
 		case "-args":
 			cfg.argsToTestBinary = strings.Join(args[i:], " ")
 			break LOOP
@@ -346,6 +487,10 @@ LOOP:
 			return cfg, fmt.Errorf("invalid option -- %s", flagName)
 		}
 	}
+	if cfg.autoRevert && (!cfg.autoCommit || cfg.autoCommitMode != "wip") {
+		// green state is snapshot of last tests pass
+		return config{}, errors.New("-auto-revert requires -auto-commit true -auto-commit-mode wip")
+	}
 
 	return cfg, nil
 }
@@ -354,14 +499,21 @@ func isValidAnalysis(analysis string) bool {
 	if analysis == "pointer" ||
 		analysis == "cha" ||
 		analysis == "rta" ||
+		analysis == "vta" ||
 		analysis == "static" {
 		return true
 	}
 	return false
 }
 
+func isValidCommand(command string) bool {
+	return command == "run" || command == "affected" || command == "explain" ||
+		command == "snapshots" || command == "squash" || command == "hook"
+}
+
 func isValidStrategy(strategy string) bool {
-	if strategy == "coverage" || strategy == "analysis" {
+	if strategy == "coverage" || strategy == "analysis" ||
+		strategy == "hybrid" || strategy == "import" {
 		return true
 	}
 	return false
@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/kr/pretty"
 )
@@ -71,6 +72,13 @@ index d1f20de..c0bcbcd 100644
 @@ -69,0 +72,22 @@ func Test_fnNameFromCallExpr(t *testing.T) {
 +       }
 +}
+diff --git a/math.go b/math.go
+index 1a2b3c4..5d6e7f8 100644
+--- a/math.go
++++ b/math.go
+@@ -5 +5 @@ const limit = 5 + 5
+-var base = 1
++var base = 2 - 1
 `, output: []Change{
 			{fpathOld: "parser.go", fpath: "parser.go", start: 33, count: 2},
 			{fpathOld: "parser_test.go", fpath: "parser_test.go", start: 341, count: 0},
@@ -82,7 +90,8 @@ index d1f20de..c0bcbcd 100644
 			{fpathOld: "process_go_file.go", fpath: "process_go_file.go", start: 120, count: 0},
 			{fpathOld: "process_go_file.go", fpath: "process_go_file.go", start: 168, count: 0},
 			{fpathOld: "process_go_file_test.go", fpath: "process_go_file_test.go", start: 6, count: 2},
-			{fpathOld: "process_go_file_test.go", fpath: "process_go_file_test.go", start: 72, count: 22}}},
+			{fpathOld: "process_go_file_test.go", fpath: "process_go_file_test.go", start: 72, count: 22},
+			{fpathOld: "math.go", fpath: "math.go", start: 5, count: 0}}},
 		// deleted file
 		{data: `diff --git a/main.go b/main.go
 deleted file mode 100644
@@ -102,7 +111,53 @@ index 6e2c328..0000000
 -func add(a, b int) {
 -	return a + b
 -}
-`, output: nil},
+`, output: []Change{{fpathOld: "main.go"}}},
+		// renamed files with and without changes
+		{data: `diff --git a/math.go b/pkga/math.go
+similarity index 100%
+rename from math.go
+rename to pkga/math.go
+diff --git a/geo.go b/geometry.go
+similarity index 80%
+rename from geo.go
+rename to geometry.go
+index 6e2c328..5d6e7f8 100644
+--- a/geo.go
++++ b/geometry.go
+@@ -7 +7 @@ func Area(d, h int) int {
+-	return d * h
++	return h * d
+diff --git a/sub.go b/pkgb/sub.go
+similarity index 100%
+rename from sub.go
+rename to pkgb/sub.go
+`, output: []Change{
+			{fpathOld: "math.go", fpath: "pkga/math.go", start: 0, count: 0},
+			{fpathOld: "geo.go", fpath: "geometry.go", start: 7, count: 0},
+			{fpathOld: "sub.go", fpath: "pkgb/sub.go", start: 0, count: 0}}},
+		// paths with spaces
+		{data: `diff --git a/old name.go b/new name.go
+similarity index 100%
+rename from old name.go
+rename to new name.go
+diff --git a/pkg a/my file.go b/pkg a/my file.go
+index 789603e..b0a822f 100644
+--- a/pkg a/my file.go	
++++ b/pkg a/my file.go	
+@@ -4 +4 @@ func F() int {
+-	return 1
++	return 2
+diff --git a/pkg a/b c.go b/pkg a/b c.go
+deleted file mode 100644
+index 6e2c328..0000000
+--- a/pkg a/b c.go	
++++ /dev/null
+@@ -1 +0,0 @@
+-package a
+`, output: []Change{
+			{fpathOld: "old name.go", fpath: "new name.go", start: 0, count: 0},
+			{fpathOld: "pkg a/my file.go", fpath: "pkg a/my file.go", start: 4, count: 0},
+			{fpathOld: "pkg a/b c.go"}}},
 	}
 	var buffer bytes.Buffer
 	for i, tc := range cases {
@@ -154,6 +209,67 @@ func Area(d, h int) int {
 }
 `)
 
+var gofileGlobals = []byte(`
+package main
+
+import "fmt"
+
+const (
+	A = iota
+	_
+	B
+)
+
+var x, y = 1,
+	2
+
+func init() {
+	fmt.Println(x)
+}
+
+func init() {
+	y = B
+}
+`)
+
+var gofileTypes = []byte(`
+package main
+
+type (
+	Shape interface {
+		Area() int
+	}
+	IDs   []int
+	Alias = IDs
+)
+
+type Handler func(
+	id int,
+) error
+`)
+
+var gofileGenerics = []byte(`
+package main
+
+type List[T any] struct {
+	items []T
+}
+
+func (l *List[T]) Push(v T) {
+	l.items = append(l.items, v)
+}
+
+type Pair[K comparable, V any] struct{ k K; v V }
+
+func (p Pair[K, V]) Key() K {
+	return p.k
+}
+
+func Map[T, U any](in []T, f func(T) U) []U {
+	return nil
+}
+`)
+
 func TestGetFileBlocks(t *testing.T) {
 	cases := []struct {
 		fileName string
@@ -175,9 +291,48 @@ func TestGetFileBlocks(t *testing.T) {
 				},
 			},
 		},
+		{
+			fileName: "globals.go", fileData: gofileGlobals, output: FileInfo{
+				fname:   "globals.go",
+				pkgName: "main", endLine: 21,
+				blocks: []FileBlock{
+					{typ: BlockConst, name: "A", start: 7, end: 7},
+					{typ: BlockConst, name: "B", start: 9, end: 9},
+					{typ: BlockVar, name: "x", start: 12, end: 13},
+					{typ: BlockVar, name: "y", start: 12, end: 13},
+					{typ: BlockInit, name: "init", start: 15, end: 17},
+					{typ: BlockInit, name: "init", start: 19, end: 21},
+				},
+			},
+		},
+		{
+			fileName: "types.go", fileData: gofileTypes, output: FileInfo{
+				fname:   "types.go",
+				pkgName: "main", endLine: 14,
+				blocks: []FileBlock{
+					{typ: BlockType, name: "Shape", start: 5, end: 7},
+					{typ: BlockType, name: "IDs", start: 8, end: 8},
+					{typ: BlockType, name: "Alias", start: 9, end: 9},
+					{typ: BlockType, name: "Handler", start: 12, end: 14},
+				},
+			},
+		},
+		{
+			fileName: "generics.go", fileData: gofileGenerics, output: FileInfo{
+				fname:   "generics.go",
+				pkgName: "main", endLine: 20,
+				blocks: []FileBlock{
+					{typ: BlockType, name: "List", start: 4, end: 6},
+					{typ: BlockMethod, name: "List.Push", start: 8, end: 10},
+					{typ: BlockType, name: "Pair", start: 12, end: 12},
+					{typ: BlockMethod, name: "Pair.Key", start: 14, end: 16},
+					{typ: BlockFunc, name: "Map", start: 18, end: 20},
+				},
+			},
+		},
 	}
 	for i, tc := range cases {
-		fileInfo, err := getFileInfo("gofile.go", gofile)
+		fileInfo, err := getFileInfo(tc.fileName, tc.fileData)
 		if isUnexpectedErr(t, i, "", tc.err, err) {
 			continue
 		}
@@ -213,13 +368,19 @@ func TestParseFlag(t *testing.T) {
 			out: config{
 				workDir:           "/home/user/go",
 				strategy:          "coverage",
+				hybridMode:        "union",
 				analysis:          "cha",
+				analysisBudget:    30 * time.Second,
 				runInit:           false,
 				delay:             10,
 				excludeFilePrefix: []string{"h", "v", "#"},
 				excludeDirs:       []string{"vendor", "node_modules"},
 				autoCommit:        true,
 				argsToTestBinary:  "-tf1 10 -tf2 20,30",
+				format:            "text",
+				git:               "exec",
+				autoCommitMode:    "branch",
+				autoRevert:        false,
 			},
 			err: nil,
 		},
@@ -249,12 +410,18 @@ func TestParseFlag(t *testing.T) {
 				workDir:           ".",
 				delay:             1000,
 				strategy:          "coverage",
+				hybridMode:        "union",
 				runInit:           true,
 				analysis:          "cha",
+				analysisBudget:    30 * time.Second,
 				excludeFilePrefix: []string{"#"},
 				excludeDirs:       []string{"vendor", "node_modules"},
 				autoCommit:        false,
 				argsToTestBinary:  "",
+				format:            "text",
+				git:               "exec",
+				autoCommitMode:    "branch",
+				autoRevert:        false,
 			},
 			err: nil,
 		},
@@ -264,9 +431,212 @@ func TestParseFlag(t *testing.T) {
 			out:    config{},
 			err:    errors.New("invalid option -- -no-a-flag"),
 		},
+		{
+			desc:   "run command with base ref",
+			osArgs: []string{"./binary", "run", "--base", "origin/main", "-strategy=coverage"},
+			out: func() config {
+				cfg := newConfig()
+				cfg.command = "run"
+				cfg.base = "origin/main"
+				cfg.strategy = "coverage"
+				return cfg
+			}(),
+		},
+		{
+			desc:   "snapshots command with action and wip auto commit",
+			osArgs: []string{"./binary", "snapshots", "diff", "2", "-auto-commit-mode", "wip"},
+			out: func() config {
+				cfg := newConfig()
+				cfg.command = "snapshots"
+				cfg.cmdArgs = []string{"diff", "2"}
+				cfg.autoCommitMode = "wip"
+				return cfg
+			}(),
+		},
+		{
+			desc:   "squash command with base",
+			osArgs: []string{"./binary", "squash", "main", "-C", "dir"},
+			out: func() config {
+				cfg := newConfig()
+				cfg.command = "squash"
+				cfg.cmdArgs = []string{"main"}
+				cfg.workDir = "dir"
+				return cfg
+			}(),
+		},
+		{
+			desc: "commit message template and options",
+			osArgs: []string{"./binary", "-commit-template", "wip: {{join .Blocks \" \"}}",
+				"-commit-sign", "true", "--commit-author=Bot <bot@example.com>",
+				"-commit-no-verify", "true", "-commit-trailer", "Refs: #1",
+				"-commit-trailer", "Signed-off-by: Bot <bot@example.com>"},
+			out: func() config {
+				cfg := newConfig()
+				cfg.commitTemplate = "wip: {{join .Blocks \" \"}}"
+				cfg.commitSign = true
+				cfg.commitAuthor = "Bot <bot@example.com>"
+				cfg.commitNoVerify = true
+				cfg.commitTrailers = []string{"Refs: #1", "Signed-off-by: Bot <bot@example.com>"}
+				return cfg
+			}(),
+		},
+		{
+			desc:   "commit template invalid",
+			osArgs: []string{"./binary", "-commit-template", "{{.Blocks"},
+			err:    errors.New("-commit-template invalid value template: commit:1: unclosed action"),
+		},
+		{
+			desc: "auto revert",
+			osArgs: []string{"./binary", "-auto-commit", "true", "-auto-revert", "true",
+				"-auto-commit-mode", "wip"},
+			out: func() config {
+				cfg := newConfig()
+				cfg.autoCommit = true
+				cfg.autoRevert = true
+				cfg.autoCommitMode = "wip"
+				return cfg
+			}(),
+		},
+		{
+			desc:   "auto revert without snapshots",
+			osArgs: []string{"./binary", "-auto-commit", "true", "-auto-revert", "true"},
+			err:    errors.New("-auto-revert requires -auto-commit true -auto-commit-mode wip"),
+		},
+		{
+			desc:   "auto revert invalid",
+			osArgs: []string{"./binary", "-auto-revert", "yes"},
+			err:    errors.New("-auto-revert invalid value yes"),
+		},
+		{
+			desc:   "hook command with args",
+			osArgs: []string{"./binary", "hook", "run", "pre-push", "-strategy", "import", "origin"},
+			out: func() config {
+				cfg := newConfig()
+				cfg.command = "hook"
+				cfg.cmdArgs = []string{"run", "pre-push", "origin"}
+				cfg.strategy = "import"
+				return cfg
+			}(),
+		},
+		{
+			desc:   "auto commit mode invalid",
+			osArgs: []string{"./binary", "-auto-commit-mode", "tag"},
+			err:    errors.New("-auto-commit-mode invalid value tag"),
+		},
+		{
+			desc:   "go git backend",
+			osArgs: []string{"./binary", "affected", "-git=go"},
+			out: func() config {
+				cfg := newConfig()
+				cfg.command = "affected"
+				cfg.git = "go"
+				return cfg
+			}(),
+		},
+		{
+			desc:   "git backend invalid",
+			osArgs: []string{"./binary", "-git", "libgit2"},
+			err:    errors.New("-git invalid value libgit2"),
+		},
+		{
+			desc:   "affected command in json format",
+			osArgs: []string{"./binary", "affected", "-format", "json"},
+			out: func() config {
+				cfg := newConfig()
+				cfg.command = "affected"
+				cfg.format = "json"
+				return cfg
+			}(),
+		},
+		{
+			desc: "explain command with test names",
+			osArgs: []string{"./binary", "explain", "TestAdd", "-strategy", "coverage",
+				"pkga.TestSub"},
+			out: func() config {
+				cfg := newConfig()
+				cfg.command = "explain"
+				cfg.strategy = "coverage"
+				cfg.cmdArgs = []string{"TestAdd", "pkga.TestSub"}
+				return cfg
+			}(),
+		},
+		{
+			desc:   "explain flag without value",
+			osArgs: []string{"./binary", "run", "--explain", "-delay", "10"},
+			out: func() config {
+				cfg := newConfig()
+				cfg.command = "run"
+				cfg.explain = true
+				cfg.delay = 10
+				return cfg
+			}(),
+		},
+		{
+			desc:   "hybrid strategy intersect",
+			osArgs: []string{"./binary", "-strategy", "hybrid", "-hybrid-mode", "intersect"},
+			out: func() config {
+				cfg := newConfig()
+				cfg.strategy = "hybrid"
+				cfg.hybridMode = "intersect"
+				return cfg
+			}(),
+		},
+		{
+			desc:   "hybrid mode invalid",
+			osArgs: []string{"./binary", "-hybrid-mode", "all"},
+			err:    errors.New("-hybrid-mode invalid value all"),
+		},
+		{
+			desc:   "import strategy and analysis budget",
+			osArgs: []string{"./binary", "-strategy", "import", "-analysis-budget", "1m30s"},
+			out: func() config {
+				cfg := newConfig()
+				cfg.strategy = "import"
+				cfg.analysisBudget = 90 * time.Second
+				return cfg
+			}(),
+		},
+		{
+			desc:   "vta analysis",
+			osArgs: []string{"./binary", "-analysis", "vta"},
+			out: func() config {
+				cfg := newConfig()
+				cfg.analysis = "vta"
+				return cfg
+			}(),
+		},
+		{
+			desc:   "analysis memory budget",
+			osArgs: []string{"./binary", "-analysis-memory", "2048"},
+			out: func() config {
+				cfg := newConfig()
+				cfg.analysisMemory = 2048
+				return cfg
+			}(),
+		},
+		{
+			desc:   "analysis memory budget invalid",
+			osArgs: []string{"./binary", "-analysis-memory", "-1"},
+			err:    errors.New("-analysis-memory invalid value -1"),
+		},
+		{
+			desc:   "analysis budget invalid",
+			osArgs: []string{"./binary", "-analysis-budget", "10"},
+			err:    errors.New("-analysis-budget invalid value 10"),
+		},
+		{
+			desc:   "unknown command",
+			osArgs: []string{"./binary", "walk"},
+			out:    config{},
+			err:    errors.New("unknown command walk"),
+		},
 	}
 	for i, tc := range cases {
+		osArgs := append([]string(nil), tc.osArgs...)
 		cfg, err := parseFlags(tc.osArgs)
+		if !reflect.DeepEqual(osArgs, tc.osArgs) {
+			t.Errorf("case [%d] %s\nexpected args not modified %v, got %v", i, tc.desc, osArgs, tc.osArgs)
+		}
 		if isUnexpectedErr(t, i, tc.desc, tc.err, err) {
 			continue
 		}
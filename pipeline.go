@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// RunCondition defines when pipeline task runs
+// depending on results of its dependencies
+type RunCondition int
+
+const (
+	// OnSuccess runs task if all dependencies succeeded
+	OnSuccess RunCondition = iota
+	// OnFailure runs task if any dependency failed or returned error
+	OnFailure
+	// Always runs task after dependencies finished
+	Always
+	// OnNoError runs task if no dependency returned error
+	OnNoError
+)
+
+func (c RunCondition) String() string {
+	switch c {
+	case OnSuccess:
+		return "on_success"
+	case OnFailure:
+		return "on_failure"
+	case Always:
+		return "always"
+	case OnNoError:
+		return "on_no_error"
+	}
+	return "unknown"
+}
+
+// PipelineTask defines task in a pipeline
+// task runs after all tasks it depends on finished
+// and its condition is met, if any dependency was not run
+// task is not run too, results of dependencies are passed
+// in context by depTaskResultsKey and result of the last
+// dependency by prevTaskOutputKey
+type PipelineTask struct {
+	// ID unique in pipeline, Task.ID() if empty
+	ID        string
+	Task      Task
+	DependsOn []string
+	When      RunCondition
+	// Timeout of the task, no timeout if 0
+	Timeout time.Duration
+}
+
+// Pipeline DAG of tasks executed by Watcher
+type Pipeline struct {
+	parallel bool
+	// tasks in topological order
+	tasks []*PipelineTask
+}
+
+// NewPipeline validates tasks dependencies and returns pipeline
+// independent tasks are executed concurrently if parallel is true
+func NewPipeline(parallel bool, tasks ...PipelineTask) (*Pipeline, error) {
+	index := map[string]*PipelineTask{}
+	var list []*PipelineTask
+	for i := range tasks {
+		task := tasks[i]
+		if task.Task == nil {
+			return nil, fmt.Errorf("pipeline task %d is nil", i)
+		}
+		if task.ID == "" {
+			task.ID = task.Task.ID()
+		}
+		if _, ok := index[task.ID]; ok {
+			return nil, fmt.Errorf("pipeline task %s defined twice", task.ID)
+		}
+		index[task.ID] = &task
+		list = append(list, &task)
+	}
+	for _, task := range list {
+		for _, dep := range task.DependsOn {
+			if _, ok := index[dep]; !ok {
+				return nil, fmt.Errorf("pipeline task %s depends on unknown task %s", task.ID, dep)
+			}
+		}
+	}
+	// sort tasks in topological order
+	// keeping order of definition
+	visited := map[string]int{} // 1 in progress, 2 done
+	var sorted []*PipelineTask
+	var visit func(t *PipelineTask) error
+	visit = func(t *PipelineTask) error {
+		switch visited[t.ID] {
+		case 1:
+			return fmt.Errorf("pipeline has cycle on task %s", t.ID)
+		case 2:
+			return nil
+		}
+		visited[t.ID] = 1
+		for _, dep := range t.DependsOn {
+			if err := visit(index[dep]); err != nil {
+				return err
+			}
+		}
+		visited[t.ID] = 2
+		sorted = append(sorted, t)
+		return nil
+	}
+	for _, task := range list {
+		if err := visit(task); err != nil {
+			return nil, err
+		}
+	}
+	return &Pipeline{parallel: parallel, tasks: sorted}, nil
+}
+
+// SequentialPipeline returns pipeline which runs tasks
+// in provided sequence and stops on first task error
+func SequentialPipeline(tasks ...Task) *Pipeline {
+	p := &Pipeline{}
+	prev := ""
+	for i, task := range tasks {
+		pt := &PipelineTask{
+			ID:   fmt.Sprintf("%d.%s", i, task.ID()),
+			Task: task, When: OnNoError,
+		}
+		if prev != "" {
+			pt.DependsOn = []string{prev}
+		}
+		prev = pt.ID
+		p.tasks = append(p.tasks, pt)
+	}
+	return p
+}
+
+// Run executes pipeline tasks and returns results by task ID
+// tasks which were not run do not have results
+func (p *Pipeline) Run(ctx context.Context, logger *log.Logger) map[string]*TaskResult {
+	results := map[string]*TaskResult{}
+	var mu sync.Mutex
+	done := map[string]chan struct{}{}
+	for _, task := range p.tasks {
+		done[task.ID] = make(chan struct{})
+	}
+	run := func(task *PipelineTask) {
+		defer close(done[task.ID])
+		deps := make(map[string]*TaskResult, len(task.DependsOn))
+		for _, dep := range task.DependsOn {
+			<-done[dep]
+			mu.Lock()
+			res := results[dep]
+			mu.Unlock()
+			if res == nil {
+				// dependency was not run
+				logger.Printf("skip Task.ID: %s, %s was not run\n", task.ID, dep) // output for debug
+				return
+			}
+			deps[dep] = res
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if !task.When.met(deps) {
+			logger.Printf("skip Task.ID: %s, condition %s not met\n", task.ID, task.When) // output for debug
+			return
+		}
+		res := p.runTask(ctx, task, deps, logger)
+		mu.Lock()
+		results[task.ID] = res
+		mu.Unlock()
+	}
+	if p.parallel {
+		var wg sync.WaitGroup
+		wg.Add(len(p.tasks))
+		for _, task := range p.tasks {
+			go func(task *PipelineTask) {
+				defer wg.Done()
+				run(task)
+			}(task)
+		}
+		wg.Wait()
+	} else {
+		// tasks sorted, dependencies are done before
+		for _, task := range p.tasks {
+			run(task)
+		}
+	}
+	return results
+}
+
+func (p *Pipeline) runTask(
+	ctx context.Context,
+	task *PipelineTask,
+	deps map[string]*TaskResult,
+	logger *log.Logger,
+) *TaskResult {
+	if len(deps) > 0 {
+		ctx = context.WithValue(ctx, depTaskResultsKey, deps)
+		ctx = context.WithValue(ctx, prevTaskOutputKey, deps[task.DependsOn[len(task.DependsOn)-1]])
+	}
+	if task.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, task.Timeout)
+		defer cancel()
+	}
+	logger.Printf("Run task.ID %+v\n", task.ID) // output for debug
+	start := time.Now()
+	res, err := task.Task.Run(ctx)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("timeout %s exceeded, %v", task.Timeout, err)
+		}
+		logger.Printf("Task.ID: %s returned error %v\n", task.ID, err) // output for debug
+		res = NewTaskResult(TaskError, err.Error())
+	}
+	if res == nil {
+		res = NewTaskResult(TaskSkipped, "")
+	}
+	if res.Duration == 0 {
+		res.Duration = time.Since(start)
+	}
+	return res
+}
+
+// met returns true if condition met by dependencies results
+func (c RunCondition) met(deps map[string]*TaskResult) bool {
+	if len(deps) == 0 {
+		return true
+	}
+	switch c {
+	case OnSuccess:
+		for _, res := range deps {
+			if res.Status != TaskSuccess {
+				return false
+			}
+		}
+		return true
+	case OnFailure:
+		for _, res := range deps {
+			if res.Status == TaskFailure || res.Status == TaskError {
+				return true
+			}
+		}
+		return false
+	case OnNoError:
+		for _, res := range deps {
+			if res.Status == TaskError {
+				return false
+			}
+		}
+		return true
+	case Always:
+		return true
+	}
+	return false
+}
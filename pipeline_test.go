@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewPipeline(t *testing.T) {
+	logger := log.New(os.Stdout, "gtr-test:", log.Ltime)
+	task := func(id string) Task {
+		return NewTask(id, func(log *log.Logger, ctx context.Context) (*TaskResult, error) {
+			return NewTaskResult(TaskSuccess, id), nil
+		}, logger)
+	}
+	cases := []struct {
+		desc  string
+		tasks []PipelineTask
+		order []string
+		err   error
+	}{
+		{
+			desc: "Tasks sorted by dependencies",
+			tasks: []PipelineTask{
+				{Task: task("notify"), DependsOn: []string{"test"}},
+				{Task: task("lint")},
+				{Task: task("test")},
+			},
+			order: []string{"test", "notify", "lint"},
+		},
+		{
+			desc: "Task defined twice",
+			tasks: []PipelineTask{
+				{Task: task("test")}, {Task: task("test")},
+			},
+			err: errors.New("pipeline task test defined twice"),
+		},
+		{
+			desc: "Unknown dependency",
+			tasks: []PipelineTask{
+				{Task: task("test"), DependsOn: []string{"build"}},
+			},
+			err: errors.New("pipeline task test depends on unknown task build"),
+		},
+		{
+			desc: "Cycle in dependencies",
+			tasks: []PipelineTask{
+				{Task: task("a"), DependsOn: []string{"c"}},
+				{Task: task("b"), DependsOn: []string{"a"}},
+				{Task: task("c"), DependsOn: []string{"b"}},
+			},
+			err: errors.New("pipeline has cycle on task a"),
+		},
+	}
+	for i, tc := range cases {
+		p, err := NewPipeline(false, tc.tasks...)
+		if isUnexpectedErr(t, i, tc.desc, tc.err, err) {
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		var order []string
+		for _, task := range p.tasks {
+			order = append(order, task.ID)
+		}
+		if !reflect.DeepEqual(tc.order, order) {
+			t.Errorf("case [%d] %s\nexpected %v, got %v", i, tc.desc, tc.order, order)
+		}
+	}
+}
+
+func TestPipelineRun(t *testing.T) {
+	logger := log.New(os.Stdout, "gtr-test:", log.Ltime)
+	var mu sync.Mutex
+	var executed []string
+	task := func(id string, status TaskStatus, err error) Task {
+		return NewTask(id, func(log *log.Logger, ctx context.Context) (*TaskResult, error) {
+			mu.Lock()
+			executed = append(executed, id)
+			mu.Unlock()
+			if err != nil {
+				return nil, err
+			}
+			return NewTaskResult(status, id), nil
+		}, logger)
+	}
+	// both tasks wait for each other, possible only in parallel
+	barrier := make(chan struct{})
+	waitTask := func(id string, send bool) Task {
+		return NewTask(id, func(log *log.Logger, ctx context.Context) (*TaskResult, error) {
+			if send {
+				barrier <- struct{}{}
+			} else {
+				<-barrier
+			}
+			return NewTaskResult(TaskSuccess, id), nil
+		}, logger)
+	}
+	slowTask := NewTask("slow", func(log *log.Logger, ctx context.Context) (*TaskResult, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, logger)
+
+	cases := []struct {
+		desc     string
+		parallel bool
+		tasks    []PipelineTask
+		executed []string
+		statuses map[string]TaskStatus
+	}{
+		{
+			desc: "Tests pass, commit and notify",
+			tasks: []PipelineTask{
+				{Task: task("test", TaskSuccess, nil)},
+				{Task: task("commit", TaskSuccess, nil), DependsOn: []string{"test"}, When: OnSuccess},
+				{Task: task("notify-pass", TaskSuccess, nil), DependsOn: []string{"commit"}, When: Always},
+				{Task: task("notify-fail", TaskSuccess, nil), DependsOn: []string{"test"}, When: OnFailure},
+			},
+			executed: []string{"commit", "notify-pass", "test"},
+			statuses: map[string]TaskStatus{
+				"test": TaskSuccess, "commit": TaskSuccess, "notify-pass": TaskSuccess},
+		},
+		{
+			desc: "Tests fail, notify and open report",
+			tasks: []PipelineTask{
+				{Task: task("test", TaskFailure, nil)},
+				{Task: task("commit", TaskSuccess, nil), DependsOn: []string{"test"}, When: OnSuccess},
+				{Task: task("notify-pass", TaskSuccess, nil), DependsOn: []string{"commit"}, When: Always},
+				{Task: task("notify-fail", TaskSuccess, nil), DependsOn: []string{"test"}, When: OnFailure},
+				{Task: task("report", TaskSuccess, nil), DependsOn: []string{"test"}, When: OnFailure},
+			},
+			executed: []string{"notify-fail", "report", "test"},
+			statuses: map[string]TaskStatus{
+				"test": TaskFailure, "notify-fail": TaskSuccess, "report": TaskSuccess},
+		},
+		{
+			desc: "Task error treated as failure",
+			tasks: []PipelineTask{
+				{Task: task("test", TaskSuccess, errors.New("strategy error"))},
+				{Task: task("notify-fail", TaskSuccess, nil), DependsOn: []string{"test"}, When: OnFailure},
+			},
+			executed: []string{"notify-fail", "test"},
+			statuses: map[string]TaskStatus{"test": TaskError, "notify-fail": TaskSuccess},
+		},
+		{
+			desc:     "Parallel independent branches",
+			parallel: true,
+			tasks: []PipelineTask{
+				{Task: waitTask("lint", true)},
+				{Task: waitTask("test", false)},
+				{Task: task("notify", TaskSuccess, nil), DependsOn: []string{"lint", "test"}, When: OnSuccess},
+			},
+			executed: []string{"notify"},
+			statuses: map[string]TaskStatus{
+				"lint": TaskSuccess, "test": TaskSuccess, "notify": TaskSuccess},
+		},
+		{
+			desc: "Task timeout",
+			tasks: []PipelineTask{
+				{Task: slowTask, Timeout: time.Millisecond},
+				{Task: task("notify", TaskSuccess, nil), DependsOn: []string{"slow"}, When: Always},
+			},
+			executed: []string{"notify"},
+			statuses: map[string]TaskStatus{"slow": TaskError, "notify": TaskSuccess},
+		},
+	}
+	for i, tc := range cases {
+		executed = nil
+		p, err := NewPipeline(tc.parallel, tc.tasks...)
+		if isUnexpectedErr(t, i, tc.desc, nil, err) {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		results := p.Run(ctx, logger)
+		cancel()
+		sort.Strings(executed)
+		if !reflect.DeepEqual(tc.executed, executed) {
+			t.Errorf("case [%d] %s\nexpected executed %v, got %v", i, tc.desc, tc.executed, executed)
+		}
+		statuses := map[string]TaskStatus{}
+		for id, res := range results {
+			statuses[id] = res.Status
+		}
+		if !reflect.DeepEqual(tc.statuses, statuses) {
+			t.Errorf("case [%d] %s\nexpected statuses %v, got %v", i, tc.desc, tc.statuses, statuses)
+		}
+	}
+}
+
+func TestSequentialPipelineStopsOnError(t *testing.T) {
+	logger := log.New(os.Stdout, "gtr-test:", log.Ltime)
+	var executed []string
+	task := func(id string, err error) Task {
+		return NewTask(id, func(log *log.Logger, ctx context.Context) (*TaskResult, error) {
+			executed = append(executed, id)
+			return NewTaskResult(TaskFailure, id), err
+		}, logger)
+	}
+	p := SequentialPipeline(task("a", nil), task("b", errors.New("stop")), task("c", nil))
+	p.Run(context.Background(), logger)
+	if !reflect.DeepEqual([]string{"a", "b"}, executed) {
+		t.Errorf("expected executed [a b], got %v", executed)
+	}
+}
+
+func TestPipelineDependencyResults(t *testing.T) {
+	logger := log.New(os.Stdout, "gtr-test:", log.Ltime)
+	task := func(id string, status TaskStatus) Task {
+		return NewTask(id, func(log *log.Logger, ctx context.Context) (*TaskResult, error) {
+			return NewTaskResult(status, id), nil
+		}, logger)
+	}
+	var deps map[string]*TaskResult
+	var prev *TaskResult
+	join := NewTask("join", func(log *log.Logger, ctx context.Context) (*TaskResult, error) {
+		deps, prev = depTaskResults(ctx), prevTaskResult(ctx)
+		return NewTaskResult(TaskSuccess, "join"), nil
+	}, logger)
+	for _, parallel := range []bool{false, true} {
+		deps, prev = nil, nil
+		p, err := NewPipeline(parallel,
+			PipelineTask{Task: task("lint", TaskSuccess)},
+			PipelineTask{Task: task("test", TaskFailure)},
+			PipelineTask{Task: join, DependsOn: []string{"lint", "test"}, When: Always},
+		)
+		if err != nil {
+			t.Fatalf("parallel %v unexpected error %v", parallel, err)
+		}
+		p.Run(context.Background(), logger)
+		summaries := map[string]string{}
+		for id, res := range deps {
+			summaries[id] = res.Summary
+		}
+		expected := map[string]string{"lint": "lint", "test": "test"}
+		if !reflect.DeepEqual(expected, summaries) {
+			t.Errorf("parallel %v expected dependency results %v, got %v", parallel, expected, summaries)
+		}
+		if prev.String() != "test" {
+			t.Errorf("parallel %v expected last dependency result test, got %v", parallel, prev)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// rejectedDir is dir in workDir reverted changes are saved to
+var rejectedDir = filepath.Join(".gtr", "rejected")
+
+// RevertChanges returns task to revert changes made in workDir since
+// last green state, latest wip snapshot, on tests failure, reverted
+// changes are saved as patch in .gtr/rejected, nothing is reverted
+// without snapshot
+func RevertChanges(
+	workDir string,
+	newCmd CommandCreator,
+) func(*log.Logger, context.Context) (*TaskResult, error) {
+	return func(log *log.Logger, ctx context.Context) (*TaskResult, error) {
+		prev := prevTaskResult(ctx)
+		if prev == nil || prev.Status != TaskFailure {
+			return NewTaskResult(TaskSkipped, "nothing to revert"), nil
+		}
+		green, err := wipRef(ctx, newCmd, workDir)
+		if err != nil {
+			return NewTaskResult(TaskError, fmt.Sprintf("Revert error %v", err)), nil
+		}
+		_, err = gitOutput(ctx, newCmd, workDir, nil,
+			"rev-parse", "-q", "--verify", green+"^{commit}")
+		if err != nil {
+			return NewTaskResult(TaskSkipped, "no green snapshot to revert to"), nil
+		}
+		// working tree with not tracked files
+		tree, err := snapshotTree(ctx, newCmd, workDir, "HEAD")
+		if err != nil {
+			return NewTaskResult(TaskError, fmt.Sprintf("Revert error %v", err)), nil
+		}
+		patch, err := gitRun(ctx, newCmd, workDir, nil,
+			"diff", "--binary", "--relative", green, tree, "--", ":!.gtr")
+		if err != nil {
+			return NewTaskResult(TaskError, fmt.Sprintf("Revert error %v", err)), nil
+		}
+		if len(patch) == 0 {
+			return NewTaskResult(TaskSkipped, "nothing to revert"), nil
+		}
+		fname, err := savePatch(workDir, patch)
+		if err != nil {
+			return NewTaskResult(TaskError, fmt.Sprintf("Revert save patch error %v", err)), nil
+		}
+		// files added since snapshot, other not tracked
+		// and ignored files are kept
+		added, err := gitRun(ctx, newCmd, workDir, nil,
+			"diff", "--name-only", "-z", "--diff-filter=A", "--relative",
+			green, tree, "--", ":!.gtr")
+		if err != nil {
+			return NewTaskResult(TaskError, fmt.Sprintf("Revert error %v", err)), nil
+		}
+		// working tree is changed to the end even if task
+		// is canceled, index and HEAD are kept
+		_, err = gitOutput(context.Background(), newCmd, workDir, nil,
+			"restore", "--source", green, "--worktree", "--", ".", ":!.gtr")
+		if err != nil {
+			return NewTaskResult(TaskError, fmt.Sprintf("Revert error %v", err)), nil
+		}
+		for _, name := range strings.Split(string(added), "\x00") {
+			if name == "" {
+				continue
+			}
+			err = os.Remove(filepath.Join(workDir, name))
+			if err != nil && !os.IsNotExist(err) {
+				return NewTaskResult(TaskError, fmt.Sprintf("Revert error %v", err)), nil
+			}
+		}
+		msg := "Reverted to " + green
+		if len(prev.Failed) > 0 {
+			msg += ", failed " + strings.Join(prev.Failed, " ")
+		}
+		msg += ", changes saved to " + fname
+		log.Println(msg)
+		return NewTaskResult(TaskSuccess, msg), nil
+	}
+}
+
+// savePatch writes patch to rejected dir of workDir
+// and returns its path relative to workDir
+func savePatch(workDir string, patch []byte) (string, error) {
+	err := os.MkdirAll(filepath.Join(workDir, rejectedDir), 0700)
+	if err != nil {
+		return "", err
+	}
+	fname := filepath.Join(rejectedDir,
+		time.Now().Format("20060102-150405.000")+".patch")
+	return fname, ioutil.WriteFile(filepath.Join(workDir, fname), patch, 0600)
+}
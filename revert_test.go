@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRevertChangesTask(t *testing.T) {
+	testDir := filepath.Join(os.TempDir(), "test_revert_changes_task")
+	filePath := func(fname string) string {
+		return filepath.Join(testDir, fname)
+	}
+	setupTestGitDir(t, testDir,
+		map[string][]byte{
+			"main.go":                        maingo,
+			"math.go":                        mathgo,
+			".gitignore":                     []byte("*.log\n"),
+			filepath.Join(".gtr", "profile"): []byte("mode: set"),
+		},
+		[]string{"main.go", "math.go", ".gitignore"},
+	)
+	defer func() {
+		if !t.Failed() {
+			_ = os.RemoveAll(testDir)
+		}
+	}()
+	ctx := context.Background()
+	logger := log.New(ioutil.Discard, "", 0)
+	change := func() error {
+		err := ioutil.WriteFile(filePath("math.go"), mathgo_add_func, 0600)
+		if err != nil {
+			return err
+		}
+		err = ioutil.WriteFile(filePath("geo.go"), geogo, 0600)
+		if err != nil {
+			return err
+		}
+		err = ioutil.WriteFile(filePath("debug.log"), []byte("log"), 0600)
+		if err != nil {
+			return err
+		}
+		return os.Remove(filePath("main.go"))
+	}
+	snapshot := func() error {
+		res, err := CommitSnapshot(testDir, NewOsCommand)(logger,
+			context.WithValue(ctx, prevTaskOutputKey, NewTaskResult(TaskSuccess, "")))
+		if err != nil || res.Status != TaskSuccess {
+			return fmt.Errorf("snapshot %v %v", res, err)
+		}
+		return nil
+	}
+	failed := NewTaskResult(TaskFailure, "Tests FAIL")
+	failed.Failed = []string{"TestMax"}
+	changed := map[string][]byte{"math.go": mathgo_add_func, "geo.go": geogo, "main.go": nil}
+	cases := []struct {
+		desc   string
+		in     *TaskResult
+		setup  func() error
+		status TaskStatus
+		// files after revert, nil if removed
+		files map[string][]byte
+	}{
+		{
+			desc:   "Tests passed",
+			in:     NewTaskResult(TaskSuccess, "Tests PASS"),
+			setup:  change,
+			status: TaskSkipped,
+			files:  changed,
+		},
+		{
+			desc:   "Strategy error",
+			in:     NewTaskResult(TaskError, "strategy error"),
+			status: TaskSkipped,
+			files:  changed,
+		},
+		{
+			desc:   "No green snapshot",
+			in:     failed,
+			status: TaskSkipped,
+			files:  changed,
+		},
+		{
+			desc: "Revert changes since snapshot",
+			in:   failed,
+			setup: func() error {
+				err := NewGitCmd(testDir)("checkout", "--", "main.go", "math.go")
+				if err != nil {
+					return err
+				}
+				err = os.Remove(filePath("geo.go"))
+				if err != nil {
+					return err
+				}
+				// not tracked file of green state
+				err = ioutil.WriteFile(filePath("notes.txt"), []byte("notes"), 0600)
+				if err != nil {
+					return err
+				}
+				err = snapshot()
+				if err != nil {
+					return err
+				}
+				return change()
+			},
+			status: TaskSuccess,
+			files: map[string][]byte{"math.go": mathgo, "geo.go": nil, "main.go": maingo,
+				"notes.txt": []byte("notes"), "debug.log": []byte("log")},
+		},
+		{
+			desc:   "Nothing changed since snapshot",
+			in:     failed,
+			status: TaskSkipped,
+			files:  map[string][]byte{"math.go": mathgo, "geo.go": nil, "main.go": maingo},
+		},
+		{
+			desc: "Revert to latest snapshot",
+			in:   failed,
+			setup: func() error {
+				err := ioutil.WriteFile(filePath("geo.go"), geogo, 0600)
+				if err != nil {
+					return err
+				}
+				err = snapshot()
+				if err != nil {
+					return err
+				}
+				return ioutil.WriteFile(filePath("geo.go"), geo_add_area, 0600)
+			},
+			status: TaskSuccess,
+			files: map[string][]byte{"math.go": mathgo, "geo.go": geogo, "main.go": maingo,
+				"notes.txt": []byte("notes")},
+		},
+	}
+	for i, tc := range cases {
+		execTestHelper(t, i, tc.desc, tc.setup)
+		before := map[string][]byte{}
+		for fname := range tc.files {
+			before[fname], _ = ioutil.ReadFile(filePath(fname))
+		}
+		res, err := RevertChanges(testDir, NewOsCommand)(logger,
+			context.WithValue(ctx, prevTaskOutputKey, tc.in))
+		if isUnexpectedErr(t, i, tc.desc, nil, err) {
+			continue
+		}
+		if res.Status != tc.status {
+			t.Errorf("case [%d] %s\nexpected status %v, got %v %s", i, tc.desc, tc.status, res.Status, res.Summary)
+			continue
+		}
+		for fname, data := range tc.files {
+			got, err := ioutil.ReadFile(filePath(fname))
+			if data == nil && !os.IsNotExist(err) {
+				t.Errorf("case [%d] %s\nexpected %s removed, got %v", i, tc.desc, fname, err)
+			} else if data != nil && !bytes.Equal(data, got) {
+				t.Errorf("case [%d] %s\nexpected %s\n%s\ngot\n%s", i, tc.desc, fname, data, got)
+			}
+		}
+		if _, err := os.Stat(filePath(filepath.Join(".gtr", "profile"))); err != nil {
+			t.Errorf("case [%d] %s\nexpected gtr files kept, got %v", i, tc.desc, err)
+		}
+		if tc.status != TaskSuccess {
+			continue
+		}
+		if !strings.Contains(res.Summary, "failed TestMax") {
+			t.Errorf("case [%d] %s\nexpected failed tests in summary, got %s", i, tc.desc, res.Summary)
+		}
+		// rejected changes can be applied back
+		patch := res.Summary[strings.LastIndex(res.Summary, " ")+1:]
+		execTestHelper(t, i, tc.desc, func() error {
+			return NewGitCmd(testDir)("apply", patch)
+		})
+		for fname, data := range before {
+			got, _ := ioutil.ReadFile(filePath(fname))
+			if !bytes.Equal(data, got) {
+				t.Errorf("case [%d] %s\nexpected applied %s\n%s\ngot\n%s", i, tc.desc, fname, data, got)
+			}
+		}
+		// revert applied changes for next case
+		_, err = RevertChanges(testDir, NewOsCommand)(logger,
+			context.WithValue(ctx, prevTaskOutputKey, tc.in))
+		if err != nil {
+			t.Fatalf("case [%d] %s\nrevert error %v", i, tc.desc, err)
+		}
+	}
+	// task canceled on restore of working tree
+	desc := "Revert canceled on restore"
+	execTestHelper(t, len(cases), desc, func() error {
+		err := ioutil.WriteFile(filePath("geo.go"), geo_add_area, 0600)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(filePath("math_add.go"), mathgo_add_func, 0600)
+	})
+	cctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	newCmd := func(ctx context.Context, bin string, args ...string) CommandExecutor {
+		for _, arg := range args {
+			if arg == "restore" {
+				cancel()
+			}
+		}
+		return NewOsCommand(ctx, bin, args...)
+	}
+	res, err := RevertChanges(testDir, newCmd)(logger,
+		context.WithValue(cctx, prevTaskOutputKey, failed))
+	if err != nil || res.Status != TaskSuccess {
+		t.Fatalf("%s\nexpected success, got %v %v", desc, res, err)
+	}
+	if got, _ := ioutil.ReadFile(filePath("geo.go")); !bytes.Equal(geogo, got) {
+		t.Errorf("%s\nexpected geo.go reverted, got\n%s", desc, got)
+	}
+	if _, err := os.Stat(filePath("math_add.go")); !os.IsNotExist(err) {
+		t.Errorf("%s\nexpected math_add.go removed, got %v", desc, err)
+	}
+}
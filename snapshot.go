@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// wipRefPrefix is prefix of refs snapshots of branches are committed to
+const wipRefPrefix = "refs/gtr/wip/"
+
+// CommitSnapshot returns task to commit working tree snapshot
+// to refs/gtr/wip/<branch>, index, working tree and checked out
+// branch are not changed
+func CommitSnapshot(
+	workDir string,
+	newCmd CommandCreator,
+) func(*log.Logger, context.Context) (*TaskResult, error) {
+	return func(log *log.Logger, ctx context.Context) (*TaskResult, error) {
+		prev := prevTaskResult(ctx)
+		if !prev.Success() {
+			return NewTaskResult(TaskSkipped, "nothing to snapshot"), nil
+		}
+		ref, err := wipRef(ctx, newCmd, workDir)
+		if err != nil {
+			return NewTaskResult(TaskError, fmt.Sprintf("Snapshot error %v", err)), nil
+		}
+		// chain snapshots, first one starts from HEAD
+		parent := ref
+		_, err = gitOutput(ctx, newCmd, workDir, nil,
+			"rev-parse", "-q", "--verify", ref+"^{commit}")
+		if err != nil {
+			parent = "HEAD"
+		}
+		tree, err := snapshotTree(ctx, newCmd, workDir, parent)
+		if err != nil {
+			return NewTaskResult(TaskError, fmt.Sprintf("Snapshot error %v", err)), nil
+		}
+		parentTree, err := gitOutput(ctx, newCmd, workDir, nil, "rev-parse", parent+"^{tree}")
+		if err != nil {
+			return NewTaskResult(TaskError, fmt.Sprintf("Snapshot error %v", err)), nil
+		}
+		if tree == parentTree {
+			return NewTaskResult(TaskSkipped, "nothing to snapshot"), nil
+		}
+		msg := "gtr snapshot"
+		if prev.Summary != "" {
+			msg += "\n\n" + prev.Summary
+		}
+		commit, err := gitOutput(ctx, newCmd, workDir, nil,
+			"commit-tree", tree, "-p", parent, "-m", msg)
+		if err != nil {
+			return NewTaskResult(TaskError, fmt.Sprintf("Snapshot error %v", err)), nil
+		}
+		_, err = gitOutput(ctx, newCmd, workDir, nil,
+			"update-ref", "-m", "gtr snapshot", ref, commit)
+		if err != nil {
+			return NewTaskResult(TaskError, fmt.Sprintf("Snapshot error %v", err)), nil
+		}
+		return NewTaskResult(TaskSuccess, ref+" "+commit), nil
+	}
+}
+
+// snapshotTree writes tree of working tree files, not ignored,
+// using temporary index seeded from parent
+func snapshotTree(ctx context.Context, newCmd CommandCreator,
+	workDir, parent string) (string, error) {
+	f, err := ioutil.TempFile("", "gtr-index")
+	if err != nil {
+		return "", err
+	}
+	// git creates index, empty file is not valid index
+	_ = f.Close()
+	_ = os.Remove(f.Name())
+	defer os.Remove(f.Name())
+	env := []string{"GIT_INDEX_FILE=" + f.Name()}
+	_, err = gitOutput(ctx, newCmd, workDir, env, "read-tree", parent)
+	if err != nil {
+		return "", err
+	}
+	// gtr files in workDir are not part of snapshot
+	_, err = gitOutput(ctx, newCmd, workDir, env, "add", "-A", "--", ":/", ":!.gtr")
+	if err != nil {
+		return "", err
+	}
+	return gitOutput(ctx, newCmd, workDir, env, "write-tree")
+}
+
+// wipRef returns snapshots ref of checked out branch
+func wipRef(ctx context.Context, newCmd CommandCreator, workDir string) (string, error) {
+	branch, err := gitOutput(ctx, newCmd, workDir, nil, "symbolic-ref", "--short", "-q", "HEAD")
+	if err != nil || branch == "" {
+		// detached HEAD
+		return wipRefPrefix + "HEAD", nil
+	}
+	return wipRefPrefix + branch, nil
+}
+
+// gitOutput runs git in workDir with env added
+// and returns trimmed output
+func gitOutput(ctx context.Context, newCmd CommandCreator,
+	workDir string, env []string, args ...string) (string, error) {
+	out, err := gitRun(ctx, newCmd, workDir, env, args...)
+	return strings.TrimSpace(string(out)), err
+}
+
+// gitRun runs git in workDir with env added and returns output
+func gitRun(ctx context.Context, newCmd CommandCreator,
+	workDir string, env []string, args ...string) ([]byte, error) {
+	var out, errOut bytes.Buffer
+	cmd := newCmd(ctx, "git", append([]string{"-C", workDir}, args...)...)
+	cmd.SetStdout(&out)
+	cmd.SetStderr(&errOut)
+	if env != nil {
+		cmd.SetEnv(append(os.Environ(), env...))
+	}
+	err := cmd.Run()
+	if err != nil {
+		return nil, fmt.Errorf("git %s error %v %s", args[0], err,
+			strings.TrimSpace(errOut.String()))
+	}
+	return out.Bytes(), nil
+}
+
+// snapshots lists, diffs or restores snapshots of checked out
+// branch by cfg.cmdArgs and returns exit code
+func snapshots(ctx context.Context, cfg config, out io.Writer) int {
+	action := "list"
+	args := cfg.cmdArgs
+	if len(args) > 0 {
+		action, args = args[0], args[1:]
+	}
+	ref, err := wipRef(ctx, NewOsCommand, cfg.workDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "snapshots error %v\n", err)
+		return exitError
+	}
+	_, err = gitOutput(ctx, NewOsCommand, cfg.workDir, nil,
+		"rev-parse", "-q", "--verify", ref+"^{commit}")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "no snapshots in %s\n", ref)
+		return exitError
+	}
+	rev := ref
+	if len(args) > 0 {
+		rev = snapshotRev(ref, args[0])
+	}
+	var gitArgs []string
+	switch action {
+	case "list":
+		// snapshots not in checked out branch, numbered from latest
+		gitArgs = []string{"log", "--first-parent", "--date=iso",
+			"--format=%h %cd %s", ref, "--not", "HEAD"}
+	case "diff":
+		// snapshot against working tree, with not tracked files
+		tree, err := snapshotTree(ctx, NewOsCommand, cfg.workDir, "HEAD")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "snapshots diff error %v\n", err)
+			return exitError
+		}
+		gitArgs = []string{"diff", rev, tree}
+	case "restore":
+		// working tree files, index, HEAD and gtr files are kept
+		gitArgs = []string{"restore", "--source", rev, "--worktree", "--", ".", ":!.gtr"}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown snapshots action %s\n", action)
+		return exitError
+	}
+	output, err := gitOutput(ctx, NewOsCommand, cfg.workDir, nil, gitArgs...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "snapshots %s error %v\n", action, err)
+		return exitError
+	}
+	if action == "list" {
+		output = numberLines(output)
+	}
+	if output != "" {
+		fmt.Fprintln(out, output)
+	}
+	return exitOK
+}
+
+// snapshotRev returns revision of snapshot, N is
+// N-th snapshot before latest, otherwise a commit
+func snapshotRev(ref, snapshot string) string {
+	if n, err := strconv.Atoi(snapshot); err == nil && n >= 0 {
+		return ref + "~" + snapshot
+	}
+	return snapshot
+}
+
+// numberLines prefixes lines with their index
+func numberLines(text string) string {
+	if text == "" {
+		return ""
+	}
+	lines := strings.Split(text, "\n")
+	for i := range lines {
+		lines[i] = strconv.Itoa(i) + " " + lines[i]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCommitSnapshotTask(t *testing.T) {
+	testDir := filepath.Join(os.TempDir(), "test_commit_snapshot_task")
+	filePath := func(fname string) string {
+		return filepath.Join(testDir, fname)
+	}
+	gtrFile := filepath.Join(".gtr", "config")
+	setupTestGitDir(t, testDir,
+		map[string][]byte{"main.go": maingo, ".gitignore": []byte("*.log\n"),
+			gtrFile: []byte("tracked")},
+		[]string{"main.go", ".gitignore", gtrFile},
+	)
+	defer func() {
+		if !t.Failed() {
+			_ = os.RemoveAll(testDir)
+		}
+	}()
+	ctx := context.Background()
+	git := func(args ...string) string {
+		out, err := gitOutput(ctx, NewOsCommand, testDir, nil, args...)
+		if err != nil {
+			t.Fatalf("git %v error %v", args, err)
+		}
+		return out
+	}
+	head := git("rev-parse", "HEAD")
+	cases := []struct {
+		desc   string
+		in     *TaskResult
+		setup  func() error
+		status TaskStatus
+		parent string // HEAD or n-th snapshot
+		files  []string
+	}{
+		{
+			desc: "Tests failed",
+			in:   NewTaskResult(TaskFailure, "Tests FAIL"),
+			setup: func() error {
+				return ioutil.WriteFile(filePath("math.go"), mathgo, 0600)
+			},
+			status: TaskSkipped,
+		},
+		{
+			desc: "Snapshot of new file",
+			in:   NewTaskResult(TaskSuccess, "Tests PASS"),
+			setup: func() error {
+				return ioutil.WriteFile(filePath("out.log"), []byte("ignored"), 0600)
+			},
+			status: TaskSuccess,
+			parent: "HEAD",
+			files:  []string{".gitignore", ".gtr", "main.go", "math.go"},
+		},
+		{
+			desc:   "No changes since snapshot",
+			in:     NewTaskResult(TaskSuccess, "Tests PASS"),
+			status: TaskSkipped,
+		},
+		{
+			desc: "Snapshot of changed and deleted files",
+			in:   NewTaskResult(TaskSuccess, "Tests PASS"),
+			setup: func() error {
+				err := ioutil.WriteFile(filePath("math.go"), mathgo_add_func, 0600)
+				if err != nil {
+					return err
+				}
+				return os.Remove(filePath("main.go"))
+			},
+			status: TaskSuccess,
+			parent: "1",
+			files:  []string{".gitignore", ".gtr", "math.go"},
+		},
+	}
+	logger := log.New(ioutil.Discard, "", 0)
+	ref := wipRefPrefix + git("symbolic-ref", "--short", "HEAD")
+	for i, tc := range cases {
+		execTestHelper(t, i, tc.desc, tc.setup)
+		res, err := CommitSnapshot(testDir, NewOsCommand)(logger,
+			context.WithValue(ctx, prevTaskOutputKey, tc.in))
+		if isUnexpectedErr(t, i, tc.desc, nil, err) {
+			continue
+		}
+		if res.Status != tc.status {
+			t.Errorf("case [%d] %s\nexpected status %v, got %v %s", i, tc.desc, tc.status, res.Status, res.Summary)
+			continue
+		}
+		if tc.status != TaskSuccess {
+			continue
+		}
+		parent := head
+		if tc.parent != "HEAD" {
+			parent = git("rev-parse", snapshotRev(ref, tc.parent))
+		}
+		if p := git("rev-parse", ref+"^"); p != parent {
+			t.Errorf("case [%d] %s\nexpected parent %s, got %s", i, tc.desc, parent, p)
+		}
+		if files := git("ls-tree", "--name-only", ref); files != strings.Join(tc.files, "\n") {
+			t.Errorf("case [%d] %s\nexpected files %v, got %v", i, tc.desc, tc.files, files)
+		}
+		// branch and index are not changed
+		if h := git("rev-parse", "HEAD"); h != head {
+			t.Errorf("case [%d] %s\nexpected HEAD %s, got %s", i, tc.desc, head, h)
+		}
+		if staged := git("diff", "--cached", "--name-only"); staged != "" {
+			t.Errorf("case [%d] %s\nexpected empty index changes, got %s", i, tc.desc, staged)
+		}
+	}
+
+	cfg := newConfig()
+	cfg.workDir = testDir
+	var out bytes.Buffer
+	if code := snapshots(ctx, cfg, &out); code != exitOK {
+		t.Fatalf("snapshots list exit code %d", code)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "0 ") ||
+		!strings.HasSuffix(lines[1], "gtr snapshot") {
+		t.Errorf("expected 2 snapshots listed, got %s", out.String())
+	}
+	// tracked gtr files are not restored
+	err := ioutil.WriteFile(filePath(gtrFile), []byte("changed"), 0600)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	cfg.cmdArgs = []string{"restore", "1"}
+	if code := snapshots(ctx, cfg, &out); code != exitOK {
+		t.Fatalf("snapshots restore exit code %d", code)
+	}
+	if data, _ := ioutil.ReadFile(filePath(gtrFile)); string(data) != "changed" {
+		t.Errorf("expected gtr file kept, got %s", data)
+	}
+	data, err := ioutil.ReadFile(filePath("math.go"))
+	if err != nil || !bytes.Equal(data, mathgo) {
+		t.Errorf("expected restored math.go, got %s %v", data, err)
+	}
+	cfg.cmdArgs = []string{"diff", "1"}
+	out.Reset()
+	if code := snapshots(ctx, cfg, &out); code != exitOK {
+		t.Fatalf("snapshots diff exit code %d", code)
+	}
+	if out.Len() != 0 {
+		t.Errorf("expected no diff with restored snapshot, got %s", out.String())
+	}
+}
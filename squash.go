@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// reAutoCommit matches subject of auto commits made
+// with default template before autoCommitTrailer
+var reAutoCommit = regexp.MustCompile(`^'?auto_commit!`)
+
+// testsTrailer prefixes line of commit message with passed tests
+const testsTrailer = "Tests: "
+
+// errNothingToSquash returned when there are less than
+// two auto commits to squash
+var errNothingToSquash = errors.New("nothing to squash")
+
+// autoCommit is commit of git log
+type autoCommit struct {
+	hash    string
+	parents []string
+	message string
+}
+
+// squash squashes auto commits into one and returns exit code
+func squash(ctx context.Context, cfg config, out io.Writer) int {
+	var base string
+	if len(cfg.cmdArgs) > 0 {
+		base = cfg.cmdArgs[0]
+	}
+	msg, err := SquashAutoCommits(ctx, cfg.workDir, base, NewOsCommand)
+	if err == errNothingToSquash {
+		fmt.Fprintln(out, err)
+		return exitOK
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "squash error %v\n", err)
+		return exitError
+	}
+	fmt.Fprintln(out, msg)
+	return exitOK
+}
+
+// SquashAutoCommits replaces consecutive auto commits since last
+// manual commit, or since base if not empty, with one commit and
+// returns its message, fails if base..HEAD has other commits,
+// index and working tree are not changed
+func SquashAutoCommits(ctx context.Context, workDir, base string,
+	newCmd CommandCreator) (string, error) {
+	head, err := gitOutput(ctx, newCmd, workDir, nil, "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	rev := head
+	if base != "" {
+		rev = base + ".." + head
+	}
+	commits, err := logCommits(ctx, newCmd, workDir, rev)
+	if err != nil {
+		return "", err
+	}
+	n := 0
+	for _, c := range commits {
+		if !isAutoCommit(c.message) || len(c.parents) != 1 {
+			if base != "" {
+				return "", fmt.Errorf("refusing to squash, %s is not auto commit: %s",
+					c.hash[:7], strings.SplitN(c.message, "\n", 2)[0])
+			}
+			break
+		}
+		n++
+	}
+	if n < 2 {
+		return "", errNothingToSquash
+	}
+	commits = commits[:n]
+	parent := commits[n-1].parents[0]
+	msg, err := squashMessage(ctx, workDir, parent, commits)
+	if err != nil {
+		return "", err
+	}
+	commit, err := gitOutput(ctx, newCmd, workDir, nil,
+		"commit-tree", head+"^{tree}", "-p", parent, "-m", msg)
+	if err != nil {
+		return "", err
+	}
+	// fails if HEAD moved meanwhile
+	_, err = gitOutput(ctx, newCmd, workDir, nil,
+		"update-ref", "-m", "gtr squash", "HEAD", commit, head)
+	if err != nil {
+		return "", err
+	}
+	return msg, nil
+}
+
+// logCommits returns commits of rev from latest
+func logCommits(ctx context.Context, newCmd CommandCreator,
+	workDir, rev string) ([]autoCommit, error) {
+	out, err := gitOutput(ctx, newCmd, workDir, nil,
+		"log", "--format=%H%x00%P%x00%B%x01", rev)
+	if err != nil {
+		return nil, err
+	}
+	var commits []autoCommit
+	for _, record := range strings.Split(out, "\x01") {
+		fields := strings.SplitN(strings.TrimSpace(record), "\x00", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		commits = append(commits, autoCommit{
+			hash:    fields[0],
+			parents: strings.Fields(fields[1]),
+			message: strings.TrimSpace(fields[2]),
+		})
+	}
+	return commits, nil
+}
+
+// isAutoCommit checks if commit message has auto commit
+// trailer in last paragraph or auto commit subject
+func isAutoCommit(msg string) bool {
+	if reAutoCommit.MatchString(msg) {
+		return true
+	}
+	id := strings.LastIndex(msg, "\n\n")
+	if id == -1 {
+		return false
+	}
+	for _, line := range strings.Split(msg[id+2:], "\n") {
+		if strings.TrimSpace(line) == autoCommitTrailer {
+			return true
+		}
+	}
+	return false
+}
+
+// squashMessage returns message with changed blocks of go files
+// between parent and HEAD and tests passed in commits
+func squashMessage(ctx context.Context, workDir, parent string,
+	commits []autoCommit) (string, error) {
+	gitCmd := NewGitCMDWithBase(workDir, parent+"..HEAD")
+	changes, err := gitCmd.Diff(ctx)
+	if err != nil {
+		return "", err
+	}
+	fileInfos := map[string]FileInfo{}
+	n := 0
+	for _, change := range changes {
+		if !strings.HasSuffix(change.fpath, ".go") {
+			continue
+		}
+		changes[n] = change
+		n++
+		if _, ok := fileInfos[change.fpath]; ok {
+			continue
+		}
+		src, err := gitCmd.Content(ctx, change.fpath)
+		if err != nil {
+			return "", err
+		}
+		info, err := getFileInfo(filepath.Join(workDir, change.fpath), src)
+		if err != nil {
+			return "", err
+		}
+		fileInfos[change.fpath] = info
+	}
+	changedBlocks, err := changesToFileBlocks(changes[:n], fileInfos)
+	if err != nil {
+		return "", err
+	}
+	var fnames []string
+	for fname := range changedBlocks {
+		fnames = append(fnames, fname)
+	}
+	sort.Strings(fnames)
+	lines := []string{"Squash " + strconv.Itoa(len(commits)) + " auto commits", ""}
+	for _, fname := range fnames {
+		names := map[string]bool{}
+		for _, block := range changedBlocks[fname].blocks {
+			names[block.name] = true
+		}
+		list := mapStrToSlice(names)
+		sort.Strings(list)
+		lines = append(lines, fname+": "+strings.Join(list, " "))
+	}
+	tests := map[string]bool{}
+	for _, c := range commits {
+		for _, line := range strings.Split(c.message, "\n") {
+			if strings.HasPrefix(line, testsTrailer) {
+				for _, name := range strings.Fields(line[len(testsTrailer):]) {
+					tests[name] = true
+				}
+			}
+		}
+	}
+	if len(tests) > 0 {
+		list := mapStrToSlice(tests)
+		sort.Strings(list)
+		lines = append(lines, "", testsTrailer+strings.Join(list, " "))
+	}
+	return strings.Join(lines, "\n"), nil
+}
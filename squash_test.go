@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSquashAutoCommits(t *testing.T) {
+	testDir := filepath.Join(os.TempDir(), "test_squash_auto_commits")
+	filePath := func(fname string) string {
+		return filepath.Join(testDir, fname)
+	}
+	setupTestGitDir(t, testDir,
+		map[string][]byte{"main.go": maingo},
+		[]string{"main.go"},
+	)
+	defer func() {
+		if !t.Failed() {
+			_ = os.RemoveAll(testDir)
+		}
+	}()
+	ctx := context.Background()
+	git := func(args ...string) string {
+		out, err := gitOutput(ctx, NewOsCommand, testDir, nil, args...)
+		if err != nil {
+			t.Fatalf("git %v error %v", args, err)
+		}
+		return out
+	}
+	logger := log.New(ioutil.Discard, "", 0)
+	autoCommitTmpl := func(tmpl, fname string, data []byte, tests ...string) func() error {
+		return func() error {
+			err := ioutil.WriteFile(filePath(fname), data, 0600)
+			if err != nil {
+				return err
+			}
+			in := NewTaskResult(TaskSuccess, "Tests PASS")
+			in.Meta[metaTests] = tests
+			opts, err := NewCommitOptions(tmpl, false, "", false, nil)
+			if err != nil {
+				return err
+			}
+			_, err = CommitChanges(testDir, opts, NewOsCommand)(logger,
+				context.WithValue(ctx, prevTaskOutputKey, in))
+			return err
+		}
+	}
+	autoCommit := func(fname string, data []byte, tests ...string) func() error {
+		return autoCommitTmpl("", fname, data, tests...)
+	}
+	cases := []struct {
+		desc   string
+		steps  []func() error
+		base   string
+		output string
+		err    error
+		// rev of manual commit squash refuses to include
+		refused string
+		// rev of squash commit parent, first commit if empty
+		parent string
+	}{
+		{
+			desc:  "One auto commit",
+			steps: []func() error{autoCommit("math.go", mathgo, "module.TestMin")},
+			err:   errNothingToSquash,
+		},
+		{
+			desc: "Auto commits since manual commit",
+			steps: []func() error{
+				autoCommit("math.go", mathgo_add_func, "module.TestMax"),
+				autoCommit("math_test.go", math_test_go, "module.TestMin", "module.TestMax"),
+			},
+			output: `Squash 3 auto commits
+
+math.go: PI max min sub
+math_test.go: TestMin
+
+Tests: module.TestMax module.TestMin`,
+		},
+		{
+			desc: "Manual commit in range of base",
+			steps: []func() error{
+				autoCommit("geo.go", geogo, "module.TestArea"),
+				func() error {
+					_ = ioutil.WriteFile(filePath("geo.go"), geo_add_area, 0600)
+					return NewGitCmd(testDir)("commit", "-am", "manual")
+				},
+				autoCommit("geo.go", geo_area_func_rename, "module.TestArea"),
+			},
+			base:    "HEAD~3",
+			refused: "HEAD~1",
+		},
+		{
+			desc: "Auto commits with custom template",
+			steps: []func() error{
+				autoCommitTmpl("wip {{join .Files \",\"}}", "math.go", mathgo, "module.TestMin"),
+				autoCommitTmpl("wip {{join .Files \",\"}}", "geo.go", geo_add_area, "module.TestArea"),
+			},
+			parent: "HEAD~3",
+			output: `Squash 3 auto commits
+
+math.go: min
+
+Tests: module.TestArea module.TestMin`,
+		},
+	}
+	manual := git("rev-parse", "HEAD")
+	for i, tc := range cases {
+		for _, step := range tc.steps {
+			execTestHelper(t, i, tc.desc, step)
+		}
+		tree := git("rev-parse", "HEAD^{tree}")
+		parent := manual
+		if tc.parent != "" {
+			parent = git("rev-parse", tc.parent)
+		}
+		if tc.refused != "" {
+			tc.err = fmt.Errorf("refusing to squash, %s is not auto commit: manual",
+				git("rev-parse", "--short=7", tc.refused))
+		}
+		output, err := SquashAutoCommits(ctx, testDir, tc.base, NewOsCommand)
+		if isUnexpectedErr(t, i, tc.desc, tc.err, err) || err != nil {
+			continue
+		}
+		if tc.output != output {
+			t.Errorf("case [%d] %s\nexpected %s\ngot %s", i, tc.desc, tc.output, output)
+		}
+		if got := git("rev-parse", "HEAD^"); got != parent {
+			t.Errorf("case [%d] %s\nexpected parent %s, got %s", i, tc.desc, parent, got)
+		}
+		if squashedTree := git("rev-parse", "HEAD^{tree}"); squashedTree != tree {
+			t.Errorf("case [%d] %s\nexpected tree %s, got %s", i, tc.desc, tree, squashedTree)
+		}
+	}
+}
@@ -0,0 +1,801 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/gob"
+	"encoding/hex"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+// version of index file format
+const ssaIndexVersion = 4
+
+// ssaIndexFile is file in .gtr dir to store index between gtr runs
+const ssaIndexFile = ".ssa_index"
+
+// ssaCache keeps module import graph and tests reachability
+// index of analyzed packages between runs, package index is
+// invalidated when sources of package or its dependencies change.
+// Index is stored in .gtr dir and loaded on start
+type ssaCache struct {
+	workDir    string
+	flags      string // analysis and build flags index depends on
+	moduleName string
+	modHash    string                // hash of go.mod and go.sum
+	files      map[string]*fileState // by file path relative to workDir
+	pkgs       map[string]*pkgNode   // by pkg path
+	index      map[string]*pkgIndex  // by pkg path
+}
+
+// fileState of go source file
+type fileState struct {
+	pkgPath string
+	xtest   bool // in external test package
+	modTime time.Time
+	size    int64
+	hash    string
+}
+
+// pkgNode is module package in import graph
+type pkgNode struct {
+	dir         string // relative to workDir
+	hash        string // hash of package go files
+	imports     []string
+	testImports []string
+}
+
+// pkgIndex is reachability index of package tests
+type pkgIndex struct {
+	// Hash of package and its dependencies sources
+	Hash string
+	// Analysis used, less precise than configured
+	// one when it exceeded budget
+	Analysis string
+	Tests    []*testIndex
+	// Refs package level var, const or type key ->
+	// keys of package funcs using it
+	Refs map[string][]string
+}
+
+// testIndex is func with *testing.T or *testing.M param
+// and module funcs reachable from it
+type testIndex struct {
+	// Name of func, TestA, helper or TestA$1 for closures
+	Name string
+	Pkg  string
+	// SubTests t.Run func name -> subtest name
+	SubTests map[string]string
+	// Funcs by func key
+	Funcs map[string]reachedFunc
+}
+
+// reachedFunc is func reached from test
+type reachedFunc struct {
+	// Caller is func key of nearest module caller
+	Caller string
+	// Name is full func name
+	Name string
+}
+
+// ssaIndex is stored tests index
+type ssaIndex struct {
+	Version int
+	Flags   string
+	Index   map[string]*pkgIndex
+}
+
+func newSSACache(workDir, flags string) *ssaCache {
+	return &ssaCache{workDir: workDir, flags: flags}
+}
+
+// buildFlags returns analysis with build environment
+// which affect call graph
+func buildFlags(analysis string) string {
+	flags := []string{analysis, runtime.Version()}
+	for _, name := range []string{"GOOS", "GOARCH", "GOFLAGS", "CGO_ENABLED"} {
+		flags = append(flags, name+"="+os.Getenv(name))
+	}
+	return strings.Join(flags, " ")
+}
+
+// reset drops import graph, index entries are
+// invalidated by hash
+func (c *ssaCache) reset() {
+	c.files = map[string]*fileState{}
+	c.pkgs = map[string]*pkgNode{}
+}
+
+// load reads stored index, index is empty if it is
+// missing or stored with other flags
+func (c *ssaCache) load() map[string]*pkgIndex {
+	index := map[string]*pkgIndex{}
+	data, err := ioutil.ReadFile(filepath.Join(c.workDir, ".gtr", ssaIndexFile))
+	if err != nil {
+		return index
+	}
+	var stored ssaIndex
+	err = gob.NewDecoder(bytes.NewReader(data)).Decode(&stored)
+	if err != nil || stored.Version != ssaIndexVersion || stored.Flags != c.flags {
+		return index
+	}
+	for path, idx := range stored.Index {
+		index[path] = idx
+	}
+	return index
+}
+
+// save stores index of module packages
+func (c *ssaCache) save() error {
+	stored := ssaIndex{
+		Version: ssaIndexVersion,
+		Flags:   c.flags,
+		Index:   map[string]*pkgIndex{},
+	}
+	for path, idx := range c.index {
+		if _, ok := c.pkgs[path]; ok {
+			stored.Index[path] = idx
+		}
+	}
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(stored)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(c.workDir, ".gtr")
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return err
+	}
+	// write whole file or nothing
+	tmp := filepath.Join(dir, ssaIndexFile+".tmp")
+	err = ioutil.WriteFile(tmp, buf.Bytes(), 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, ssaIndexFile))
+}
+
+// update rehashes module sources and reloads imports
+// of packages which files changed
+func (c *ssaCache) update(ctx context.Context) error {
+	moduleName, err := getModuleName(c.workDir)
+	if err != nil {
+		return err
+	}
+	modHash := ""
+	for _, name := range []string{"go.mod", "go.sum"} {
+		data, err := ioutil.ReadFile(filepath.Join(c.workDir, name))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		modHash += hashBytes(data)
+	}
+	if c.pkgs == nil || moduleName != c.moduleName || modHash != c.modHash {
+		// dependencies changed
+		c.reset()
+		c.moduleName = moduleName
+		c.modHash = modHash
+	}
+	if c.index == nil {
+		c.index = c.load()
+	}
+
+	dirs, err := c.hashDirs()
+	if err != nil {
+		return err
+	}
+	var toLoad []string
+	for path, node := range c.pkgs {
+		if _, ok := dirs[node.dir]; !ok {
+			// package removed
+			delete(c.pkgs, path)
+		}
+	}
+	for dir, hash := range dirs {
+		path := c.dirPkgPath(dir)
+		node := c.pkgs[path]
+		if node != nil && node.hash == hash {
+			continue
+		}
+		c.pkgs[path] = &pkgNode{dir: dir, hash: hash}
+		toLoad = append(toLoad, path)
+	}
+	if len(toLoad) == 0 {
+		return nil
+	}
+	return c.loadImports(ctx, toLoad)
+}
+
+// hashDirs returns hashes of module dirs with go files
+// and updates files state
+func (c *ssaCache) hashDirs() (map[string]string, error) {
+	dirFiles := map[string][]string{}
+	seen := map[string]bool{}
+	err := filepath.Walk(c.workDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(c.workDir, path)
+		if err != nil {
+			return err
+		}
+		name := info.Name()
+		if info.IsDir() {
+			if rel == "." {
+				return nil
+			}
+			// skipped by go tool
+			if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") ||
+				name == "testdata" || name == "vendor" {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+				// nested module
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(name, ".go") {
+			return nil
+		}
+		dir := filepath.Dir(rel)
+		seen[rel] = true
+		state := c.files[rel]
+		if state == nil || !state.modTime.Equal(info.ModTime()) || state.size != info.Size() {
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			state = &fileState{
+				pkgPath: c.dirPkgPath(dir),
+				xtest:   isXTestFile(path, data),
+				modTime: info.ModTime(),
+				size:    info.Size(),
+				hash:    hashBytes(data),
+			}
+			c.files[rel] = state
+		}
+		dirFiles[dir] = append(dirFiles[dir], rel+" "+state.hash)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for rel := range c.files {
+		if !seen[rel] {
+			delete(c.files, rel)
+		}
+	}
+	dirs := map[string]string{}
+	for dir, files := range dirFiles {
+		sort.Strings(files)
+		dirs[dir] = hashBytes([]byte(strings.Join(files, "\n")))
+	}
+	return dirs, nil
+}
+
+// loadImports loads imports of packages and their tests
+func (c *ssaCache) loadImports(ctx context.Context, pkgPaths []string) error {
+	cfg := &packages.Config{
+		Context: ctx,
+		Dir:     c.workDir,
+		Mode:    packages.NeedName | packages.NeedImports,
+		Tests:   true,
+	}
+	pkgs, err := packages.Load(cfg, pkgPaths...)
+	if err != nil {
+		return err
+	}
+	for _, pkg := range pkgs {
+		if strings.HasSuffix(pkg.PkgPath, ".test") {
+			// test main
+			continue
+		}
+		node := c.pkgs[testRootPkg(pkg.PkgPath)]
+		if node == nil {
+			continue
+		}
+		var imports []string
+		for path := range pkg.Imports {
+			if c.isModulePkg(path) {
+				imports = append(imports, path)
+			}
+		}
+		if pkg.ID == pkg.PkgPath {
+			node.imports = imports
+		} else {
+			// test variants
+			node.testImports = append(node.testImports, imports...)
+		}
+	}
+	return nil
+}
+
+// dirPkgPath returns module package path of dir
+func (c *ssaCache) dirPkgPath(dir string) string {
+	if dir == "." {
+		return c.moduleName
+	}
+	return c.moduleName + "/" + filepath.ToSlash(dir)
+}
+
+func (c *ssaCache) isModulePkg(path string) bool {
+	return path == c.moduleName || strings.HasPrefix(path, c.moduleName+"/")
+}
+
+// filePkgPath returns package path of file relative to workDir
+func (c *ssaCache) filePkgPath(fname string) string {
+	if state := c.files[fname]; state != nil {
+		return state.pkgPath
+	}
+	return ""
+}
+
+// fileDeclPkgPath returns package path of file declarations,
+// path of external test package has _test suffix as in func keys
+func (c *ssaCache) fileDeclPkgPath(fname string) string {
+	state := c.files[fname]
+	if state == nil {
+		return ""
+	}
+	if state.xtest {
+		return state.pkgPath + "_test"
+	}
+	return state.pkgPath
+}
+
+// dependents returns packages which tests may call
+// code of provided packages
+func (c *ssaCache) dependents(pkgPaths []string) []string {
+	var out []string
+	for path := range c.importPaths(pkgPaths) {
+		out = append(out, path)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// importPaths returns shortest import path from each
+// dependent package to one of provided packages
+func (c *ssaCache) importPaths(pkgPaths []string) map[string][]string {
+	importers := map[string][]string{}
+	for path, node := range c.pkgs {
+		for _, imp := range node.imports {
+			importers[imp] = append(importers[imp], path)
+		}
+	}
+	for _, list := range importers {
+		sort.Strings(list)
+	}
+	paths := map[string][]string{}
+	queue := append([]string{}, pkgPaths...)
+	sort.Strings(queue)
+	for _, path := range queue {
+		paths[path] = []string{path}
+	}
+	for len(queue) > 0 {
+		path := queue[0]
+		queue = queue[1:]
+		for _, importer := range importers[path] {
+			if _, ok := paths[importer]; ok {
+				continue
+			}
+			paths[importer] = append([]string{importer}, paths[path]...)
+			queue = append(queue, importer)
+		}
+	}
+	// tests of package may import dependents
+	var testers []string
+	for path := range c.pkgs {
+		testers = append(testers, path)
+	}
+	sort.Strings(testers)
+	testPaths := map[string][]string{}
+	for _, path := range testers {
+		if _, ok := paths[path]; ok {
+			continue
+		}
+		for _, imp := range c.pkgs[path].testImports {
+			if p, ok := paths[imp]; ok {
+				testPaths[path] = append([]string{path}, p...)
+				break
+			}
+		}
+	}
+	for path, p := range testPaths {
+		paths[path] = p
+	}
+	return paths
+}
+
+// testFiles returns test files of package
+// relative to workDir
+func (c *ssaCache) testFiles(pkgPath string) []string {
+	var out []string
+	for rel, state := range c.files {
+		if state.pkgPath == pkgPath && strings.HasSuffix(rel, "_test.go") {
+			out = append(out, rel)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// indexHash returns hash of package with tests and
+// all its dependencies in module
+func (c *ssaCache) indexHash(pkgPath string) string {
+	memo := map[string]string{}
+	var depHash func(path string) string
+	depHash = func(path string) string {
+		if h, ok := memo[path]; ok {
+			return h
+		}
+		node := c.pkgs[path]
+		if node == nil {
+			return ""
+		}
+		hashes := []string{node.hash}
+		for _, imp := range node.imports {
+			hashes = append(hashes, imp+" "+depHash(imp))
+		}
+		sort.Strings(hashes[1:])
+		memo[path] = hashBytes([]byte(strings.Join(hashes, "\n")))
+		return memo[path]
+	}
+	node := c.pkgs[pkgPath]
+	if node == nil {
+		return ""
+	}
+	hashes := []string{c.modHash, depHash(pkgPath)}
+	for _, imp := range node.testImports {
+		hashes = append(hashes, imp+" "+depHash(imp))
+	}
+	sort.Strings(hashes[2:])
+	return hashBytes([]byte(strings.Join(hashes, "\n")))
+}
+
+// outdated returns packages which index is missing or outdated
+func (c *ssaCache) outdated(pkgPaths []string) []string {
+	var out []string
+	for _, path := range pkgPaths {
+		idx := c.index[path]
+		if idx == nil || idx.Hash != c.indexHash(path) {
+			out = append(out, path)
+		}
+	}
+	return out
+}
+
+// imprecise returns indexed packages analyzed
+// with other than analysis
+func (c *ssaCache) imprecise(pkgPaths []string, analysis string) []string {
+	var out []string
+	for _, path := range pkgPaths {
+		idx := c.index[path]
+		if idx != nil && idx.Analysis != analysis {
+			out = append(out, path)
+		}
+	}
+	return out
+}
+
+// indexHashes returns current index hashes of packages
+func (c *ssaCache) indexHashes(pkgPaths []string) map[string]string {
+	hashes := map[string]string{}
+	for _, path := range pkgPaths {
+		hashes[path] = c.indexHash(path)
+	}
+	return hashes
+}
+
+// setIndex stores index of packages analyzed by analysis with
+// sources of hashes, packages without tests stored with empty index
+func (c *ssaCache) setIndex(hashes map[string]string, index map[string]*pkgIndex,
+	analysis string) error {
+	for path, hash := range hashes {
+		idx := index[path]
+		if idx == nil {
+			idx = &pkgIndex{}
+		}
+		idx.Hash = hash
+		idx.Analysis = analysis
+		c.index[path] = idx
+	}
+	return c.save()
+}
+
+// refs returns keys of funcs of packages using
+// package level var, const or type
+func (c *ssaCache) refs(pkgPaths []string, key string) []string {
+	var out []string
+	for _, path := range pkgPaths {
+		if idx := c.index[path]; idx != nil {
+			out = append(out, idx.Refs[key]...)
+		}
+	}
+	return out
+}
+
+// tests returns indexed tests of packages
+func (c *ssaCache) tests(pkgPaths []string) []*testIndex {
+	var out []*testIndex
+	for _, path := range pkgPaths {
+		if idx := c.index[path]; idx != nil {
+			out = append(out, idx.Tests...)
+		}
+	}
+	return out
+}
+
+// indexTests returns reachability index of module tests and
+// references to package level vars and consts by package
+func indexTests(
+	moduleName string, program *ssa.Program,
+	infos map[*types.Package]*types.Info, graph *callgraph.Graph,
+) map[string]*pkgIndex {
+	index := map[string]*pkgIndex{}
+	for root, refs := range globalRefs(moduleName, program, infos) {
+		index[root] = &pkgIndex{Refs: refs}
+	}
+	for tnode, subTests := range getAllTestsInModule(moduleName, graph) {
+		pkgPath := tnode.Func.Pkg.Pkg.Path()
+		root := testRootPkg(pkgPath)
+		idx := index[root]
+		if idx == nil {
+			idx = &pkgIndex{}
+			index[root] = idx
+		}
+		idx.Tests = append(idx.Tests, &testIndex{
+			Name:     tnode.Func.Name(),
+			Pkg:      pkgPath,
+			SubTests: subTests,
+			Funcs:    reachableFuncs(moduleName, tnode),
+		})
+	}
+	for _, idx := range index {
+		sort.Slice(idx.Tests, func(i, j int) bool {
+			if idx.Tests[i].Pkg != idx.Tests[j].Pkg {
+				return idx.Tests[i].Pkg < idx.Tests[j].Pkg
+			}
+			return idx.Tests[i].Name < idx.Tests[j].Name
+		})
+	}
+	return index
+}
+
+// globalRefs returns module funcs by package level var, const
+// or type they use grouped by package, vars found by ssa.Global
+// operands, consts, which are inlined in ssa, and type aliases by
+// types info uses, types by signature and values types too
+func globalRefs(
+	moduleName string, program *ssa.Program, infos map[*types.Package]*types.Info,
+) map[string]map[string][]string {
+	refs := map[string]map[string][]string{}
+	for fn := range ssautil.AllFunctions(program) {
+		key := funcKey(fn)
+		// package initializer stores values, wrappers have no syntax
+		if key == "" || fn.Synthetic != "" || !strings.HasPrefix(key, moduleName) {
+			continue
+		}
+		globals := map[string]bool{}
+		typeKeys(fn.Signature, globals)
+		for _, block := range fn.Blocks {
+			for _, instr := range block.Instrs {
+				if v, ok := instr.(ssa.Value); ok {
+					typeKeys(v.Type(), globals)
+				}
+				for _, op := range instr.Operands(nil) {
+					if g, ok := (*op).(*ssa.Global); ok && g.Pkg != nil {
+						globals[g.Pkg.Pkg.Path()+"."+g.Name()] = true
+					}
+				}
+			}
+		}
+		if info := infos[fn.Package().Pkg]; info != nil && fn.Syntax() != nil {
+			ast.Inspect(fn.Syntax(), func(n ast.Node) bool {
+				if lit, ok := n.(*ast.FuncLit); ok && ast.Node(lit) != fn.Syntax() {
+					// closures are separate funcs
+					return false
+				}
+				id, ok := n.(*ast.Ident)
+				if !ok {
+					return true
+				}
+				switch obj := info.Uses[id].(type) {
+				case *types.Const, *types.TypeName:
+					if obj.Pkg() != nil && obj.Parent() == obj.Pkg().Scope() {
+						globals[obj.Pkg().Path()+"."+obj.Name()] = true
+					}
+				}
+				return true
+			})
+		}
+		root := testRootPkg(fn.Package().Pkg.Path())
+		for global := range globals {
+			if !strings.HasPrefix(global, moduleName) {
+				continue
+			}
+			if refs[root] == nil {
+				refs[root] = map[string][]string{}
+			}
+			refs[root][global] = append(refs[root][global], key)
+		}
+	}
+	for _, pkgRefs := range refs {
+		for global, keys := range pkgRefs {
+			// test variants of package have same keys
+			sort.Strings(keys)
+			n := 0
+			for i := range keys {
+				if i == 0 || keys[i] != keys[n-1] {
+					keys[n] = keys[i]
+					n++
+				}
+			}
+			pkgRefs[global] = keys[:n]
+		}
+	}
+	return refs
+}
+
+// typeKeys adds keys of package level named types used in typ,
+// underlying types of named types are not visited
+func typeKeys(typ types.Type, keys map[string]bool) {
+	switch t := typ.(type) {
+	case *types.Named:
+		obj := t.Obj()
+		if obj.Pkg() != nil && obj.Parent() == obj.Pkg().Scope() {
+			keys[obj.Pkg().Path()+"."+obj.Name()] = true
+		}
+	case *types.Pointer:
+		typeKeys(t.Elem(), keys)
+	case *types.Slice:
+		typeKeys(t.Elem(), keys)
+	case *types.Array:
+		typeKeys(t.Elem(), keys)
+	case *types.Chan:
+		typeKeys(t.Elem(), keys)
+	case *types.Map:
+		typeKeys(t.Key(), keys)
+		typeKeys(t.Elem(), keys)
+	case *types.Signature:
+		if t.Recv() != nil {
+			typeKeys(t.Recv().Type(), keys)
+		}
+		typeKeys(t.Params(), keys)
+		typeKeys(t.Results(), keys)
+	case *types.Tuple:
+		for i := 0; i < t.Len(); i++ {
+			typeKeys(t.At(i).Type(), keys)
+		}
+	}
+}
+
+// reachableFuncs returns module funcs reachable from node
+// with nearest module caller to restore call path
+func reachableFuncs(moduleName string, start *callgraph.Node) map[string]reachedFunc {
+	funcs := map[string]reachedFunc{}
+	caller := map[*callgraph.Node]string{start: ""}
+	queue := []*callgraph.Node{start}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		from := caller[n]
+		key := funcKey(n.Func)
+		if key != "" && strings.HasPrefix(key, moduleName) {
+			if _, ok := funcs[key]; !ok {
+				funcs[key] = reachedFunc{Caller: from, Name: n.Func.String()}
+			}
+			from = key
+		}
+		for _, edge := range n.Out {
+			if _, ok := caller[edge.Callee]; !ok {
+				caller[edge.Callee] = from
+				queue = append(queue, edge.Callee)
+			}
+		}
+	}
+	return funcs
+}
+
+// funcKey returns pkgPath.Func or pkgPath.Recv.Method key
+// matching FileBlock names, instances of generic funcs
+// have key of generic func
+func funcKey(fn *ssa.Function) string {
+	if fn != nil && fn.Origin() != nil {
+		fn = fn.Origin()
+	}
+	if fn == nil || fn.Package() == nil {
+		return ""
+	}
+	name := fn.Name()
+	if recv := fn.Signature.Recv(); recv != nil {
+		typ := recv.Type()
+		if ptr, ok := typ.(*types.Pointer); ok {
+			typ = ptr.Elem()
+		}
+		if named, ok := typ.(*types.Named); ok {
+			name = named.Obj().Name() + "." + name
+		}
+	}
+	return fn.Package().Pkg.Path() + "." + name
+}
+
+// reaches returns first of sorted changed func keys
+// reached by shortest path and the path
+func (ti *testIndex) reaches(changed []string) (string, []string) {
+	var key string
+	var path []string
+	for _, k := range changed {
+		if _, ok := ti.Funcs[k]; !ok {
+			continue
+		}
+		p := ti.callPath(k)
+		if key == "" || len(p) < len(path) {
+			key, path = k, p
+		}
+	}
+	return key, path
+}
+
+// callPath returns func names from test to func
+func (ti *testIndex) callPath(key string) []string {
+	var path []string
+	for key != "" && len(path) <= len(ti.Funcs) {
+		fn := ti.Funcs[key]
+		path = append(path, fn.Name)
+		key = fn.Caller
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// testRootPkg returns package path of test package
+func testRootPkg(pkgPath string) string {
+	pkgPath = strings.TrimSuffix(pkgPath, ".test")
+	return strings.TrimSuffix(pkgPath, "_test")
+}
+
+// isXTestFile checks if package clause of test file
+// declares external test package
+func isXTestFile(fname string, src []byte) bool {
+	if !strings.HasSuffix(fname, "_test.go") {
+		return false
+	}
+	f, err := parser.ParseFile(token.NewFileSet(), fname, src, parser.PackageClauseOnly)
+	return err == nil && strings.HasSuffix(f.Name.Name, "_test")
+}
+
+func hashBytes(data []byte) string {
+	sum := sha1.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// allPkgs returns module packages
+func (c *ssaCache) allPkgs() []string {
+	var out []string
+	for path := range c.pkgs {
+		out = append(out, path)
+	}
+	sort.Strings(out)
+	return out
+}
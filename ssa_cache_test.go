@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSSACacheInvalidation(t *testing.T) {
+	testDir := filepath.Join(os.TempDir(), "test_ssa_cache_invalidation")
+	files := map[string]string{
+		"go.mod": "module cache-test\n\ngo 1.13\n",
+		"pkga/a.go": `package pkga
+
+import "cache-test/pkgb"
+
+func A() int { return pkgb.B() }
+`,
+		"pkgb/b.go": "package pkgb\n\nfunc B() int { return 1 }\n",
+		"pkgc/c.go": "package pkgc\n\nfunc C() int { return 2 }\n",
+		"pkgc/c_test.go": `package pkgc_test
+
+import (
+	"testing"
+
+	"cache-test/pkga"
+)
+
+func TestC(t *testing.T) {
+	if pkga.A() != 1 {
+		t.Error("unexpected result")
+	}
+}
+`,
+	}
+	writeFiles := func(files map[string]string) error {
+		for name, data := range files {
+			fpath := filepath.Join(testDir, name)
+			err := os.MkdirAll(filepath.Dir(fpath), 0700)
+			if err != nil {
+				return err
+			}
+			err = ioutil.WriteFile(fpath, []byte(data), 0600)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	_ = os.RemoveAll(testDir)
+	if err := writeFiles(files); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer func() {
+		if !t.Failed() {
+			_ = os.RemoveAll(testDir)
+		}
+	}()
+	all := []string{"cache-test/pkga", "cache-test/pkgb", "cache-test/pkgc"}
+	cache := newSSACache(testDir, "pointer")
+	cases := []struct {
+		desc       string
+		setup      func() error
+		changed    []string
+		dependents []string
+		outdated   []string
+	}{
+		{desc: "first update", changed: []string{"cache-test/pkgb"},
+			dependents: all, outdated: all},
+		{desc: "no changes", changed: []string{"cache-test/pkga"},
+			dependents: []string{"cache-test/pkga", "cache-test/pkgc"}},
+		{
+			desc: "change leaf package",
+			setup: func() error {
+				return writeFiles(map[string]string{
+					"pkgc/c.go": "package pkgc\n\nfunc C() int { return 3 }\n"})
+			},
+			changed:    []string{"cache-test/pkgc"},
+			dependents: []string{"cache-test/pkgc"},
+			outdated:   []string{"cache-test/pkgc"},
+		},
+		{
+			desc: "change imported package",
+			setup: func() error {
+				return writeFiles(map[string]string{
+					"pkgb/b.go": "package pkgb\n\nfunc B() int { return 10 }\n"})
+			},
+			changed:    []string{"cache-test/pkgb"},
+			dependents: all,
+			outdated:   all,
+		},
+		{
+			desc: "remove import",
+			setup: func() error {
+				return writeFiles(map[string]string{
+					"pkga/a.go": "package pkga\n\nfunc A() int { return 1 }\n"})
+			},
+			changed:    []string{"cache-test/pkgb"},
+			dependents: []string{"cache-test/pkgb"},
+			outdated:   []string{"cache-test/pkga", "cache-test/pkgc"},
+		},
+	}
+	for i, tc := range cases {
+		execTestHelper(t, i, tc.desc, tc.setup)
+		err := cache.update(context.Background())
+		if isUnexpectedErr(t, i, tc.desc, nil, err) {
+			continue
+		}
+		dependents := cache.dependents(tc.changed)
+		if !reflect.DeepEqual(tc.dependents, dependents) {
+			t.Errorf("case [%d] %s\nexpected dependents %v\ngot %v", i, tc.desc, tc.dependents, dependents)
+		}
+		outdated := cache.outdated(all)
+		if !reflect.DeepEqual(tc.outdated, outdated) {
+			t.Errorf("case [%d] %s\nexpected outdated %v\ngot %v", i, tc.desc, tc.outdated, outdated)
+		}
+		err = cache.setIndex(cache.indexHashes(all), nil, "pointer")
+		if err != nil {
+			t.Errorf("case [%d] %s\nsetIndex error %v", i, tc.desc, err)
+		}
+	}
+	if pkg := cache.filePkgPath(filepath.Join("pkga", "a.go")); pkg != "cache-test/pkga" {
+		t.Errorf("expected file package cache-test/pkga, got %s", pkg)
+	}
+}
+
+func TestSSACacheStoredIndex(t *testing.T) {
+	testDir := filepath.Join(os.TempDir(), "test_ssa_cache_stored_index")
+	_ = os.RemoveAll(testDir)
+	files := map[string]string{
+		"go.mod":    "module cache-test\n\ngo 1.13\n",
+		"pkga/a.go": "package pkga\n\nfunc A() int { return 1 }\n",
+		"pkgb/b.go": "package pkgb\n\nfunc B() int { return 1 }\n",
+	}
+	for name, data := range files {
+		fpath := filepath.Join(testDir, name)
+		if err := os.MkdirAll(filepath.Dir(fpath), 0700); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if err := ioutil.WriteFile(fpath, []byte(data), 0600); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+	defer func() {
+		if !t.Failed() {
+			_ = os.RemoveAll(testDir)
+		}
+	}()
+	all := []string{"cache-test/pkga", "cache-test/pkgb"}
+	index := map[string]*pkgIndex{
+		"cache-test/pkga": {Tests: []*testIndex{{Name: "TestA", Pkg: "cache-test/pkga",
+			Funcs: map[string]reachedFunc{"cache-test/pkga.A": {Name: "cache-test/pkga.A"}}}}},
+	}
+	cache := newSSACache(testDir, "pointer")
+	if err := cache.update(context.Background()); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	// pointer analysis exceeded budget
+	if err := cache.setIndex(cache.indexHashes(all), index, "rta"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	// change pkgb on restart
+	err := ioutil.WriteFile(filepath.Join(testDir, "pkgb", "b.go"),
+		[]byte("package pkgb\n\nfunc B() int { return 2 }\n"), 0600)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	cases := []struct {
+		desc      string
+		flags     string
+		outdated  []string
+		imprecise []string
+		tests     int
+	}{
+		{desc: "load stored index", flags: "pointer",
+			outdated: []string{"cache-test/pkgb"}, imprecise: all, tests: 1},
+		{desc: "stored with other flags", flags: "cha",
+			outdated: all},
+	}
+	for i, tc := range cases {
+		cache := newSSACache(testDir, tc.flags)
+		err := cache.update(context.Background())
+		if isUnexpectedErr(t, i, tc.desc, nil, err) {
+			continue
+		}
+		outdated := cache.outdated(all)
+		if !reflect.DeepEqual(tc.outdated, outdated) {
+			t.Errorf("case [%d] %s\nexpected outdated %v\ngot %v", i, tc.desc, tc.outdated, outdated)
+		}
+		imprecise := cache.imprecise(all, "pointer")
+		if !reflect.DeepEqual(tc.imprecise, imprecise) {
+			t.Errorf("case [%d] %s\nexpected imprecise %v\ngot %v", i, tc.desc, tc.imprecise, imprecise)
+		}
+		tests := cache.tests(all)
+		if len(tests) != tc.tests {
+			t.Errorf("case [%d] %s\nexpected %d tests, got %d", i, tc.desc, tc.tests, len(tests))
+		}
+	}
+}
+
+func TestTestIndexReaches(t *testing.T) {
+	test := &testIndex{
+		Name: "TestA",
+		Pkg:  "mod",
+		Funcs: map[string]reachedFunc{
+			"mod.TestA":    {Name: "mod.TestA"},
+			"mod.helper":   {Caller: "mod.TestA", Name: "mod.helper"},
+			"mod.T.Method": {Caller: "mod.helper", Name: "(*mod.T).Method"},
+			"mod.add":      {Caller: "mod.TestA", Name: "mod.add"},
+		},
+	}
+	cases := []struct {
+		desc    string
+		changed []string
+		key     string
+		path    []string
+	}{
+		{desc: "not reached", changed: []string{"mod.sub"}},
+		{desc: "test changed", changed: []string{"mod.TestA"},
+			key: "mod.TestA", path: []string{"mod.TestA"}},
+		{desc: "shortest path", changed: []string{"mod.T.Method", "mod.add"},
+			key: "mod.add", path: []string{"mod.TestA", "mod.add"}},
+		{desc: "path through helper", changed: []string{"mod.T.Method"},
+			key: "mod.T.Method", path: []string{"mod.TestA", "mod.helper", "(*mod.T).Method"}},
+	}
+	for i, tc := range cases {
+		key, path := test.reaches(tc.changed)
+		if key != tc.key || !reflect.DeepEqual(tc.path, path) {
+			t.Errorf("case [%d] %s\nexpected %s %v\ngot %s %v", i, tc.desc, tc.key, tc.path, key, path)
+		}
+	}
+}
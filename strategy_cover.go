@@ -5,9 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"go/ast"
+	"go/types"
 	"io/ioutil"
 	"log"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 
@@ -15,21 +17,31 @@ import (
 )
 
 var _ Strategy = (*CoverStrategy)(nil)
+var _ Explainer = (*CoverStrategy)(nil)
+var _ AllSelector = (*CoverStrategy)(nil)
 
 type CoverStrategy struct {
-	firstRun bool
-	runInit  bool
-	workDir  string
-	gitCmd   *GitCMD
-	log      *log.Logger
+	firstRun     bool
+	runInit      bool
+	selectedAll  bool
+	workDir      string
+	gitCmd       GitCMD
+	log          *log.Logger
+	explain      bool
+	explanations []Explanation
 }
 
-func NewCoverStrategy(runInit bool, workDir string, logger *log.Logger) *CoverStrategy {
+func NewCoverStrategy(
+	runInit bool,
+	workDir string,
+	gitCmd GitCMD,
+	logger *log.Logger,
+) *CoverStrategy {
 	return &CoverStrategy{
 		firstRun: true,
 		runInit:  runInit,
 		workDir:  workDir,
-		gitCmd:   NewGitCMD(workDir),
+		gitCmd:   gitCmd,
 		log:      logger,
 	}
 }
@@ -37,10 +49,29 @@ func (cs *CoverStrategy) CoverageEnabled() bool {
 	return true
 }
 
+// SelectedAll is true if last TestsToRun selected
+// all tests to initialize cover profiles
+func (cs *CoverStrategy) SelectedAll() bool {
+	return cs.selectedAll
+}
+
+// SetExplain enables recording of cover blocks
+// overlapping changes
+func (cs *CoverStrategy) SetExplain(explain bool) {
+	cs.explain = explain
+}
+
+// Explanations returns cover blocks of last TestsToRun call
+func (cs *CoverStrategy) Explanations() []Explanation {
+	return cs.explanations
+}
+
 func (cs *CoverStrategy) TestsToRun(ctx context.Context) (
 	runAll bool, testsList, subTestsList []string,
 	err error) {
 	runAll = false
+	cs.explanations = nil
+	cs.selectedAll = false
 	// check if dir with profile exists
 	// TODO handle old cover profile, if not changed no need to update
 	// or just run every day?
@@ -52,7 +83,12 @@ func (cs *CoverStrategy) TestsToRun(ctx context.Context) (
 		if err != nil {
 			return
 		}
+		dir, err = os.Open(profileDir)
+		if err != nil {
+			return
+		}
 	}
+	defer dir.Close()
 	moduleName := ""
 	moduleName, err = getModuleName(cs.workDir)
 	if err != nil {
@@ -68,6 +104,7 @@ func (cs *CoverStrategy) TestsToRun(ctx context.Context) (
 			return
 		}
 		cs.firstRun = false
+		cs.selectedAll = true
 		return
 	}
 
@@ -78,6 +115,16 @@ func (cs *CoverStrategy) TestsToRun(ctx context.Context) (
 		err = fmt.Errorf("gitCmd.Diff error %s", err)
 		return
 	}
+	// declarations deleted, moved or in deleted files
+	removed := removedBlocks(ctx, cs.gitCmd, changes)
+	// funcs and vars reading changed testdata and embedded files
+	inputs := inputBlocks(cs.workDir, changes)
+	// modules which versions changed in go.mod or go.sum
+	modules, err := changedModules(ctx, cs.gitCmd, changes)
+	if err != nil {
+		err = fmt.Errorf("changedModules error %s", err)
+		return
+	}
 	// filter out none go files
 	n := 0
 	for _, x := range changes {
@@ -89,7 +136,8 @@ func (cs *CoverStrategy) TestsToRun(ctx context.Context) (
 	}
 
 	changes = changes[:n]
-	if len(changes) == 0 {
+	if len(changes) == 0 && len(removed) == 0 &&
+		len(inputs) == 0 && len(modules) == 0 {
 		// no changes to test
 		return
 	}
@@ -100,7 +148,13 @@ func (cs *CoverStrategy) TestsToRun(ctx context.Context) (
 		if _, ok := fileInfos[change.fpath]; ok {
 			continue
 		}
-		info, err = getFileInfo(filepath.Join(cs.workDir, change.fpath), nil)
+		// content after changes, commit or index
+		// differs from working tree
+		var src []byte
+		src, err = cs.gitCmd.Content(ctx, change.fpath)
+		if err == nil {
+			info, err = getFileInfo(filepath.Join(cs.workDir, change.fpath), src)
+		}
 		if err != nil {
 			fmt.Fprintln(os.Stderr, "\n=======\033[31m Build Failed \033[39m=======")
 			fmt.Fprintf(os.Stderr, "%s", err)
@@ -115,6 +169,9 @@ func (cs *CoverStrategy) TestsToRun(ctx context.Context) (
 		err = fmt.Errorf("changesToFileBlocks error %s", cerr)
 		return
 	}
+	// comments and formatting do not change behavior
+	changedBlocks = semanticBlocks(ctx, cs.gitCmd, changes, changedBlocks)
+	mergeBlocks(changedBlocks, inputs)
 
 	// load all coverprofiles TODO load only changed use ts
 	allFiles, err := dir.Readdirnames(0)
@@ -155,6 +212,39 @@ func (cs *CoverStrategy) TestsToRun(ctx context.Context) (
 	}
 
 	testsDic := map[string]bool{}
+	// coverTests finds tests which cover block
+	// of file and records changed block
+	coverTests := func(fname string, block FileBlock, changed string) {
+		testToFileCover, ok := fileBlocksToTest[filepath.Join(moduleName, fname)]
+		if !ok {
+			return
+		}
+		// find test which cover changes
+		// create list of tests with packages
+		for profName, profile := range testToFileCover {
+			for _, profBlock := range profile.Blocks {
+				if (block.start >= profBlock[0] && block.start <= profBlock[1]) ||
+					(block.end >= profBlock[0] && block.end <= profBlock[1]) ||
+					(profBlock[0] >= block.start && profBlock[1] <= block.end) {
+					id := strings.LastIndexByte(profName, '.')
+					dir := filepath.Dir(profile.File)
+					testName := fmt.Sprintf("%s.%s", dir, profName[id+1:])
+					testsDic[testName] = true
+					if cs.explain {
+						cs.explanations = append(cs.explanations, Explanation{
+							Test:         testName,
+							Changed:      changed,
+							CoverProfile: filepath.Join(".gtr", profName),
+							CoverBlock:   profBlock,
+						})
+					}
+				}
+			}
+		}
+	}
+	// package level vars and consts are not in cover
+	// profiles, funcs reading them are checked
+	changedGlobals := map[string]string{}
 	// TODO refactor
 	// find tests which covers changed code blocks
 	for fname, info := range changedBlocks {
@@ -167,34 +257,168 @@ func (cs *CoverStrategy) TestsToRun(ctx context.Context) (
 					testName = fmt.Sprintf("%s.%s", filepath.Join(moduleName, info.pkgName), testName)
 				}
 				testsDic[testName] = true
+				if cs.explain {
+					cs.explanations = append(cs.explanations, Explanation{
+						Test:    testName,
+						Changed: explainedBlock(fname, block),
+					})
+				}
 			}
+			if block.typ&(BlockVar|BlockConst) > 0 {
+				pkgPath := path.Join(moduleName, filepath.ToSlash(filepath.Dir(fname)))
+				changedGlobals[pkgPath+"."+block.name] = explainedBlock(fname, block)
+				continue
+			}
+			coverTests(fname, block, explainedBlock(fname, block))
 		}
-		fileName := filepath.Join(moduleName, fname)
-		testToFileCover, ok := fileBlocksToTest[fileName]
-		if !ok {
-			continue
-		}
+	}
+	// cover profiles are of old version of files,
+	// removed vars and consts are not in profiles
+	for fname, info := range removed {
 		for _, block := range info.blocks {
-			// find test which cover changes
-			// create list of tests with packages
-			for testName, profile := range testToFileCover {
-				for _, profBlock := range profile.Blocks {
-					if (block.start >= profBlock[0] && block.start <= profBlock[1]) ||
-						(block.end >= profBlock[0] && block.end <= profBlock[1]) ||
-						(profBlock[0] >= block.start && profBlock[1] <= block.end) {
-						id := strings.LastIndexByte(testName, '.')
-						dir := filepath.Dir(profile.File)
-						testName = fmt.Sprintf("%s.%s", dir, testName[id+1:])
-						testsDic[testName] = true
-					}
-				}
+			if block.typ&(BlockVar|BlockConst) > 0 ||
+				(block.typ&BlockFunc > 0 && strings.HasPrefix(block.name, "Test")) {
+				continue
+			}
+			coverTests(fname, block, explainedRemovedBlock(fname, block))
+		}
+	}
+	if len(changedGlobals) > 0 {
+		var refs []globalRef
+		refs, err = findGlobalRefs(ctx, cs.workDir, changedGlobals)
+		if err != nil {
+			return
+		}
+		for _, ref := range refs {
+			coverTests(ref.fname, ref.block, changedGlobals[ref.global]+
+				" via "+explainedBlock(ref.fname, ref.block))
+		}
+	}
+	if len(modules) > 0 {
+		// changed dependencies may change behavior
+		// of all tests of importers
+		var importers map[string][]string
+		importers, err = modImporters(ctx, cs.workDir, moduleName, modules)
+		if err != nil {
+			err = fmt.Errorf("modImporters error %s", err)
+			return
+		}
+		var tests []string
+		tests, err = findAllTestInDir(ctx, moduleName, cs.workDir)
+		if err != nil {
+			return
+		}
+		for _, testName := range tests {
+			path, ok := importers[testRootPkg(testName[:strings.LastIndexByte(testName, '.')])]
+			if !ok {
+				continue
+			}
+			testsDic[testName] = true
+			if cs.explain {
+				cs.explanations = append(cs.explanations, Explanation{
+					Test:    testName,
+					Changed: "go.mod:" + path[len(path)-1],
+					Path:    path,
+				})
 			}
 		}
 	}
+	sortExplanations(cs.explanations)
 	testsList = mapStrToSlice(testsDic)
 	return
 }
 
+// globalRef is func block reading package level var or const
+type globalRef struct {
+	fname  string // relative to module dir
+	block  FileBlock
+	global string // pkgPath.Name
+}
+
+// findGlobalRefs returns blocks of module funcs
+// reading package level vars or consts by key
+func findGlobalRefs(ctx context.Context, dir string, globals map[string]string) (
+	[]globalRef, error) {
+	cfg := &packages.Config{
+		Context: ctx,
+		Dir:     dir,
+		Mode: packages.NeedName |
+			packages.NeedFiles |
+			packages.NeedSyntax |
+			packages.NeedTypes |
+			packages.NeedTypesSizes |
+			packages.NeedTypesInfo,
+		Tests: true,
+	}
+	pkgs, err := packages.Load(cfg, "./...")
+	if err != nil {
+		return nil, err
+	}
+	for i := range pkgs {
+		if len(pkgs[i].Errors) > 0 {
+			if ctx.Err() != nil {
+				return nil, errors.New("task canceled")
+			}
+			return nil, errors.New("packages.Load error")
+		}
+	}
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	var refs []globalRef
+	// test variants of packages have same files
+	seen := map[globalRef]bool{}
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Syntax {
+			fname, err := filepath.Rel(dir, pkg.Fset.File(file.Pos()).Name())
+			if err != nil || strings.HasPrefix(fname, "..") {
+				continue
+			}
+			for _, decl := range file.Decls {
+				fn, ok := decl.(*ast.FuncDecl)
+				if !ok || fn.Body == nil {
+					continue
+				}
+				block := FileBlock{
+					typ:   BlockFunc,
+					name:  fn.Name.Name,
+					start: pkg.Fset.Position(fn.Body.Lbrace).Line,
+					end:   pkg.Fset.Position(fn.Body.Rbrace).Line,
+				}
+				if fn.Recv != nil {
+					block.typ = BlockMethod
+					if recv, err := recvTypeName(fn.Recv.List[0].Type); err == nil {
+						block.name = recv + "." + block.name
+					}
+				}
+				ast.Inspect(fn.Body, func(n ast.Node) bool {
+					id, ok := n.(*ast.Ident)
+					if !ok {
+						return true
+					}
+					obj := pkg.TypesInfo.Uses[id]
+					switch obj.(type) {
+					case *types.Var, *types.Const:
+					default:
+						return true
+					}
+					if obj.Pkg() == nil || obj.Parent() != obj.Pkg().Scope() {
+						return true
+					}
+					ref := globalRef{fname, block, obj.Pkg().Path() + "." + obj.Name()}
+					if _, ok := globals[ref.global]; ok && !seen[ref] {
+						seen[ref] = true
+						refs = append(refs, ref)
+					}
+					return true
+				})
+			}
+		}
+	}
+	return refs, nil
+}
+
 // findAllTestInDir returns all Test names in module
 func findAllTestInDir(ctx context.Context, moduleName, dir string) ([]string, error) {
 	cfg := &packages.Config{
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"io/ioutil"
 	"log"
@@ -152,6 +153,37 @@ func TestCoverStrategyTestsToRun(t *testing.T) {
 		func Sub(a, b int) int {
 			return a - b + 0
 		}`)
+		fileARemoveMul = []byte(`package main
+
+		func add(a, b int) int {
+			return a + b
+		}
+
+		func double(a int) int {
+			return 2 * a
+		}
+		`)
+		mainFileMoveMul = []byte(`package main
+
+		import (
+			"fmt"
+                        "cover-strategy-test-run/pkga"
+		)
+
+		var a, b int = 10, 20
+
+		func main() {
+			fmt.Printf("%+v\n", add(a, b))
+		}
+
+		func sub(a, b int) int {
+			return pkga.Sub(a, b)
+		}
+
+		func mul(a, b int) int {
+			return a * b * 1
+		}
+		`)
 		pkgATestFileUpdate = []byte(`package pkga
 
 		import (
@@ -240,7 +272,9 @@ cover-strategy-test-run/main.go:14.26,16.4 1 0
 			},
 		)
 		// TODO test with different configurations
-		return NewCoverStrategy(true, testDir, logger)
+		cs := NewCoverStrategy(true, testDir, NewGitCMD(testDir), logger)
+		cs.SetExplain(true)
+		return cs
 	}
 	// teardown
 	defer func() {
@@ -253,6 +287,7 @@ cover-strategy-test-run/main.go:14.26,16.4 1 0
 		desc            string
 		setup, tearDown func() error
 		outTests        []string
+		explanations    []string
 		err             error
 	}{
 		{
@@ -304,6 +339,8 @@ cover-strategy-test-run/main.go:14.26,16.4 1 0
 				return gitCmdRun("commit", "-am", "commit changes") // Test
 			},
 			outTests: []string{"cover-strategy-test-run.TestDouble"},
+			explanations: []string{
+				"cover-strategy-test-run.TestDouble changed main_test.go:TestDouble"},
 		},
 		{
 			desc: "Update mul func in file_a.go and sub in pkga/file_a.go",
@@ -323,6 +360,9 @@ cover-strategy-test-run/main.go:14.26,16.4 1 0
 			},
 			outTests: []string{"cover-strategy-test-run.TestMul",
 				"cover-strategy-test-run/pkga.TestSub"},
+			explanations: []string{
+				"cover-strategy-test-run.TestMul covers file_a.go:mul lines 7-9 in .gtr/cover-strategy-test-run.TestMul",
+				"cover-strategy-test-run/pkga.TestSub covers pkga/file_a.go:Sub lines 7-9 in .gtr/cover-strategy-test-run_pkga.TestSub"},
 		},
 		{
 			desc: "Update in pkga",
@@ -335,6 +375,28 @@ cover-strategy-test-run/main.go:14.26,16.4 1 0
 				return gitCmdRun("commit", "-am", "commit changes") // Test
 			},
 			outTests: []string{"cover-strategy-test-run/pkga.TestDiv"},
+			explanations: []string{
+				"cover-strategy-test-run/pkga.TestDiv changed pkga/file_a_test.go:TestDiv"},
+		},
+		{
+			desc: "Move mul func from file_a.go to main.go",
+			setup: func() error {
+				err := ioutil.WriteFile(
+					filepath.Join(testDir, "file_a.go"),
+					fileARemoveMul, 0600)
+				if err != nil {
+					return err
+				}
+				return ioutil.WriteFile(
+					filepath.Join(testDir, "main.go"),
+					mainFileMoveMul, 0600)
+			},
+			tearDown: func() error {
+				return gitCmdRun("commit", "-am", "commit changes")
+			},
+			outTests: []string{"cover-strategy-test-run.TestMul"},
+			explanations: []string{
+				"cover-strategy-test-run.TestMul covers removed file_a.go:mul lines 7-9 in .gtr/cover-strategy-test-run.TestMul"},
 		},
 	}
 	coverStrategy := setup()
@@ -353,7 +415,13 @@ cover-strategy-test-run/main.go:14.26,16.4 1 0
 		if !reflect.DeepEqual(tc.outTests, testsList) {
 			t.Errorf("case [%d] %s\nexpected Tests %+v\ngot %+v", i, tc.desc, tc.outTests, testsList)
 		}
-
+		var explanations []string
+		for _, e := range coverStrategy.Explanations() {
+			explanations = append(explanations, e.String())
+		}
+		if !reflect.DeepEqual(tc.explanations, explanations) {
+			t.Errorf("case [%d] %s\nexpected Explanations %q\ngot %q", i, tc.desc, tc.explanations, explanations)
+		}
 	}
 
 }
@@ -509,3 +577,116 @@ func TestFindAllTestInDir(t *testing.T) {
 	}
 
 }
+
+func TestCoverStrategyGlobalRefs(t *testing.T) {
+	var (
+		gomod = []byte(`module cover-globals-test-run
+
+go 1.13
+`)
+		pkgAFile = []byte(`package pkga
+
+var scale = 1
+
+func Div(a, b int) int {
+	return a / b * scale
+}
+
+func Sub(a, b int) int {
+	return a - b + zero
+}
+
+const zero = 0
+`)
+		pkgATestFile = []byte(`package pkga
+
+import (
+	"testing"
+)
+
+func TestDiv(t *testing.T) {
+	if Div(3, 2) != 1 {
+		t.Error("Div unexpected result")
+	}
+}
+
+func TestSub(t *testing.T) {
+	if Sub(10, 5) != 5 {
+		t.Error("Sub unexpected result")
+	}
+}
+`)
+		testDivProf = []byte(`mode: set
+cover-globals-test-run/pkga/file_a.go:5.24,7.2 1 1
+cover-globals-test-run/pkga/file_a.go:9.24,11.2 1 0
+`)
+		testSubProf = []byte(`mode: set
+cover-globals-test-run/pkga/file_a.go:5.24,7.2 1 0
+cover-globals-test-run/pkga/file_a.go:9.24,11.2 1 1
+`)
+	)
+	testDir := filepath.Join(os.TempDir(), "test_cover_strategy_global_refs")
+	gitCmdRun := NewGitCmd(testDir)
+	pkgAFilePath := filepath.Join("pkga", "file_a.go")
+	pkgATestFilePath := filepath.Join("pkga", "file_a_test.go")
+	files := map[string][]byte{
+		"go.mod":     gomod,
+		pkgAFilePath: pkgAFile, pkgATestFilePath: pkgATestFile,
+		filepath.Join(".gtr", "cover-globals-test-run_pkga.TestDiv"): testDivProf,
+		filepath.Join(".gtr", "cover-globals-test-run_pkga.TestSub"): testSubProf,
+	}
+	setupTestGitDir(t, testDir, files,
+		[]string{"go.mod", pkgAFilePath, pkgATestFilePath})
+	defer func() {
+		if !t.Failed() {
+			_ = os.RemoveAll(testDir)
+		}
+	}()
+	logger := log.New(ioutil.Discard, "", 0)
+	cases := []struct {
+		desc         string
+		old, new     string
+		outTests     []string
+		explanations []string
+	}{
+		{
+			desc: "Update const read by Sub",
+			old:  "const zero = 0", new: "const zero = 1 - 1",
+			outTests: []string{"cover-globals-test-run/pkga.TestSub"},
+			explanations: []string{
+				"cover-globals-test-run/pkga.TestSub covers pkga/file_a.go:zero via pkga/file_a.go:Sub lines 9-11 in .gtr/cover-globals-test-run_pkga.TestSub"},
+		},
+		{
+			desc: "Update var read by Div",
+			old:  "var scale = 1", new: "var scale = 2 / 2",
+			outTests: []string{"cover-globals-test-run/pkga.TestDiv"},
+			explanations: []string{
+				"cover-globals-test-run/pkga.TestDiv covers pkga/file_a.go:scale via pkga/file_a.go:Div lines 5-7 in .gtr/cover-globals-test-run_pkga.TestDiv"},
+		},
+	}
+	coverStrategy := NewCoverStrategy(false, testDir, NewGitCMD(testDir), logger)
+	coverStrategy.SetExplain(true)
+	for i, tc := range cases {
+		execTestHelper(t, i, tc.desc, func() error {
+			pkgAFile = bytes.Replace(pkgAFile, []byte(tc.old), []byte(tc.new), 1)
+			return ioutil.WriteFile(filepath.Join(testDir, pkgAFilePath), pkgAFile, 0600)
+		})
+		_, testsList, _, err := coverStrategy.TestsToRun(context.Background())
+		execTestHelper(t, i, tc.desc, func() error {
+			return gitCmdRun("commit", "-am", "commit changes")
+		})
+		if isUnexpectedErr(t, i, tc.desc, nil, err) {
+			continue
+		}
+		if !reflect.DeepEqual(tc.outTests, testsList) {
+			t.Errorf("case [%d] %s\nexpected Tests %+v\ngot %+v", i, tc.desc, tc.outTests, testsList)
+		}
+		var explanations []string
+		for _, e := range coverStrategy.Explanations() {
+			explanations = append(explanations, e.String())
+		}
+		if !reflect.DeepEqual(tc.explanations, explanations) {
+			t.Errorf("case [%d] %s\nexpected Explanations %q\ngot %q", i, tc.desc, tc.explanations, explanations)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+)
+
+var _ Strategy = (*HybridStrategy)(nil)
+var _ Explainer = (*HybridStrategy)(nil)
+
+// AllSelector is implemented by strategies which may
+// select all tests regardless of changes
+type AllSelector interface {
+	// SelectedAll is true if last TestsToRun selected all tests
+	SelectedAll() bool
+}
+
+// SubTestParenter is implemented by strategies which
+// know tests running selected subtests
+type SubTestParenter interface {
+	// SubTestParents returns test names by subtest
+	// selected by last TestsToRun call
+	SubTestParents() map[string][]string
+}
+
+// HybridStrategy combines tests selected by coverage
+// and analysis strategies, union of tests by default
+// or intersection, and records which strategy selected each test
+type HybridStrategy struct {
+	intersect      bool
+	names          []string
+	strategies     []Strategy
+	log            *log.Logger
+	sources        map[string][]string
+	subTestSources map[string][]string
+	explanations   []Explanation
+}
+
+// NewHybridStrategy returns strategy
+// intersect to select tests found by both strategies
+// coverage and analysis strategies to combine
+// logger for strategy
+func NewHybridStrategy(
+	intersect bool,
+	coverage, analysis Strategy,
+	logger *log.Logger,
+) *HybridStrategy {
+	return &HybridStrategy{
+		intersect:  intersect,
+		names:      []string{"coverage", "analysis"},
+		strategies: []Strategy{coverage, analysis},
+		log:        logger,
+	}
+}
+
+// CoverageEnabled is true to keep coverage profiles updated
+func (hs *HybridStrategy) CoverageEnabled() bool {
+	return true
+}
+
+// SetExplain enables explanations of combined strategies
+func (hs *HybridStrategy) SetExplain(explain bool) {
+	for _, s := range hs.strategies {
+		if ex, ok := s.(Explainer); ok {
+			ex.SetExplain(explain)
+		}
+	}
+}
+
+// Explanations returns explanations of selected tests
+// with strategy name as source
+func (hs *HybridStrategy) Explanations() []Explanation {
+	return hs.explanations
+}
+
+// Sources returns names of strategies by selected
+// test of last TestsToRun call
+func (hs *HybridStrategy) Sources() map[string][]string {
+	return hs.sources
+}
+
+// SubTestSources returns names of strategies by selected
+// subtest of last TestsToRun call
+func (hs *HybridStrategy) SubTestSources() map[string][]string {
+	return hs.subTestSources
+}
+
+// TestsToRun returns union or intersection of tests
+// selected by strategies, tests run one by one to
+// update coverage profiles, in intersection strategies
+// selected all tests have no opinion and subtests are
+// selected if their tests are selected
+func (hs *HybridStrategy) TestsToRun(ctx context.Context) (
+	runAll bool, testsList, subTestsList []string, err error) {
+	hs.sources = map[string][]string{}
+	hs.subTestSources = map[string][]string{}
+	hs.explanations = nil
+	testsCount := map[string]int{}
+	subTestsCount := map[string]int{}
+	parents := map[string][]string{}
+	// strategies which selected by changes
+	voters := 0
+	var explanations []Explanation
+	for i, s := range hs.strategies {
+		_, tests, subTests, serr := s.TestsToRun(ctx)
+		if serr != nil {
+			if serr == ErrBuildFailed {
+				return false, nil, nil, serr
+			}
+			return false, nil, nil, fmt.Errorf("%s strategy error %v", hs.names[i], serr)
+		}
+		hs.log.Printf("%s strategy selected %d tests\n", hs.names[i], len(tests))
+		if as, ok := s.(AllSelector); !ok || !as.SelectedAll() {
+			voters++
+			for _, name := range tests {
+				testsCount[name]++
+			}
+			for _, name := range subTests {
+				subTestsCount[name]++
+			}
+		}
+		for _, name := range tests {
+			hs.sources[name] = append(hs.sources[name], hs.names[i])
+		}
+		for _, name := range subTests {
+			hs.subTestSources[name] = append(hs.subTestSources[name], hs.names[i])
+		}
+		if sp, ok := s.(SubTestParenter); ok {
+			for name, tests := range sp.SubTestParents() {
+				parents[name] = append(parents[name], tests...)
+			}
+		}
+		if ex, ok := s.(Explainer); ok {
+			for _, e := range ex.Explanations() {
+				e.Source = hs.names[i]
+				explanations = append(explanations, e)
+			}
+		}
+	}
+	// union if all strategies selected all tests
+	intersect := hs.intersect && voters > 0
+	for name := range hs.sources {
+		if intersect && testsCount[name] < voters {
+			delete(hs.sources, name)
+			continue
+		}
+		testsList = append(testsList, name)
+	}
+	sort.Strings(testsList)
+	for name := range hs.subTestSources {
+		if intersect && subTestsCount[name] < voters &&
+			!hasSelectedParent(hs.sources, parents[name]) {
+			delete(hs.subTestSources, name)
+			continue
+		}
+		subTestsList = append(subTestsList, name)
+	}
+	sort.Strings(subTestsList)
+	for _, e := range explanations {
+		if _, ok := hs.sources[e.Test]; ok {
+			hs.explanations = append(hs.explanations, e)
+		} else if _, ok := hs.subTestSources[e.Test]; ok {
+			hs.explanations = append(hs.explanations, e)
+		}
+	}
+	sortExplanations(hs.explanations)
+	return false, testsList, subTestsList, nil
+}
+
+// hasSelectedParent checks if any of parents tests selected
+func hasSelectedParent(selected map[string][]string, parents []string) bool {
+	for _, name := range parents {
+		if _, ok := selected[name]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"reflect"
+	"testing"
+)
+
+var _ Explainer = (*dummyExplainer)(nil)
+
+type dummyExplainer struct {
+	dummyStrategy
+	explain      bool
+	explanations []Explanation
+	selectedAll  bool
+	parents      map[string][]string
+}
+
+func (de *dummyExplainer) SetExplain(explain bool) {
+	de.explain = explain
+}
+
+func (de *dummyExplainer) Explanations() []Explanation {
+	return de.explanations
+}
+
+func (de *dummyExplainer) SelectedAll() bool {
+	return de.selectedAll
+}
+
+func (de *dummyExplainer) SubTestParents() map[string][]string {
+	return de.parents
+}
+
+func TestHybridStrategyTestsToRun(t *testing.T) {
+	coverage := &dummyExplainer{
+		dummyStrategy: dummyStrategy{tests: []string{"mod.TestA", "mod.TestB"}},
+		explanations: []Explanation{
+			{Test: "mod.TestA", Changed: "a.go:A", CoverProfile: ".gtr/mod.TestA", CoverBlock: [2]int{3, 5}},
+			{Test: "mod.TestB", Changed: "a.go:A", CoverProfile: ".gtr/mod.TestB", CoverBlock: [2]int{3, 5}},
+		},
+	}
+	analysis := &dummyExplainer{
+		dummyStrategy: dummyStrategy{runAll: true,
+			tests: []string{"mod.TestB", "mod.TestC"}, subtests: []string{"max", "min"}},
+		explanations: []Explanation{
+			{Test: "mod.TestB", Changed: "a.go:A", Path: []string{"mod.TestB", "mod.A"}},
+		},
+		parents: map[string][]string{"max": {"mod.TestB"}, "min": {"mod.TestC"}},
+	}
+	logger := log.New(ioutil.Discard, "", 0)
+	cases := []struct {
+		desc            string
+		intersect       bool
+		coverageAll     bool
+		analysisErr     error
+		tests           []string
+		subTests        []string
+		sources         map[string][]string
+		subTestsSources map[string][]string
+		explanations    []string
+		err             error
+	}{
+		{
+			desc:     "union",
+			tests:    []string{"mod.TestA", "mod.TestB", "mod.TestC"},
+			subTests: []string{"max", "min"},
+			sources: map[string][]string{
+				"mod.TestA": {"coverage"},
+				"mod.TestB": {"coverage", "analysis"},
+				"mod.TestC": {"analysis"},
+			},
+			subTestsSources: map[string][]string{
+				"max": {"analysis"},
+				"min": {"analysis"},
+			},
+			explanations: []string{
+				"coverage: mod.TestA covers a.go:A lines 3-5 in .gtr/mod.TestA",
+				"coverage: mod.TestB covers a.go:A lines 3-5 in .gtr/mod.TestB",
+				"analysis: mod.TestB reaches a.go:A: mod.TestB -> mod.A",
+			},
+		},
+		{
+			desc:      "intersect",
+			intersect: true,
+			tests:     []string{"mod.TestB"},
+			subTests:  []string{"max"},
+			sources: map[string][]string{
+				"mod.TestB": {"coverage", "analysis"},
+			},
+			subTestsSources: map[string][]string{
+				"max": {"analysis"},
+			},
+			explanations: []string{
+				"coverage: mod.TestB covers a.go:A lines 3-5 in .gtr/mod.TestB",
+				"analysis: mod.TestB reaches a.go:A: mod.TestB -> mod.A",
+			},
+		},
+		{
+			desc:        "intersect with all tests of coverage",
+			intersect:   true,
+			coverageAll: true,
+			tests:       []string{"mod.TestB", "mod.TestC"},
+			subTests:    []string{"max", "min"},
+			sources: map[string][]string{
+				"mod.TestB": {"coverage", "analysis"},
+				"mod.TestC": {"analysis"},
+			},
+			subTestsSources: map[string][]string{
+				"max": {"analysis"},
+				"min": {"analysis"},
+			},
+			explanations: []string{
+				"coverage: mod.TestB covers a.go:A lines 3-5 in .gtr/mod.TestB",
+				"analysis: mod.TestB reaches a.go:A: mod.TestB -> mod.A",
+			},
+		},
+		{
+			desc:        "build failed",
+			analysisErr: ErrBuildFailed,
+			err:         ErrBuildFailed,
+		},
+		{
+			desc:        "strategy error",
+			analysisErr: errors.New("diff error"),
+			err:         errors.New("analysis strategy error diff error"),
+		},
+	}
+	for i, tc := range cases {
+		analysis.err = tc.analysisErr
+		coverage.selectedAll = tc.coverageAll
+		hs := NewHybridStrategy(tc.intersect, coverage, analysis, logger)
+		hs.SetExplain(true)
+		runAll, tests, subTests, err := hs.TestsToRun(context.Background())
+		if isUnexpectedErr(t, i, tc.desc, tc.err, err) {
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if runAll {
+			t.Errorf("case [%d] %s\nexpected tests to run one by one", i, tc.desc)
+		}
+		if !coverage.explain || !analysis.explain {
+			t.Errorf("case [%d] %s\nexpected explain enabled", i, tc.desc)
+		}
+		if !reflect.DeepEqual(tc.tests, tests) || !reflect.DeepEqual(tc.subTests, subTests) {
+			t.Errorf("case [%d] %s\nexpected %v %v\ngot %v %v", i, tc.desc, tc.tests, tc.subTests, tests, subTests)
+		}
+		if !reflect.DeepEqual(tc.sources, hs.Sources()) {
+			t.Errorf("case [%d] %s\nexpected sources %v\ngot %v", i, tc.desc, tc.sources, hs.Sources())
+		}
+		if !reflect.DeepEqual(tc.subTestsSources, hs.SubTestSources()) {
+			t.Errorf("case [%d] %s\nexpected subtests sources %v\ngot %v", i, tc.desc, tc.subTestsSources, hs.SubTestSources())
+		}
+		var explanations []string
+		for _, e := range hs.Explanations() {
+			explanations = append(explanations, e.String())
+		}
+		if !reflect.DeepEqual(tc.explanations, explanations) {
+			t.Errorf("case [%d] %s\nexpected explanations %q\ngot %q", i, tc.desc, tc.explanations, explanations)
+		}
+	}
+}
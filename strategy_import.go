@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var _ Strategy = (*ImportGraphStrategy)(nil)
+var _ Explainer = (*ImportGraphStrategy)(nil)
+
+// ImportGraphStrategy selects all tests of packages which
+// import changed packages directly or transitively,
+// uses only package imports without type checking
+type ImportGraphStrategy struct {
+	workDir      string
+	gitCmd       GitCMD
+	log          *log.Logger
+	cache        *ssaCache
+	explain      bool
+	explanations []Explanation
+}
+
+// NewImportGraphStrategy returns strategy
+func NewImportGraphStrategy(
+	workDir string,
+	gitCmd GitCMD,
+	logger *log.Logger,
+) *ImportGraphStrategy {
+	return &ImportGraphStrategy{
+		workDir: workDir,
+		gitCmd:  gitCmd,
+		log:     logger,
+		cache:   newSSACache(workDir, "import"),
+	}
+}
+
+func (is *ImportGraphStrategy) CoverageEnabled() bool {
+	return false
+}
+
+// SetExplain enables recording of import paths
+// from tests to changed packages
+func (is *ImportGraphStrategy) SetExplain(explain bool) {
+	is.explain = explain
+}
+
+// Explanations returns import paths of last TestsToRun call
+func (is *ImportGraphStrategy) Explanations() []Explanation {
+	return is.explanations
+}
+
+// TestsToRun returns all tests of packages depending
+// on changed packages
+func (is *ImportGraphStrategy) TestsToRun(ctx context.Context) (
+	runAll bool, testsList, subTestsList []string, err error) {
+	is.explanations = nil
+	changes, err := is.gitCmd.Diff(ctx)
+	if err != nil {
+		err = fmt.Errorf("gitCmd.Diff error %s", err)
+		return
+	}
+	err = is.cache.update(ctx)
+	if err != nil {
+		err = fmt.Errorf("cache update error %s", err)
+		return
+	}
+	pkgsSet := map[string]bool{}
+	// packages reading changed testdata and embedded files
+	for fname := range inputBlocks(is.workDir, changes) {
+		if pkgPath := is.cache.filePkgPath(fname); pkgPath != "" {
+			pkgsSet[pkgPath] = true
+		}
+	}
+	modules, err := changedModules(ctx, is.gitCmd, changes)
+	if err != nil {
+		err = fmt.Errorf("changedModules error %s", err)
+		return
+	}
+	importers, err := modImporters(ctx, is.workDir, is.cache.moduleName, modules)
+	if err != nil {
+		err = fmt.Errorf("modImporters error %s", err)
+		return
+	}
+	for pkgPath := range importers {
+		pkgsSet[pkgPath] = true
+	}
+	semanticFiles := map[string]bool{}
+	for _, change := range changes {
+		if strings.HasSuffix(change.fpathOld, ".go") && (change.fpath == "" ||
+			filepath.Dir(change.fpathOld) != filepath.Dir(change.fpath)) {
+			// file deleted or moved from package
+			pkgsSet[is.cache.dirPkgPath(filepath.Dir(change.fpathOld))] = true
+		}
+		if !strings.HasSuffix(change.fpath, ".go") {
+			continue
+		}
+		// comments and formatting do not change behavior
+		changed, ok := semanticFiles[change.fpath]
+		if !ok {
+			changed = semanticChange(ctx, is.gitCmd, change)
+			semanticFiles[change.fpath] = changed
+		}
+		if !changed {
+			continue
+		}
+		if pkgPath := is.cache.filePkgPath(change.fpath); pkgPath != "" {
+			pkgsSet[pkgPath] = true
+		}
+	}
+	if len(pkgsSet) == 0 {
+		// no changes to test
+		return
+	}
+
+	importPaths := is.cache.importPaths(mapStrToSlice(pkgsSet))
+	var pkgPaths []string
+	for pkgPath := range importPaths {
+		pkgPaths = append(pkgPaths, pkgPath)
+	}
+	sort.Strings(pkgPaths)
+	for _, pkgPath := range pkgPaths {
+		for _, fname := range is.cache.testFiles(pkgPath) {
+			var names []string
+			names, err = testFuncsInFile(filepath.Join(is.workDir, fname))
+			if err != nil {
+				err = fmt.Errorf("parse test file error %s", err)
+				return
+			}
+			for _, name := range names {
+				testName := pkgPath + "." + name
+				testsList = append(testsList, testName)
+				if is.explain {
+					path := importPaths[pkgPath]
+					is.explanations = append(is.explanations, Explanation{
+						Test:    testName,
+						Changed: path[len(path)-1],
+						Path:    path,
+					})
+				}
+			}
+		}
+	}
+	return true, testsList, nil, nil
+}
+
+// testFuncsInFile returns names of Test funcs in file
+func testFuncsInFile(fname string) ([]string, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), fname, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, decl := range f.Decls {
+		funDecl, ok := decl.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+		if funDecl.Recv == nil && strings.HasPrefix(funDecl.Name.Name, "Test") &&
+			funDecl.Name.Name != "TestMain" {
+			names = append(names, funDecl.Name.Name)
+		}
+	}
+	return names, nil
+}
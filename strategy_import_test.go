@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestImportGraphStrategyTestsToRun(t *testing.T) {
+	testDir := filepath.Join(os.TempDir(), "test_import_graph_strategy_tests_to_run")
+	gitCmdRun := NewGitCmd(testDir)
+
+	pkgAFilePath := filepath.Join("pkga", "f.go")
+	pkgATestFilePath := filepath.Join("pkga", "f_test.go")
+	pkgBFilePath := filepath.Join("pkgb", "f.go")
+
+	files := map[string][]byte{
+		"go.mod":    gomod,
+		"file_a.go": fileA, "file_b.go": fileB, "file_test.go": testFile,
+		pkgAFilePath: pkgAFile, pkgBFilePath: pkgBFile,
+		pkgATestFilePath: pkgATestFile,
+	}
+	logger := log.New(ioutil.Discard, "", 0)
+	setupTestGitDir(t,
+		testDir, files,
+		[]string{
+			"go.mod", "file_a.go", "file_b.go", "file_test.go",
+			pkgAFilePath, pkgBFilePath, pkgATestFilePath,
+		},
+	)
+	defer func() {
+		if !t.Failed() {
+			_ = os.RemoveAll(testDir)
+		}
+	}()
+	pkgaTests := []string{
+		"git-diff-strategy-test-run/pkga.TestPkgAFunc",
+		"git-diff-strategy-test-run/pkga.TestPkgAMethodOnPointer",
+		"git-diff-strategy-test-run/pkga.TestPkgBMethodOnValue",
+	}
+	cases := []struct {
+		desc            string
+		setup, tearDown func() error
+		outTests        []string
+		explanation     string
+		err             error
+	}{
+		{desc: "No changes in files"},
+		{
+			desc: "Update pkgb imported by pkga",
+			setup: func() error {
+				return ioutil.WriteFile(
+					filepath.Join(testDir, pkgBFilePath), pkgBFileUpdateF, 0600)
+			},
+			tearDown: func() error {
+				return gitCmdRun("commit", "-am", "commit pkgb changes")
+			},
+			outTests:    pkgaTests,
+			explanation: "git-diff-strategy-test-run/pkga.TestPkgAFunc reaches git-diff-strategy-test-run/pkgb: git-diff-strategy-test-run/pkga -> git-diff-strategy-test-run/pkgb",
+		},
+		{
+			desc: "Update file_b.go in root package",
+			setup: func() error {
+				return ioutil.WriteFile(
+					filepath.Join(testDir, "file_b.go"), fileBUpdateMax, 0600)
+			},
+			tearDown: func() error {
+				return gitCmdRun("commit", "-am", "commit file_b.go changes")
+			},
+			outTests: []string{"git-diff-strategy-test-run.TestAdd",
+				"git-diff-strategy-test-run.TestMinMaxAdd",
+				"git-diff-strategy-test-run.TestSub"},
+			explanation: "git-diff-strategy-test-run.TestAdd reaches git-diff-strategy-test-run: git-diff-strategy-test-run",
+		},
+		{
+			desc: "Comment in pkga test file",
+			setup: func() error {
+				return ioutil.WriteFile(
+					filepath.Join(testDir, pkgATestFilePath),
+					append(pkgATestFile, []byte("// update\n")...), 0600)
+			},
+			tearDown: func() error {
+				return gitCmdRun("commit", "-am", "commit pkga test comment")
+			},
+		},
+		{
+			desc: "Update pkga test file",
+			setup: func() error {
+				return ioutil.WriteFile(
+					filepath.Join(testDir, pkgATestFilePath),
+					append(pkgATestFile, []byte("// update\nfunc helper() {}\n")...), 0600)
+			},
+			tearDown: func() error {
+				return gitCmdRun("commit", "-am", "commit pkga test changes")
+			},
+			outTests: pkgaTests,
+		},
+		{
+			desc: "Delete pkgb file imported by pkga",
+			setup: func() error {
+				return os.Remove(filepath.Join(testDir, pkgBFilePath))
+			},
+			outTests:    pkgaTests,
+			explanation: "git-diff-strategy-test-run/pkga.TestPkgAFunc reaches git-diff-strategy-test-run/pkgb: git-diff-strategy-test-run/pkga -> git-diff-strategy-test-run/pkgb",
+		},
+	}
+	strategy := NewImportGraphStrategy(testDir, NewGitCMD(testDir), logger)
+	strategy.SetExplain(true)
+	for i, tc := range cases {
+		execTestHelper(t, i, tc.desc, tc.setup)
+		_, testsList, _, err := strategy.TestsToRun(context.Background())
+		execTestHelper(t, i, tc.desc, tc.tearDown)
+		if isUnexpectedErr(t, i, tc.desc, tc.err, err) {
+			continue
+		}
+		sort.Strings(testsList)
+		if !reflect.DeepEqual(tc.outTests, testsList) {
+			t.Errorf("case [%d] %s\nexpected Tests %+v\ngot %+v", i, tc.desc, tc.outTests, testsList)
+		}
+		explanations := strategy.Explanations()
+		if len(explanations) != len(tc.outTests) {
+			t.Errorf("case [%d] %s\nexpected explanation for each test, got %v", i, tc.desc, explanations)
+			continue
+		}
+		if tc.explanation != "" && explanations[0].String() != tc.explanation {
+			t.Errorf("case [%d] %s\nexpected explanation %q\ngot %q", i, tc.desc, tc.explanation, explanations[0])
+		}
+	}
+}
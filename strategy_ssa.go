@@ -1,18 +1,28 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/gob"
 	"errors"
 	"fmt"
+	"go/types"
 	"log"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"golang.org/x/tools/go/callgraph"
 	"golang.org/x/tools/go/callgraph/cha"
 	"golang.org/x/tools/go/callgraph/rta"
 	"golang.org/x/tools/go/callgraph/static"
+	"golang.org/x/tools/go/callgraph/vta"
 	"golang.org/x/tools/go/packages"
 	"golang.org/x/tools/go/pointer"
 	"golang.org/x/tools/go/ssa"
@@ -22,24 +32,114 @@ import (
 // ErrBuildFailed is the error returned when source build fails
 var ErrBuildFailed = errors.New("build failed")
 
+// ErrBudgetExceeded is the error returned when all analyses
+// exceed time or memory budget
+var ErrBudgetExceeded = errors.New("analysis budget exceeded")
+
+// memCheckInterval between heap size checks of analysis
+const memCheckInterval = 100 * time.Millisecond
+
+// analysisEnv names analysis to run by analysis process,
+// analysisBudgetEnv and analysisMemEnv its time budget
+// and heap limit in bytes
+const (
+	analysisEnv       = "GTR_ANALYSIS"
+	analysisBudgetEnv = "GTR_ANALYSIS_BUDGET"
+	analysisMemEnv    = "GTR_ANALYSIS_MEMORY"
+)
+
+// exit codes of analysis process
+const (
+	exitBuildFailed = 3
+	exitTimeLimit   = 4
+	exitMemLimit    = 5
+)
+
+// analysisCommand returns executable of analysis process
+var analysisCommand = os.Executable
+
+// analysisDowngrades maps analysis to less precise
+// and cheaper one to use when budget is exceeded
+var analysisDowngrades = map[string]string{
+	"pointer": "rta",
+	"vta":     "rta",
+	"rta":     "cha",
+}
+
+// callGraphFuncs builds call graph of program by analysis
+var callGraphFuncs = map[string]func(
+	program *ssa.Program, testPkgs []*ssa.Package) (*callgraph.Graph, error){
+	"pointer": func(program *ssa.Program, testPkgs []*ssa.Package) (*callgraph.Graph, error) {
+		result, err := pointer.Analyze(&pointer.Config{
+			Mains:          ssautil.MainPackages(testPkgs),
+			BuildCallGraph: true,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return result.CallGraph, nil
+	},
+	"static": func(program *ssa.Program, testPkgs []*ssa.Package) (*callgraph.Graph, error) {
+		return static.CallGraph(program), nil
+	},
+	"cha": func(program *ssa.Program, testPkgs []*ssa.Package) (*callgraph.Graph, error) {
+		return cha.CallGraph(program), nil
+	},
+	"rta": func(program *ssa.Program, testPkgs []*ssa.Package) (*callgraph.Graph, error) {
+		var ssaFuncs []*ssa.Function
+		for fn := range ssautil.AllFunctions(program) {
+			if fn != nil {
+				ssaFuncs = append(ssaFuncs, fn)
+			}
+		}
+		return rta.Analyze(ssaFuncs, true).CallGraph, nil
+	},
+	"vta": func(program *ssa.Program, testPkgs []*ssa.Package) (*callgraph.Graph, error) {
+		// cha graph is used to resolve calls
+		// before types of variables are known
+		return vta.CallGraph(ssautil.AllFunctions(program), cha.CallGraph(program)), nil
+	},
+}
+
 var _ Strategy = (*SSAStrategy)(nil)
+var _ Explainer = (*SSAStrategy)(nil)
+var _ SubTestParenter = (*SSAStrategy)(nil)
 
 // SSAStrategy finds test to run from git diffs
-// and pointer analysis
+// and pointer analysis, tests index is cached in .gtr and
+// only packages which sources or dependencies changed
+// are reanalyzed. With budget analysis runs in separate process,
+// analysis exceeding time or memory budget is killed and
+// downgraded to less precise one, precise analysis is redone
+// on next changes, fallback strategy used on build failures
+// and when all analyses exceed budget
 type SSAStrategy struct {
-	analysis string
-	workDir  string
-	gitCmd   *GitCMD
-	log      *log.Logger
+	analysis     string
+	workDir      string
+	gitCmd       GitCMD
+	log          *log.Logger
+	explain      bool
+	explanations []Explanation
+	parents      map[string][]string // of selected subtests
+	mu           sync.Mutex
+	cache        *ssaCache
+	fallback     Strategy
+	budget       time.Duration // per analysis
+	memLimit     uint64        // max heap bytes
 }
 
 // NewSSAStrategy returns strategy
-func NewSSAStrategy(analysis, workDir string, logger *log.Logger) *SSAStrategy {
+func NewSSAStrategy(
+	analysis, workDir string,
+	gitCmd GitCMD,
+	logger *log.Logger,
+) *SSAStrategy {
 	return &SSAStrategy{
 		analysis: analysis,
 		workDir:  workDir,
-		gitCmd:   NewGitCMD(workDir),
+		gitCmd:   gitCmd,
 		log:      logger,
+		cache:    newSSACache(workDir, buildFlags(analysis)),
 	}
 }
 
@@ -47,17 +147,61 @@ func (ss *SSAStrategy) CoverageEnabled() bool {
 	return false
 }
 
+// SetExplain enables recording of callgraph paths
+// from tests to changed funcs
+func (ss *SSAStrategy) SetExplain(explain bool) {
+	ss.explain = explain
+	if ex, ok := ss.fallback.(Explainer); ok {
+		ex.SetExplain(explain)
+	}
+}
+
+// SetFallback sets strategy to use when build fails or
+// all analyses exceed budget
+func (ss *SSAStrategy) SetFallback(fallback Strategy) {
+	ss.fallback = fallback
+	ss.SetExplain(ss.explain)
+}
+
+// SetBudget sets time and heap size in bytes each analysis
+// may take before downgrade to less precise one,
+// 0 is unlimited
+func (ss *SSAStrategy) SetBudget(budget time.Duration, memLimit uint64) {
+	ss.budget = budget
+	ss.memLimit = memLimit
+}
+
+// Explanations returns paths of last TestsToRun call
+func (ss *SSAStrategy) Explanations() []Explanation {
+	return ss.explanations
+}
+
 // TestsToRun returns names of tests and subtests
 // affected by files
 // TODO test on different modules and Gopath version
 // TODO improve performance, TestsToRun testing takes more than 4s
 func (ss *SSAStrategy) TestsToRun(ctx context.Context) (
 	runAll bool, testsList, subTestsList []string, err error) {
+	// runs may overlap on canceled tasks
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.explanations = nil
+	ss.parents = nil
 	changes, err := ss.gitCmd.Diff(ctx)
 	if err != nil {
 		err = fmt.Errorf("gitCmd.Diff error %s", err)
 		return
 	}
+	// declarations deleted, moved or in deleted files
+	removed := removedBlocks(ctx, ss.gitCmd, changes)
+	// funcs and vars reading changed testdata and embedded files
+	inputs := inputBlocks(ss.workDir, changes)
+	// modules which versions changed in go.mod or go.sum
+	modules, err := changedModules(ctx, ss.gitCmd, changes)
+	if err != nil {
+		err = fmt.Errorf("changedModules error %s", err)
+		return
+	}
 	// filter out none go files
 	n := 0
 	for _, x := range changes {
@@ -69,7 +213,8 @@ func (ss *SSAStrategy) TestsToRun(ctx context.Context) (
 	}
 
 	changes = changes[:n]
-	if len(changes) == 0 {
+	if len(changes) == 0 && len(removed) == 0 &&
+		len(inputs) == 0 && len(modules) == 0 {
 		// no changes to test
 		return
 	}
@@ -80,7 +225,13 @@ func (ss *SSAStrategy) TestsToRun(ctx context.Context) (
 		if _, ok := fileInfos[change.fpath]; ok {
 			continue
 		}
-		info, err = getFileInfo(filepath.Join(ss.workDir, change.fpath), nil)
+		// content after changes, commit or index
+		// differs from working tree
+		var src []byte
+		src, err = ss.gitCmd.Content(ctx, change.fpath)
+		if err == nil {
+			info, err = getFileInfo(filepath.Join(ss.workDir, change.fpath), src)
+		}
 		if err != nil {
 			fmt.Fprintln(os.Stderr, "\n=======\033[31m Build Failed \033[39m=======")
 			fmt.Fprintf(os.Stderr, "%s", err)
@@ -95,97 +246,186 @@ func (ss *SSAStrategy) TestsToRun(ctx context.Context) (
 		err = fmt.Errorf("changesToFileBlocks error %s", cerr)
 		return
 	}
+	// comments and formatting do not change behavior
+	changedBlocks = semanticBlocks(ctx, ss.gitCmd, changes, changedBlocks)
+	mergeBlocks(changedBlocks, inputs)
 
-	moduleName, program, filePathToPkg, allPkgs, analyzeErr := analyzeGoCode(ctx, ss.workDir)
-	if analyzeErr != nil {
-		err = ErrBuildFailed
+	err = ss.cache.update(ctx)
+	if err != nil {
+		err = fmt.Errorf("cache update error %s", err)
 		return
 	}
-
-	// TODO test with libraries without entry point
-	var testPkgs []*ssa.Package
-	for _, pkg := range allPkgs {
-		if strings.HasSuffix(pkg.Pkg.Path(), ".test") {
-			testPkgs = append(testPkgs, pkg)
+	// find funcs, package level vars, consts,
+	// types and init funcs from changed blocks
+	changedFuncs := map[string]string{}
+	changedGlobals := map[string]string{}
+	initPkgs := map[string]string{}
+	pkgsSet := map[string]bool{}
+	for fname, info := range changedBlocks {
+		pkgPath := ss.cache.filePkgPath(fname)
+		if pkgPath == "" {
+			continue
+		}
+		declPkgPath := ss.cache.fileDeclPkgPath(fname)
+		for _, block := range info.blocks {
+			switch {
+			case block.typ&(BlockFunc|BlockMethod) > 0:
+				changedFuncs[declPkgPath+"."+block.name] = explainedBlock(fname, block)
+			case block.typ&(BlockVar|BlockConst|BlockType) > 0:
+				changedGlobals[declPkgPath+"."+block.name] = explainedBlock(fname, block)
+			case block.typ&BlockInit > 0:
+				initPkgs[pkgPath] = explainedBlock(fname, block)
+			default:
+				continue
+			}
+			pkgsSet[pkgPath] = true
 		}
 	}
-	config := &pointer.Config{
-		Mains:          ssautil.MainPackages(testPkgs),
-		BuildCallGraph: true,
-	}
-	var graph *callgraph.Graph
-	// configure analysis
-	switch ss.analysis {
-	case "pointer":
-		result, aerr := pointer.Analyze(config)
-		if aerr != nil {
-			err = aerr
+	if len(modules) > 0 {
+		// like init funcs changed dependencies may
+		// change behavior of all tests of importers
+		var importers map[string][]string
+		importers, err = modImporters(ctx, ss.workDir, ss.cache.moduleName, modules)
+		if err != nil {
+			err = fmt.Errorf("modImporters error %s", err)
 			return
 		}
-		graph = result.CallGraph
-	case "static":
-		graph = static.CallGraph(program)
-	case "cha":
-		graph = cha.CallGraph(program)
-	case "rta":
-		var ssaFuncs []*ssa.Function
-		for fn := range ssautil.AllFunctions(program) {
-			if fn != nil {
-				ssaFuncs = append(ssaFuncs, fn)
-			}
+		for pkgPath, path := range importers {
+			initPkgs[pkgPath] = "go.mod:" + path[len(path)-1]
+			pkgsSet[pkgPath] = true
 		}
-		graph = rta.Analyze(ssaFuncs, true).CallGraph
-	default:
-		return // unhandled analysis
 	}
-	graph.DeleteSyntheticNodes() // check
-	// find nodes from changed blocks
-	changedNodes := map[*callgraph.Node]bool{}
-	for fn := range graph.Nodes {
-		if fn == nil || fn.Package() == nil {
-			continue
+	removedFuncs := map[string]string{}
+	removedGlobals := map[string]string{}
+	// removed code is only in index of previous analysis,
+	// all tests of package are selected without it
+	notIndexedPkgs := map[string]string{}
+	for fname, info := range removed {
+		pkgPath := ss.cache.dirPkgPath(filepath.Dir(fname))
+		declPkgPath := pkgPath
+		if strings.HasSuffix(info.pkgName, "_test") {
+			// external test package
+			declPkgPath += "_test"
 		}
-		pkgPath := fn.Package().Pkg.Path()
-		for fname, info := range changedBlocks {
-			if pkgPath != filePathToPkg[fname] {
+		for _, block := range info.blocks {
+			switch {
+			case block.typ&(BlockFunc|BlockMethod) > 0:
+				removedFuncs[declPkgPath+"."+block.name] = explainedRemovedBlock(fname, block)
+			case block.typ&(BlockVar|BlockConst|BlockType) > 0:
+				removedGlobals[declPkgPath+"."+block.name] = explainedRemovedBlock(fname, block)
+			case block.typ&BlockInit > 0:
+				initPkgs[pkgPath] = explainedRemovedBlock(fname, block)
+			default:
 				continue
 			}
-			for _, block := range info.blocks {
-				// store all nodes
-				if (block.typ&BlockFunc > 0 && fn.Name() == block.name) ||
-					(block.typ&BlockMethod > 0 && len(fn.Params) > 0 &&
-						strings.HasSuffix(fn.Params[0].Type().String()+"."+fn.Name(), block.name)) {
-					changedNodes[graph.Nodes[fn]] = true
-					break
-				}
+			pkgsSet[pkgPath] = true
+			if block.typ&BlockInit == 0 && ss.cache.index[pkgPath] == nil {
+				notIndexedPkgs[pkgPath] = explainedRemovedBlock(fname, block)
 			}
 		}
 	}
-	if len(changedNodes) == 0 {
+	if len(pkgsSet) == 0 {
 		ss.log.Println("no updated nodes found")
 		return
 	}
-	allTests := getAllTestsInModule(moduleName, graph)
+	// analyze only packages which tests may reach changes
+	// and index is outdated
+	pkgPaths := ss.cache.dependents(mapStrToSlice(pkgsSet))
+	// removed code is only in index of previous
+	// analysis, it is empty on first run
+	removedTests := ss.cache.tests(pkgPaths)
+	if len(notIndexedPkgs) > 0 {
+		ss.log.Printf("no index of removed code in %d packages, selecting their tests\n",
+			len(notIndexedPkgs))
+	}
+	for global, changed := range removedGlobals {
+		for _, key := range ss.cache.refs(pkgPaths, global) {
+			if _, ok := removedFuncs[key]; !ok {
+				removedFuncs[key] = changed
+			}
+		}
+	}
+	outdated := ss.cache.outdated(pkgPaths)
+	if imprecise := ss.cache.imprecise(pkgPaths, ss.analysis); len(imprecise) > 0 {
+		// index built when analysis exceeded budget
+		ss.log.Printf("redoing %s analysis of %d packages\n", ss.analysis, len(imprecise))
+		outdatedSet := map[string]bool{}
+		for _, path := range append(outdated, imprecise...) {
+			outdatedSet[path] = true
+		}
+		outdated = mapStrToSlice(outdatedSet)
+		sort.Strings(outdated)
+	}
+	if len(outdated) > 0 {
+		patterns := outdated
+		if len(ss.cache.index) == 0 {
+			// first run, index all
+			patterns = []string{ss.workDir + "/..."}
+			outdated = ss.cache.allPkgs()
+		}
+		hashes := ss.cache.indexHashes(outdated)
+		ss.log.Printf("analyzing %d packages\n", len(outdated))
+		index, analysis, aerr := ss.analyze(ctx, patterns)
+		if (aerr == ErrBuildFailed || aerr == ErrBudgetExceeded) && ss.fallback != nil {
+			ss.log.Printf("%v, using fallback\n", aerr)
+			return ss.fallbackTestsToRun(ctx)
+		}
+		if aerr != nil {
+			err = aerr
+			return
+		}
+		ss.setIndex(hashes, index, analysis)
+	}
 
+	// funcs using changed vars, consts and types
+	for global, changed := range changedGlobals {
+		for _, key := range ss.cache.refs(pkgPaths, global) {
+			if _, ok := changedFuncs[key]; !ok {
+				changedFuncs[key] = changed
+			}
+		}
+	}
+	allTests := ss.cache.tests(pkgPaths)
 	testsSet := map[string]bool{}
 	subTests := map[string]bool{}
-	for tnode := range allTests {
-		callgraph.PathSearch(tnode, func(n *callgraph.Node) bool {
-			if !changedNodes[n] {
-				return false
+	parents := map[string]map[string]bool{}
+	// selectTests adds tests and subtests reaching changed funcs
+	selectTests := func(tests []*testIndex, changedFuncs map[string]string) {
+		var changedKeys []string
+		for key := range changedFuncs {
+			changedKeys = append(changedKeys, key)
+		}
+		sort.Strings(changedKeys)
+		for _, test := range tests {
+			changed, callPath := test.reaches(changedKeys)
+			if changed == "" {
+				continue
 			}
-			funName := tnode.Func.Name()
-			pkgPath := tnode.Func.Pkg.Pkg.Path()
+			selected := map[string]bool{}
+			funName := test.Name
+			// go test runs external tests by package path
+			pkgPath := testRootPkg(test.Pkg)
 			for {
 				idx := strings.LastIndexByte(funName, '$')
 				// is anon func
-				for tn, dic := range allTests {
-					if subName, ok := dic[funName]; ok {
+				for _, tn := range tests {
+					if subName, ok := tn.SubTests[funName]; ok {
 						// add all subtest with this helper
 						// TODO maybe use pkg as prefix
 						subTests[subName] = true
-						if strings.LastIndexByte(tn.Func.Name(), '$') == -1 {
-							testsSet[fmt.Sprintf("%s.%s", pkgPath, tn.Func.Name())] = true
+						selected[subName] = true
+						parent := tn.Name
+						if idx := strings.IndexByte(parent, '$'); idx > -1 {
+							parent = parent[:idx]
+						}
+						if parents[subName] == nil {
+							parents[subName] = map[string]bool{}
+						}
+						parents[subName][fmt.Sprintf("%s.%s", pkgPath, parent)] = true
+						if strings.LastIndexByte(tn.Name, '$') == -1 {
+							name := fmt.Sprintf("%s.%s", pkgPath, tn.Name)
+							testsSet[name] = true
+							selected[name] = true
 						}
 
 					}
@@ -193,19 +433,273 @@ func (ss *SSAStrategy) TestsToRun(ctx context.Context) (
 				if idx > -1 {
 					funName = funName[0:idx]
 				} else if len(funName) > 4 && funName[0:4] == "Test" {
-					testsSet[fmt.Sprintf("%s.%s", pkgPath, funName)] = true
+					name := fmt.Sprintf("%s.%s", pkgPath, funName)
+					testsSet[name] = true
+					selected[name] = true
 					break
 				} else {
 					break
 				}
 			}
-			return true
-		})
+			if ss.explain {
+				for _, name := range mapStrToSlice(selected) {
+					ss.explanations = append(ss.explanations, Explanation{
+						Test:    name,
+						Changed: changedFuncs[changed],
+						Path:    callPath,
+					})
+				}
+			}
+		}
+	}
+	selectTests(allTests, changedFuncs)
+	// removed tests and their closures are not run
+	n = 0
+	for _, test := range removedTests {
+		name := test.Name
+		if idx := strings.IndexByte(name, '$'); idx > -1 {
+			name = name[:idx]
+		}
+		if _, ok := removedFuncs[test.Pkg+"."+name]; !ok {
+			removedTests[n] = test
+			n++
+		}
+	}
+	selectTests(removedTests[:n], removedFuncs)
+	if len(initPkgs) > 0 {
+		// init funcs run before all tests of dependent packages
+		var changedPkgs []string
+		for pkgPath := range initPkgs {
+			changedPkgs = append(changedPkgs, pkgPath)
+		}
+		importPaths := ss.cache.importPaths(changedPkgs)
+		for _, test := range allTests {
+			path := importPaths[testRootPkg(test.Pkg)]
+			if path == nil || !strings.HasPrefix(test.Name, "Test") ||
+				test.Name == "TestMain" || strings.IndexByte(test.Name, '$') > -1 {
+				continue
+			}
+			name := fmt.Sprintf("%s.%s", testRootPkg(test.Pkg), test.Name)
+			testsSet[name] = true
+			if ss.explain {
+				ss.explanations = append(ss.explanations, Explanation{
+					Test:    name,
+					Changed: initPkgs[path[len(path)-1]],
+					Path:    path,
+				})
+			}
+		}
+	}
+	for _, test := range allTests {
+		pkgPath := testRootPkg(test.Pkg)
+		changed, ok := notIndexedPkgs[pkgPath]
+		if !ok || !strings.HasPrefix(test.Name, "Test") ||
+			test.Name == "TestMain" || strings.IndexByte(test.Name, '$') > -1 {
+			continue
+		}
+		name := fmt.Sprintf("%s.%s", pkgPath, test.Name)
+		testsSet[name] = true
+		if ss.explain {
+			ss.explanations = append(ss.explanations, Explanation{
+				Test:    name,
+				Changed: changed,
+				Path:    []string{pkgPath},
+			})
+		}
+	}
+	sortExplanations(ss.explanations)
+	ss.parents = map[string][]string{}
+	for subName, names := range parents {
+		ss.parents[subName] = mapStrToSlice(names)
 	}
 
 	return true, mapStrToSlice(testsSet), mapStrToSlice(subTests), nil
 }
 
+// SubTestParents returns tests running subtests
+// selected by last TestsToRun call
+func (ss *SSAStrategy) SubTestParents() map[string][]string {
+	return ss.parents
+}
+
+// setIndex stores index analyzed by analysis in cache
+func (ss *SSAStrategy) setIndex(hashes map[string]string, index map[string]*pkgIndex,
+	analysis string) {
+	err := ss.cache.setIndex(hashes, index, analysis)
+	if err != nil {
+		ss.log.Printf("save index error %v\n", err)
+	}
+}
+
+// fallbackTestsToRun returns tests of fallback strategy
+func (ss *SSAStrategy) fallbackTestsToRun(ctx context.Context) (
+	runAll bool, testsList, subTestsList []string, err error) {
+	runAll, testsList, subTestsList, err = ss.fallback.TestsToRun(ctx)
+	if ex, ok := ss.fallback.(Explainer); ok {
+		ss.explanations = ex.Explanations()
+	}
+	return
+}
+
+// analyze returns tests index by package of packages matching
+// patterns and analysis used, with budget analysis runs in
+// process which exits when it exceeds budget and next less
+// precise analysis is used
+func (ss *SSAStrategy) analyze(ctx context.Context, patterns []string) (
+	map[string]*pkgIndex, string, error) {
+	for analysis := ss.analysis; analysis != ""; analysis = analysisDowngrades[analysis] {
+		if _, ok := callGraphFuncs[analysis]; !ok {
+			return nil, "", fmt.Errorf("unknown analysis %s", analysis)
+		}
+		if ss.budget == 0 && ss.memLimit == 0 {
+			index, err := analyzeTests(ctx, ss.workDir, analysis, patterns, nil)
+			return index, analysis, err
+		}
+		index, exceeded, err := ss.analyzeProcess(ctx, analysis, patterns)
+		if err != nil {
+			return nil, "", err
+		}
+		if exceeded != "" {
+			ss.log.Printf("%s analysis exceeded %s budget\n", analysis, exceeded)
+			continue
+		}
+		if analysis != ss.analysis {
+			ss.log.Printf("%s analysis used\n", analysis)
+		}
+		return index, analysis, nil
+	}
+	return nil, "", ErrBudgetExceeded
+}
+
+// analyzeProcess runs analysis in process which exits when
+// analysis takes longer than budget or its heap grows over
+// memory limit, process is killed on canceled ctx,
+// returns exceeded budget
+func (ss *SSAStrategy) analyzeProcess(ctx context.Context, analysis string, patterns []string) (
+	index map[string]*pkgIndex, exceeded string, err error) {
+	bin, err := analysisCommand()
+	if err != nil {
+		return nil, "", err
+	}
+	cmd := exec.CommandContext(ctx, bin, append([]string{ss.workDir}, patterns...)...)
+	cmd.Env = append(os.Environ(), analysisEnv+"="+analysis,
+		analysisBudgetEnv+"="+ss.budget.String(),
+		fmt.Sprintf("%s=%d", analysisMemEnv, ss.memLimit))
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = os.Stderr
+	err = cmd.Run()
+	if ctx.Err() != nil {
+		return nil, "", ctx.Err()
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		switch exitErr.ExitCode() {
+		case exitBuildFailed:
+			return nil, "", ErrBuildFailed
+		case exitTimeLimit:
+			return nil, ss.budget.String(), nil
+		case exitMemLimit:
+			return nil, fmt.Sprintf("%dMB memory", ss.memLimit>>20), nil
+		}
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("%s analysis error %v", analysis, err)
+	}
+	err = gob.NewDecoder(&out).Decode(&index)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s analysis index error %v", analysis, err)
+	}
+	return index, "", nil
+}
+
+// runAnalysis is analysis process of strategy, it indexes
+// tests of packages matching patterns in work dir, first
+// of args, and writes index to stdout, process exits when
+// analysis of loaded packages takes longer than budget or
+// its heap grows over memory limit, returns exit code
+func runAnalysis(ctx context.Context, analysis string, args []string) int {
+	budget, _ := time.ParseDuration(os.Getenv(analysisBudgetEnv))
+	memLimit, _ := strconv.ParseUint(os.Getenv(analysisMemEnv), 10, 64)
+	if memLimit > 0 {
+		// heap of process is used only by analysis
+		go func() {
+			var stats runtime.MemStats
+			for {
+				runtime.ReadMemStats(&stats)
+				if stats.HeapAlloc > memLimit {
+					os.Exit(exitMemLimit)
+				}
+				time.Sleep(memCheckInterval)
+			}
+		}()
+	}
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "analysis work dir expected")
+		return exitError
+	}
+	if _, ok := callGraphFuncs[analysis]; !ok {
+		fmt.Fprintf(os.Stderr, "unknown analysis %s\n", analysis)
+		return exitError
+	}
+	var loaded func()
+	if budget > 0 {
+		loaded = func() {
+			time.AfterFunc(budget, func() {
+				os.Exit(exitTimeLimit)
+			})
+		}
+	}
+	index, err := analyzeTests(ctx, args[0], analysis, args[1:], loaded)
+	if err == ErrBuildFailed {
+		return exitBuildFailed
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s analysis error %v\n", analysis, err)
+		return exitError
+	}
+	if index == nil {
+		index = map[string]*pkgIndex{}
+	}
+	err = gob.NewEncoder(os.Stdout).Encode(index)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s analysis index error %v\n", analysis, err)
+		return exitError
+	}
+	return exitOK
+}
+
+// analyzeTests loads packages matching patterns with tests,
+// builds call graph by analysis and returns tests index by
+// package, loaded is called before analysis if not nil
+func analyzeTests(ctx context.Context, workDir, analysis string, patterns []string,
+	loaded func()) (map[string]*pkgIndex, error) {
+	moduleName, program, allPkgs, infos, err := analyzeGoCode(ctx, workDir, patterns)
+	if err != nil {
+		return nil, err
+	}
+	if loaded != nil {
+		loaded()
+	}
+
+	// TODO test with libraries without entry point
+	var testPkgs []*ssa.Package
+	for _, pkg := range allPkgs {
+		if pkg != nil && strings.HasSuffix(pkg.Pkg.Path(), ".test") {
+			testPkgs = append(testPkgs, pkg)
+		}
+	}
+	if len(testPkgs) == 0 {
+		// no tests
+		return nil, nil
+	}
+	graph, err := callGraphFuncs[analysis](program, testPkgs)
+	if err != nil {
+		return nil, err
+	}
+	graph.DeleteSyntheticNodes() // check
+	return indexTests(moduleName, program, infos, graph), nil
+}
+
 func changesToFileBlocks(changes []Change, fileInfos map[string]FileInfo) (map[string]FileInfo, error) {
 	changedBlocks := map[string]FileInfo{}
 	// process all changes
@@ -260,11 +754,14 @@ func changesToFileBlocks(changes []Change, fileInfos map[string]FileInfo) (map[s
 	return changedBlocks, nil
 }
 
-func analyzeGoCode(ctx context.Context, workDir string) (
+// analyzeGoCode loads packages matching patterns and builds
+// ssa program, ErrBuildFailed is returned on syntax and
+// type errors of packages
+func analyzeGoCode(ctx context.Context, workDir string, patterns []string) (
 	moduleName string,
 	prog *ssa.Program,
-	filePathToPkg map[string]string,
 	allPkgs []*ssa.Package,
+	infos map[*types.Package]*types.Info,
 	err error,
 ) {
 	cfg := &packages.Config{
@@ -283,57 +780,71 @@ func analyzeGoCode(ctx context.Context, workDir string) (
 
 	var pkgs []*packages.Package
 	// find all packages
-	pkgs, err = packages.Load(cfg, workDir+"/...")
+	pkgs, err = packages.Load(cfg, patterns...)
+	if ctx.Err() != nil {
+		err = ctx.Err()
+		return
+	}
 	if err != nil {
+		err = fmt.Errorf("packages.Load error %v", err)
 		return
 	}
 
 	for i := range pkgs {
-		if len(pkgs[i].Errors) > 0 {
-			fmt.Fprintln(os.Stderr, "\n=======\033[31m Build Failed \033[39m=======")
-			select {
-			case <-ctx.Done():
-				fmt.Fprintln(os.Stderr, "task canceled")
-				err = errors.New("task canceled")
+		if len(pkgs[i].Errors) == 0 {
+			continue
+		}
+		for _, perr := range pkgs[i].Errors {
+			if perr.Kind != packages.ParseError && perr.Kind != packages.TypeError {
+				err = fmt.Errorf("packages.Load error %v", perr)
 				return
-			default:
 			}
-			packages.PrintErrors(pkgs)
-			fmt.Fprintln(os.Stderr, "\n============================")
-			err = errors.New("packages.Load error")
-			return
 		}
+		fmt.Fprintln(os.Stderr, "\n=======\033[31m Build Failed \033[39m=======")
+		packages.PrintErrors(pkgs)
+		fmt.Fprintln(os.Stderr, "\n============================")
+		err = ErrBuildFailed
+		return
 	}
 
 	moduleName, err = getModuleName(workDir)
 	if err != nil {
+		err = fmt.Errorf("getModuleName error %v", err)
 		return
 	}
 
-	// TODO test without go mod, in GOPATH
-	filePathToPkg = map[string]string{}
+	infos = map[*types.Package]*types.Info{}
 	for _, pkg := range pkgs {
-		path := pkg.PkgPath
-		if !strings.HasPrefix(path, moduleName) {
-			// skip none module packages
-			continue
-		}
-		for _, file := range pkg.GoFiles {
-			lid := strings.LastIndexByte(file, '/')
-			if moduleName == path {
-				// root dir
-				filePathToPkg[file[lid+1:]] = path
-			} else {
-				filePathToPkg[path[len(moduleName)+1:]+"/"+file[lid+1:]] = path
-			}
-		}
+		infos[pkg.Types] = pkg.TypesInfo
 	}
 	// create program
-	prog, allPkgs = ssautil.Packages(pkgs, ssa.NaiveForm|ssa.SanityCheckFunctions)
+	// pointer analysis is unsound on generic func bodies
+	prog, allPkgs = ssautil.Packages(pkgs,
+		ssa.NaiveForm|ssa.SanityCheckFunctions|ssa.InstantiateGenerics)
 	prog.Build()
 	return
 }
 
+// hasTestingParam checks if func has *testing.T
+// or *testing.M param
+func hasTestingParam(fn *ssa.Function) bool {
+	params := fn.Signature.Params()
+	for i := 0; i < params.Len(); i++ {
+		ptr, ok := params.At(i).Type().(*types.Pointer)
+		if !ok {
+			continue
+		}
+		named, ok := ptr.Elem().(*types.Named)
+		if !ok || named.Obj().Pkg() == nil || named.Obj().Pkg().Path() != "testing" {
+			continue
+		}
+		if named.Obj().Name() == "T" || named.Obj().Name() == "M" {
+			return true
+		}
+	}
+	return false
+}
+
 func getAllTestsInModule(moduleName string, graph *callgraph.Graph) (
 	allTests map[*callgraph.Node]map[string]string,
 ) {
@@ -344,11 +855,9 @@ func getAllTestsInModule(moduleName string, graph *callgraph.Graph) (
 			!strings.HasPrefix(k.Package().Pkg.Path(), moduleName) {
 			continue
 		}
-		nodeType := k.Type().String()
 		// TODO what to do with helperTesting(t *testing.T[, args ...]) ?
 		// find testing funcs
-		if strings.Contains(nodeType, "*testing.T") ||
-			strings.Contains(nodeType, "*testing.M") {
+		if hasTestingParam(k) {
 			allTests[n] = nil
 			for _, block := range k.Blocks {
 				for j, instr := range block.Instrs {
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"io/ioutil"
 	"log"
@@ -10,10 +11,31 @@ import (
 	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/kr/pretty"
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/ssa"
 )
 
+// testBlockedAnalysisEnv names analysis which never
+// ends in analysis process of test binary
+const testBlockedAnalysisEnv = "GTR_TEST_BLOCKED_ANALYSIS"
+
+func TestMain(m *testing.M) {
+	// test binary is analysis process of ssa strategy
+	if analysis := os.Getenv(analysisEnv); analysis != "" {
+		if analysis == os.Getenv(testBlockedAnalysisEnv) {
+			callGraphFuncs[analysis] = func(program *ssa.Program, testPkgs []*ssa.Package) (*callgraph.Graph, error) {
+				time.Sleep(time.Hour)
+				return nil, nil
+			}
+		}
+		os.Exit(runAnalysis(context.Background(), analysis, os.Args[1:]))
+	}
+	os.Exit(m.Run())
+}
+
 func TestChangesToFileBlocks(t *testing.T) {
 	f1Blocks := []FileBlock{
 		{typ: BlockFunc, name: "main", start: 6, end: 8},
@@ -199,7 +221,7 @@ func main() {
 
 func add(a, b int) int {
 	// add comment
-	return a + b
+	return b + a
 }
 `)
 	fileBUpdateMax = []byte(`package main
@@ -225,6 +247,30 @@ func max(a, b int) int {
 	return b
 }
 `)
+	fileBRemoveMax = []byte(`package main
+
+const PI = 3.14
+
+func sub(a, b int) int {
+	return a - b
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	} else {
+		return b
+	}
+}
+`)
+	fileAMoveMax = append(append([]byte{}, fileAUpdateAdd...), []byte(`
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+`)...)
 	pkgAFile = []byte(`package pkga
 
 import (
@@ -371,8 +417,9 @@ func TestSSAStrategyTestsToRun(t *testing.T) {
 				pkgAFilePath, pkgBFilePath, pkgATestFilePath,
 			},
 		)
-		// TODO test for different analysis types
-		return NewSSAStrategy("pointer", testDir, logger)
+		ss := NewSSAStrategy("pointer", testDir, NewGitCMD(testDir), logger)
+		ss.SetExplain(true)
+		return ss
 	}
 
 	// teardown
@@ -386,6 +433,7 @@ func TestSSAStrategyTestsToRun(t *testing.T) {
 		desc                  string
 		setup, tearDown       func() error
 		outTests, outSubTests []string
+		explanations          []string
 		err                   error
 	}{
 		{desc: "No changes in files"},
@@ -414,6 +462,11 @@ func TestSSAStrategyTestsToRun(t *testing.T) {
 			},
 			outTests:    []string{"git-diff-strategy-test-run.TestMinMaxAdd"},
 			outSubTests: []string{"group test 1", "max"},
+			explanations: []string{
+				"git-diff-strategy-test-run.TestMinMaxAdd reaches file_b.go:max: git-diff-strategy-test-run.helperMax -> git-diff-strategy-test-run.max",
+				"group test 1 reaches file_b.go:max: git-diff-strategy-test-run.helperMax -> git-diff-strategy-test-run.max",
+				"max reaches file_b.go:max: git-diff-strategy-test-run.helperMax -> git-diff-strategy-test-run.max",
+			},
 		},
 		{
 			desc: "Check named imports",
@@ -425,9 +478,12 @@ func TestSSAStrategyTestsToRun(t *testing.T) {
 			tearDown: func() error {
 				return gitCmdRun("commit", "-am", "commit changes")
 			},
-			outTests: []string{"git-diff-strategy-test-run/pkga.TestPkgAFunc",
-				"git-diff-strategy-test-run/pkga.TestPkgBMethodOnValue"},
+			outTests:    []string{"git-diff-strategy-test-run/pkga.TestPkgAFunc"},
 			outSubTests: nil,
+			// whole file reindented, only F is changed semantically
+			explanations: []string{
+				"git-diff-strategy-test-run/pkga.TestPkgAFunc reaches pkgb/f.go:F: git-diff-strategy-test-run/pkga.TestPkgAFunc -> git-diff-strategy-test-run/pkga.F -> git-diff-strategy-test-run/pkgb.F",
+			},
 		},
 		{
 			desc: "Update pkgb.A type methods",
@@ -441,6 +497,34 @@ func TestSSAStrategyTestsToRun(t *testing.T) {
 			},
 			outTests:    []string{"git-diff-strategy-test-run/pkga.TestPkgBMethodOnValue"},
 			outSubTests: nil,
+			explanations: []string{
+				"git-diff-strategy-test-run/pkga.TestPkgBMethodOnValue reaches pkgb/f.go:A.MethodOnValue: git-diff-strategy-test-run/pkga.TestPkgBMethodOnValue -> (git-diff-strategy-test-run/pkgb.A).MethodOnValue",
+			},
+		},
+		{
+			desc: "Move max func to file_a.go",
+			setup: func() error {
+				err := ioutil.WriteFile(
+					filepath.Join(testDir, "file_b.go"), fileBRemoveMax, 0600)
+				if err != nil {
+					return err
+				}
+				return ioutil.WriteFile(
+					filepath.Join(testDir, "file_a.go"), fileAMoveMax, 0600)
+			},
+			tearDown: func() error {
+				return gitCmdRun("commit", "-am", "commit changes")
+			},
+			outTests:    []string{"git-diff-strategy-test-run.TestMinMaxAdd"},
+			outSubTests: []string{"group test 1", "max"},
+			explanations: []string{
+				"git-diff-strategy-test-run.TestMinMaxAdd reaches file_a.go:max: git-diff-strategy-test-run.helperMax -> git-diff-strategy-test-run.max",
+				"git-diff-strategy-test-run.TestMinMaxAdd reaches removed file_b.go:max: git-diff-strategy-test-run.helperMax -> git-diff-strategy-test-run.max",
+				"group test 1 reaches file_a.go:max: git-diff-strategy-test-run.helperMax -> git-diff-strategy-test-run.max",
+				"group test 1 reaches removed file_b.go:max: git-diff-strategy-test-run.helperMax -> git-diff-strategy-test-run.max",
+				"max reaches file_a.go:max: git-diff-strategy-test-run.helperMax -> git-diff-strategy-test-run.max",
+				"max reaches removed file_b.go:max: git-diff-strategy-test-run.helperMax -> git-diff-strategy-test-run.max",
+			},
 		},
 		// TODO add test with helper func in different packages
 		// TODO add test with different testing frameworks
@@ -467,8 +551,640 @@ func TestSSAStrategyTestsToRun(t *testing.T) {
 		if !reflect.DeepEqual(tc.outSubTests, subTestsList) {
 			t.Errorf("case [%d] %s\nexpected Subtests %+v\ngot %+v", i, tc.desc, tc.outSubTests, subTestsList)
 		}
+		// check path from test to changed func
+		var explanations []string
+		for _, e := range ssaStrategy.Explanations() {
+			explanations = append(explanations, e.String())
+		}
+		if tc.explanations != nil && !reflect.DeepEqual(tc.explanations, explanations) {
+			t.Errorf("case [%d] %s\nexpected Explanations %q\ngot %q", i, tc.desc, tc.explanations, explanations)
+		}
+	}
+
+}
+
+func TestSSAStrategyAnalysesCompare(t *testing.T) {
+	testDir := filepath.Join(os.TempDir(), "test_ssa_strategy_analyses_compare")
+	gitCmdRun := NewGitCmd(testDir)
+
+	pkgAFilePath := filepath.Join("pkga", "f.go")
+	pkgATestFilePath := filepath.Join("pkga", "f_test.go")
+	pkgBFilePath := filepath.Join("pkgb", "f.go")
+
+	files := map[string][]byte{
+		"go.mod":    gomod,
+		"file_a.go": fileA, "file_b.go": fileB, "file_test.go": testFile,
+		pkgAFilePath: pkgAFile, pkgBFilePath: pkgBFile,
+		pkgATestFilePath: pkgATestFile,
+	}
+	setupTestGitDir(t,
+		testDir, files,
+		[]string{
+			"go.mod", "file_a.go", "file_b.go", "file_test.go",
+			pkgAFilePath, pkgBFilePath, pkgATestFilePath,
+		},
+	)
+	defer func() {
+		if !t.Failed() {
+			_ = os.RemoveAll(testDir)
+		}
+	}()
+	logger := log.New(ioutil.Discard, "", 0)
+	// precise analyses should select same tests,
+	// cha and rta are less precise and may select more
+	precise := []string{"pointer", "vta"}
+	sound := []string{"cha", "rta"}
+	cases := []struct {
+		desc     string
+		fname    string
+		data     []byte
+		outTests []string
+	}{
+		{
+			desc:  "Update file_a.go file",
+			fname: "file_a.go", data: fileAUpdateAdd,
+			outTests: []string{"git-diff-strategy-test-run.TestAdd",
+				"git-diff-strategy-test-run.TestMinMaxAdd",
+				"git-diff-strategy-test-run.TestSub"},
+		},
+		{
+			desc:  "Update file_b.go file max func",
+			fname: "file_b.go", data: fileBUpdateMax,
+			outTests: []string{"git-diff-strategy-test-run.TestMinMaxAdd"},
+		},
+		{
+			desc:  "Check named imports",
+			fname: pkgBFilePath, data: pkgBFileUpdateF,
+			outTests: []string{"git-diff-strategy-test-run/pkga.TestPkgAFunc"},
+		},
+		{
+			desc:  "Update pkgb.A type methods",
+			fname: pkgBFilePath, data: pkgBFileUpdateMethods,
+			outTests: []string{"git-diff-strategy-test-run/pkga.TestPkgBMethodOnValue"},
+		},
+	}
+	strategies := map[string]*SSAStrategy{}
+	for _, analysis := range append(precise, sound...) {
+		strategies[analysis] = NewSSAStrategy(analysis, testDir, NewGitCMD(testDir), logger)
+	}
+	for i, tc := range cases {
+		execTestHelper(t, i, tc.desc, func() error {
+			return ioutil.WriteFile(filepath.Join(testDir, tc.fname), tc.data, 0600)
+		})
+		selected := map[string][]string{}
+		for analysis, ss := range strategies {
+			_, testsList, _, err := ss.TestsToRun(context.Background())
+			if isUnexpectedErr(t, i, tc.desc+" "+analysis, nil, err) {
+				continue
+			}
+			sort.Strings(testsList)
+			selected[analysis] = testsList
+		}
+		execTestHelper(t, i, tc.desc, func() error {
+			return gitCmdRun("commit", "-am", "commit changes")
+		})
+		for _, analysis := range precise {
+			if !reflect.DeepEqual(tc.outTests, selected[analysis]) {
+				t.Errorf("case [%d] %s\nexpected %s Tests %+v\ngot %+v",
+					i, tc.desc, analysis, tc.outTests, selected[analysis])
+			}
+		}
+		for _, analysis := range sound {
+			tests := map[string]bool{}
+			for _, name := range selected[analysis] {
+				tests[name] = true
+			}
+			for _, name := range tc.outTests {
+				if !tests[name] {
+					t.Errorf("case [%d] %s\nexpected %s Tests %+v to include %s",
+						i, tc.desc, analysis, selected[analysis], name)
+				}
+			}
+		}
+	}
+}
+
+func TestSSAStrategyGlobals(t *testing.T) {
+	var (
+		gomod = []byte(`module ssa-globals-test-run
+
+go 1.13
+`)
+		mainFile = []byte(`package main
+
+const limit = 10
+
+var base = 1
+
+func main() {}
+
+func capped(a int) int {
+	if a > limit {
+		return limit
+	}
+	return a
+}
+
+func inc(a int) int {
+	return a + base
+}
+
+type counter int
+
+func (c counter) next() counter {
+	return c + 1
+}
+`)
+		mainTestFile = []byte(`package main
+
+import "testing"
+
+func TestCapped(t *testing.T) {
+	if capped(20) != 10 {
+		t.Error("unexpected result")
+	}
+}
+
+func TestInc(t *testing.T) {
+	if inc(1) != 2 {
+		t.Error("unexpected result")
+	}
+}
+
+func TestNext(t *testing.T) {
+	if counter(1).next() != 2 {
+		t.Error("unexpected result")
+	}
+}
+`)
+		pkgCFile = []byte(`package pkgc
+
+var Ready bool
+
+func init() {
+	Ready = true
+}
+`)
+		pkgDFile = []byte(`package pkgd
+
+import "ssa-globals-test-run/pkgc"
+
+func IsReady() bool {
+	return pkgc.Ready
+}
+`)
+		pkgDTestFile = []byte(`package pkgd
+
+import "testing"
+
+func TestReady(t *testing.T) {
+	if !IsReady() {
+		t.Error("not ready")
+	}
+}
+`)
+	)
+	testDir := filepath.Join(os.TempDir(), "test_ssa_strategy_globals")
+	gitCmdRun := NewGitCmd(testDir)
+	pkgCFilePath := filepath.Join("pkgc", "c.go")
+	pkgDFilePath := filepath.Join("pkgd", "d.go")
+	pkgDTestFilePath := filepath.Join("pkgd", "d_test.go")
+	files := map[string][]byte{
+		"go.mod":  gomod,
+		"main.go": mainFile, "main_test.go": mainTestFile,
+		pkgCFilePath: pkgCFile, pkgDFilePath: pkgDFile, pkgDTestFilePath: pkgDTestFile,
+	}
+	setupTestGitDir(t, testDir, files, []string{"go.mod", "main.go", "main_test.go",
+		pkgCFilePath, pkgDFilePath, pkgDTestFilePath})
+	defer func() {
+		if !t.Failed() {
+			_ = os.RemoveAll(testDir)
+		}
+	}()
+	logger := log.New(ioutil.Discard, "", 0)
+	cases := []struct {
+		desc         string
+		fname        string
+		old, new     string
+		outTests     []string
+		explanations []string
+	}{
+		{
+			desc:  "Update const read by capped",
+			fname: "main.go", old: "const limit = 10", new: "const limit = 5 + 5",
+			outTests: []string{"ssa-globals-test-run.TestCapped"},
+			explanations: []string{
+				"ssa-globals-test-run.TestCapped reaches main.go:limit: ssa-globals-test-run.TestCapped -> ssa-globals-test-run.capped",
+			},
+		},
+		{
+			desc:  "Update var read by inc",
+			fname: "main.go", old: "var base = 1", new: "var base = 2 - 1",
+			outTests: []string{"ssa-globals-test-run.TestInc"},
+			explanations: []string{
+				"ssa-globals-test-run.TestInc reaches main.go:base: ssa-globals-test-run.TestInc -> ssa-globals-test-run.inc",
+			},
+		},
+		{
+			desc:  "Update named type with method",
+			fname: "main.go", old: "type counter int", new: "type counter int64",
+			outTests: []string{"ssa-globals-test-run.TestNext"},
+			explanations: []string{
+				"ssa-globals-test-run.TestNext reaches main.go:counter: ssa-globals-test-run.TestNext",
+			},
+		},
+		{
+			desc:  "Update init of imported package",
+			fname: pkgCFilePath, old: "Ready = true", new: "Ready = !false",
+			outTests: []string{"ssa-globals-test-run/pkgd.TestReady"},
+			explanations: []string{
+				"ssa-globals-test-run/pkgd.TestReady reaches pkgc/c.go:init: ssa-globals-test-run/pkgd -> ssa-globals-test-run/pkgc",
+			},
+		},
+	}
+	ss := NewSSAStrategy("pointer", testDir, NewGitCMD(testDir), logger)
+	ss.SetExplain(true)
+	for i, tc := range cases {
+		execTestHelper(t, i, tc.desc, func() error {
+			data, err := ioutil.ReadFile(filepath.Join(testDir, tc.fname))
+			if err != nil {
+				return err
+			}
+			data = bytes.Replace(data, []byte(tc.old), []byte(tc.new), 1)
+			return ioutil.WriteFile(filepath.Join(testDir, tc.fname), data, 0600)
+		})
+		_, testsList, _, err := ss.TestsToRun(context.Background())
+		execTestHelper(t, i, tc.desc, func() error {
+			return gitCmdRun("commit", "-am", "commit changes")
+		})
+		if isUnexpectedErr(t, i, tc.desc, nil, err) {
+			continue
+		}
+		sort.Strings(testsList)
+		if !reflect.DeepEqual(tc.outTests, testsList) {
+			t.Errorf("case [%d] %s\nexpected Tests %+v\ngot %+v", i, tc.desc, tc.outTests, testsList)
+		}
+		var explanations []string
+		for _, e := range ss.Explanations() {
+			explanations = append(explanations, e.String())
+		}
+		if !reflect.DeepEqual(tc.explanations, explanations) {
+			t.Errorf("case [%d] %s\nexpected Explanations %q\ngot %q", i, tc.desc, tc.explanations, explanations)
+		}
 	}
+}
 
+func TestSSAStrategyExternalTests(t *testing.T) {
+	var (
+		gomod = []byte(`module ssa-xtest-test-run
+
+go 1.13
+`)
+		pkgAFile = []byte(`package pkga
+
+func Double(a int) int {
+	return a * 2
+}
+
+func Triple(a int) int {
+	return a * 3
+}
+`)
+		pkgATestFile = []byte(`package pkga
+
+import "testing"
+
+func TestDouble(t *testing.T) {
+	if Double(2) != 4 {
+		t.Error("unexpected result")
+	}
+}
+`)
+		pkgAExtTestFile = []byte(`package pkga_test
+
+import (
+	"testing"
+
+	"ssa-xtest-test-run/pkga"
+)
+
+const factor = 3
+
+func triple(a int) int {
+	return pkga.Triple(a)
+}
+
+func TestTriple(t *testing.T) {
+	if triple(2) != 2*factor {
+		t.Error("unexpected result")
+	}
+}
+`)
+	)
+	testDir := filepath.Join(os.TempDir(), "test_ssa_strategy_external_tests")
+	gitCmdRun := NewGitCmd(testDir)
+	pkgAFilePath := filepath.Join("pkga", "a.go")
+	pkgATestFilePath := filepath.Join("pkga", "a_test.go")
+	pkgAExtTestFilePath := filepath.Join("pkga", "a_ext_test.go")
+	files := map[string][]byte{
+		"go.mod":     gomod,
+		pkgAFilePath: pkgAFile, pkgATestFilePath: pkgATestFile, pkgAExtTestFilePath: pkgAExtTestFile,
+	}
+	setupTestGitDir(t, testDir, files, []string{"go.mod",
+		pkgAFilePath, pkgATestFilePath, pkgAExtTestFilePath})
+	defer func() {
+		if !t.Failed() {
+			_ = os.RemoveAll(testDir)
+		}
+	}()
+	logger := log.New(ioutil.Discard, "", 0)
+	cases := []struct {
+		desc     string
+		fname    string
+		old, new string
+		outTests []string
+	}{
+		{
+			desc:  "Update helper of external test package",
+			fname: pkgAExtTestFilePath, old: "return pkga.Triple(a)", new: "return pkga.Triple(a) + 0",
+			outTests: []string{"ssa-xtest-test-run/pkga.TestTriple"},
+		},
+		{
+			desc:  "Update const of external test package",
+			fname: pkgAExtTestFilePath, old: "const factor = 3", new: "const factor = 1 + 2",
+			outTests: []string{"ssa-xtest-test-run/pkga.TestTriple"},
+		},
+		{
+			desc:  "Update func called from external test package",
+			fname: pkgAFilePath, old: "return a * 3", new: "return a * 3 * 1",
+			outTests: []string{"ssa-xtest-test-run/pkga.TestTriple"},
+		},
+		{
+			desc:  "Remove test of external test package",
+			fname: pkgAExtTestFilePath, old: "TestTriple", new: "testTriple",
+		},
+	}
+	ss := NewSSAStrategy("pointer", testDir, NewGitCMD(testDir), logger)
+	for i, tc := range cases {
+		execTestHelper(t, i, tc.desc, func() error {
+			data, err := ioutil.ReadFile(filepath.Join(testDir, tc.fname))
+			if err != nil {
+				return err
+			}
+			data = bytes.Replace(data, []byte(tc.old), []byte(tc.new), 1)
+			return ioutil.WriteFile(filepath.Join(testDir, tc.fname), data, 0600)
+		})
+		_, testsList, _, err := ss.TestsToRun(context.Background())
+		execTestHelper(t, i, tc.desc, func() error {
+			return gitCmdRun("commit", "-am", "commit changes")
+		})
+		if isUnexpectedErr(t, i, tc.desc, nil, err) {
+			continue
+		}
+		sort.Strings(testsList)
+		if !reflect.DeepEqual(tc.outTests, testsList) {
+			t.Errorf("case [%d] %s\nexpected Tests %+v\ngot %+v", i, tc.desc, tc.outTests, testsList)
+		}
+	}
+}
+
+func TestSSAStrategyRemovedWithoutIndex(t *testing.T) {
+	var (
+		gomod = []byte(`module ssa-removed-test-run
+
+go 1.13
+`)
+		shapeFile = []byte(`package shape
+
+type Shape struct{}
+
+func (Shape) String() string {
+	return "shape"
+}
+
+func Double(a int) int {
+	return a * 2
+}
+`)
+		shapeTestFile = []byte(`package shape
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestShape(t *testing.T) {
+	if fmt.Sprint(Shape{}) != "shape" {
+		t.Error("unexpected result")
+	}
+}
+
+func TestDouble(t *testing.T) {
+	if Double(2) != 4 {
+		t.Error("unexpected result")
+	}
+}
+`)
+		mainFile = []byte(`package main
+
+func main() {}
+`)
+		mainTestFile = []byte(`package main
+
+import "testing"
+
+func TestMainPkg(t *testing.T) {}
+`)
+	)
+	testDir := filepath.Join(os.TempDir(), "test_ssa_strategy_removed_without_index")
+	shapeFilePath := filepath.Join("shape", "shape.go")
+	shapeTestFilePath := filepath.Join("shape", "shape_test.go")
+	files := map[string][]byte{
+		"go.mod":  gomod,
+		"main.go": mainFile, "main_test.go": mainTestFile,
+		shapeFilePath: shapeFile, shapeTestFilePath: shapeTestFile,
+	}
+	setupTestGitDir(t, testDir, files, []string{"go.mod", "main.go", "main_test.go",
+		shapeFilePath, shapeTestFilePath})
+	defer func() {
+		if !t.Failed() {
+			_ = os.RemoveAll(testDir)
+		}
+	}()
+	// method changes output of fmt without change of callers
+	data := bytes.Replace(shapeFile, []byte("func (Shape) String() string {\n\treturn \"shape\"\n}\n"), nil, 1)
+	err := ioutil.WriteFile(filepath.Join(testDir, shapeFilePath), data, 0600)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	var logs bytes.Buffer
+	ss := NewSSAStrategy("pointer", testDir, NewGitCMD(testDir), log.New(&logs, "", 0))
+	ss.SetExplain(true)
+	_, tests, _, err := ss.TestsToRun(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	sort.Strings(tests)
+	expected := []string{"ssa-removed-test-run/shape.TestDouble", "ssa-removed-test-run/shape.TestShape"}
+	if !reflect.DeepEqual(expected, tests) {
+		t.Errorf("expected tests of package without index %v, got %v", expected, tests)
+	}
+	var explanations []string
+	for _, e := range ss.Explanations() {
+		explanations = append(explanations, e.String())
+	}
+	expectedExplanations := []string{
+		"ssa-removed-test-run/shape.TestDouble reaches removed shape/shape.go:Shape.String: ssa-removed-test-run/shape",
+		"ssa-removed-test-run/shape.TestShape reaches removed shape/shape.go:Shape.String: ssa-removed-test-run/shape",
+	}
+	if !reflect.DeepEqual(expectedExplanations, explanations) {
+		t.Errorf("expected explanations %q, got %q", expectedExplanations, explanations)
+	}
+	if !strings.Contains(logs.String(), "no index of removed code in 1 packages") {
+		t.Errorf("expected log of removed code without index, got\n%s", logs.String())
+	}
+}
+
+func TestSSAStrategyGenerics(t *testing.T) {
+	var (
+		gomod = []byte(`module ssa-generics-test-run
+
+go 1.18
+`)
+		listFile = []byte(`package main
+
+type List[T any] struct {
+	items []T
+}
+
+func (l *List[T]) Push(v T) {
+	l.items = append(l.items, v)
+}
+
+func (l *List[T]) Len() int {
+	return len(l.items)
+}
+
+type Pair[K comparable, V any] struct {
+	key K
+	val V
+}
+
+func (p Pair[K, V]) Key() K {
+	return p.key
+}
+
+func Map[T, U any](in []T, f func(T) U) []U {
+	out := make([]U, 0, len(in))
+	for _, v := range in {
+		out = append(out, f(v))
+	}
+	return out
+}
+
+func main() {}
+`)
+		listTestFile = []byte(`package main
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestPush(t *testing.T) {
+	l := &List[int]{}
+	l.Push(1)
+	if l.Len() != 1 {
+		t.Error("unexpected len")
+	}
+}
+
+func TestKey(t *testing.T) {
+	p := Pair[string, int]{key: "a", val: 1}
+	if p.Key() != "a" {
+		t.Error("unexpected key")
+	}
+}
+
+func TestMapInts(t *testing.T) {
+	out := Map([]int{1}, func(v int) int { return v * 2 })
+	if out[0] != 2 {
+		t.Error("unexpected result")
+	}
+}
+
+func TestMapStrings(t *testing.T) {
+	out := Map([]int{1}, strconv.Itoa)
+	if out[0] != "1" {
+		t.Error("unexpected result")
+	}
+}
+`)
+	)
+	testDir := filepath.Join(os.TempDir(), "test_ssa_strategy_generics")
+	gitCmdRun := NewGitCmd(testDir)
+	files := map[string][]byte{
+		"go.mod": gomod, "list.go": listFile, "list_test.go": listTestFile,
+	}
+	setupTestGitDir(t, testDir, files, []string{"go.mod", "list.go", "list_test.go"})
+	defer func() {
+		if !t.Failed() {
+			_ = os.RemoveAll(testDir)
+		}
+	}()
+	logger := log.New(ioutil.Discard, "", 0)
+	cases := []struct {
+		desc         string
+		old, new     string
+		outTests     []string
+		explanations []string
+	}{
+		{
+			desc: "Update method of generic type",
+			old:  "l.items = append(l.items, v)", new: "l.items = append(l.items[:len(l.items):len(l.items)], v)",
+			outTests: []string{"ssa-generics-test-run.TestPush"},
+			explanations: []string{
+				"ssa-generics-test-run.TestPush reaches list.go:List.Push: ssa-generics-test-run.TestPush -> (*ssa-generics-test-run.List[int]).Push",
+			},
+		},
+		{
+			desc: "Update method of generic type with type params list",
+			old:  "return p.key", new: "return Pair[K, V]{key: p.key}.key",
+			outTests: []string{"ssa-generics-test-run.TestKey"},
+		},
+		{
+			desc: "Update generic func used with different type args",
+			old:  "out := make([]U, 0, len(in))", new: "out := make([]U, 0)",
+			outTests: []string{"ssa-generics-test-run.TestMapInts",
+				"ssa-generics-test-run.TestMapStrings"},
+		},
+	}
+	ss := NewSSAStrategy("pointer", testDir, NewGitCMD(testDir), logger)
+	ss.SetExplain(true)
+	for i, tc := range cases {
+		execTestHelper(t, i, tc.desc, func() error {
+			listFile = bytes.Replace(listFile, []byte(tc.old), []byte(tc.new), 1)
+			return ioutil.WriteFile(filepath.Join(testDir, "list.go"), listFile, 0600)
+		})
+		_, testsList, _, err := ss.TestsToRun(context.Background())
+		execTestHelper(t, i, tc.desc, func() error {
+			return gitCmdRun("commit", "-am", "commit changes")
+		})
+		if isUnexpectedErr(t, i, tc.desc, nil, err) {
+			continue
+		}
+		sort.Strings(testsList)
+		if !reflect.DeepEqual(tc.outTests, testsList) {
+			t.Errorf("case [%d] %s\nexpected Tests %+v\ngot %+v", i, tc.desc, tc.outTests, testsList)
+		}
+		var explanations []string
+		for _, e := range ss.Explanations() {
+			explanations = append(explanations, e.String())
+		}
+		if tc.explanations != nil && !reflect.DeepEqual(tc.explanations, explanations) {
+			t.Errorf("case [%d] %s\nexpected Explanations %q\ngot %q", i, tc.desc, tc.explanations, explanations)
+		}
+	}
 }
 
 func setupTestGitDir(t *testing.T, testDir string, files map[string][]byte, filesToCommit []string) {
@@ -546,3 +1262,169 @@ func isUnexpectedErr(t *testing.T, caseID int, desc string, expectedErr, goterr
 	}
 	return false
 }
+
+func TestSSAStrategyFallback(t *testing.T) {
+	testDir := filepath.Join(os.TempDir(), "test_ssa_strategy_fallback")
+	pkgAFilePath := filepath.Join("pkga", "f.go")
+	pkgATestFilePath := filepath.Join("pkga", "f_test.go")
+	pkgBFilePath := filepath.Join("pkgb", "f.go")
+	files := map[string][]byte{
+		"go.mod":    gomod,
+		"file_a.go": fileA, "file_b.go": fileB, "file_test.go": testFile,
+		pkgAFilePath: pkgAFile, pkgBFilePath: pkgBFile,
+		pkgATestFilePath: pkgATestFile,
+	}
+	setupTestGitDir(t,
+		testDir, files,
+		[]string{
+			"go.mod", "file_a.go", "file_b.go", "file_test.go",
+			pkgAFilePath, pkgBFilePath, pkgATestFilePath,
+		},
+	)
+	defer func() {
+		if !t.Failed() {
+			_ = os.RemoveAll(testDir)
+		}
+	}()
+	var logs bytes.Buffer
+	logger := log.New(&logs, "", 0)
+	gitCmd := NewGitCMD(testDir)
+	ss := NewSSAStrategy("pointer", testDir, gitCmd, logger)
+	ss.SetFallback(NewImportGraphStrategy(testDir, gitCmd, logger))
+
+	err := ioutil.WriteFile(filepath.Join(testDir, "file_b.go"), fileBUpdateMax, 0600)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expected := []string{"git-diff-strategy-test-run.TestAdd",
+		"git-diff-strategy-test-run.TestMinMaxAdd", "git-diff-strategy-test-run.TestSub"}
+	for _, budget := range []struct {
+		desc     string
+		time     time.Duration
+		memLimit uint64
+	}{
+		{desc: "time", time: time.Nanosecond},
+		{desc: "memory", memLimit: 1},
+	} {
+		ss.SetBudget(budget.time, budget.memLimit)
+		_, tests, _, err := ss.TestsToRun(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		sort.Strings(tests)
+		if !reflect.DeepEqual(expected, tests) {
+			t.Errorf("over %s budget expected fallback tests %v, got %v", budget.desc, expected, tests)
+		}
+	}
+	for _, msg := range []string{"pointer analysis exceeded 1ns budget",
+		"rta analysis exceeded 1ns budget", "cha analysis exceeded 1ns budget",
+		"pointer analysis exceeded 0MB memory budget", ErrBudgetExceeded.Error() + ", using fallback"} {
+		if !strings.Contains(logs.String(), msg) {
+			t.Errorf("expected log %q, got\n%s", msg, logs.String())
+		}
+	}
+
+	// pointer analysis never ends
+	os.Setenv(testBlockedAnalysisEnv, "pointer")
+	defer os.Unsetenv(testBlockedAnalysisEnv)
+	ss.SetBudget(time.Second, 0)
+	logs.Reset()
+	_, tests, subTests, err := ss.TestsToRun(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	sort.Strings(subTests)
+	// rta is less precise on closures and selects min subtest
+	if !reflect.DeepEqual([]string{"git-diff-strategy-test-run.TestMinMaxAdd"}, tests) ||
+		!reflect.DeepEqual([]string{"group test 1", "max", "min"}, subTests) {
+		t.Errorf("expected tests from rta analysis, got %v %v", tests, subTests)
+	}
+	for _, msg := range []string{"pointer analysis exceeded 1s budget", "rta analysis used"} {
+		if !strings.Contains(logs.String(), msg) {
+			t.Errorf("expected log %q, got\n%s", msg, logs.String())
+		}
+	}
+
+	// index of rta analysis is replaced
+	os.Unsetenv(testBlockedAnalysisEnv)
+	ss.SetBudget(0, 0)
+	logs.Reset()
+	_, tests, subTests, err = ss.TestsToRun(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	sort.Strings(subTests)
+	if !reflect.DeepEqual([]string{"git-diff-strategy-test-run.TestMinMaxAdd"}, tests) ||
+		!reflect.DeepEqual([]string{"group test 1", "max"}, subTests) {
+		t.Errorf("expected tests from pointer analysis, got %v %v", tests, subTests)
+	}
+	if !strings.Contains(logs.String(), "redoing pointer analysis") {
+		t.Errorf("expected pointer analysis redone, got\n%s", logs.String())
+	}
+
+	// type error in pkgb
+	err = ioutil.WriteFile(filepath.Join(testDir, pkgBFilePath),
+		[]byte("package pkgb\n\nfunc F() string {\n\treturn 1\n}\n"), 0600)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	ss.SetBudget(0, 0)
+	_, tests, _, err = ss.TestsToRun(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	for _, name := range []string{"git-diff-strategy-test-run/pkga.TestPkgAFunc",
+		"git-diff-strategy-test-run.TestMinMaxAdd"} {
+		found := false
+		for _, test := range tests {
+			found = found || test == name
+		}
+		if !found {
+			t.Errorf("expected %s in fallback tests on build failure, got %v", name, tests)
+		}
+	}
+}
+
+func TestSSAStrategyAnalyzeErrors(t *testing.T) {
+	testDir := filepath.Join(os.TempDir(), "test_ssa_strategy_analyze_errors")
+	noModDir := filepath.Join(os.TempDir(), "test_ssa_strategy_analyze_no_mod")
+	setupTestGitDir(t, testDir, map[string][]byte{
+		"go.mod":  []byte("module analyze-errors\n\ngo 1.13\n"),
+		"main.go": []byte("package main\n\nfunc main() {\n\tvar a int = \"a\"\n}\n"),
+	}, []string{"go.mod", "main.go"})
+	defer func() {
+		if !t.Failed() {
+			_ = os.RemoveAll(testDir)
+			_ = os.RemoveAll(noModDir)
+		}
+	}()
+	if err := os.MkdirAll(noModDir, 0700); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	cases := []struct {
+		desc        string
+		ctx         context.Context
+		dir         string
+		buildFailed bool
+		err         error
+	}{
+		{desc: "type error", ctx: context.Background(), dir: testDir, buildFailed: true},
+		{desc: "canceled", ctx: canceled, dir: testDir, err: context.Canceled},
+		{desc: "not module dir", ctx: context.Background(), dir: noModDir},
+	}
+	for i, tc := range cases {
+		_, err := analyzeTests(tc.ctx, tc.dir, "cha", []string{"./..."}, nil)
+		if err == nil {
+			t.Errorf("case [%d] %s\nexpected error", i, tc.desc)
+			continue
+		}
+		if (err == ErrBuildFailed) != tc.buildFailed {
+			t.Errorf("case [%d] %s\nexpected build failed %v, got %v", i, tc.desc, tc.buildFailed, err)
+		}
+		if tc.err != nil && err != tc.err {
+			t.Errorf("case [%d] %s\nexpected error %v, got %v", i, tc.desc, tc.err, err)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Task interface for Watcher to execute
+// result of the task is passed to the next task
+// in context by prevTaskOutputKey, results of all
+// dependencies by depTaskResultsKey
+type Task interface {
+	ID() string
+	Run(ctx context.Context) (*TaskResult, error)
+}
+
+// TaskStatus status of executed task
+type TaskStatus int
+
+const (
+	// TaskSkipped task had nothing to do
+	TaskSkipped TaskStatus = iota
+	// TaskSuccess task succeeded, like tests passed
+	TaskSuccess
+	// TaskFailure task finished with negative result, like tests failed
+	TaskFailure
+	// TaskError task could not be finished
+	TaskError
+)
+
+func (s TaskStatus) String() string {
+	switch s {
+	case TaskSkipped:
+		return "skipped"
+	case TaskSuccess:
+		return "success"
+	case TaskFailure:
+		return "failure"
+	case TaskError:
+		return "error"
+	}
+	return "unknown"
+}
+
+// keys of TaskResult.Meta set by gtr tasks
+const (
+	// *TestsReport of GoTestRunner
+	metaTestsReport = "tests_report"
+	// []string of tests selected by strategy
+	metaTests = "tests"
+	// []string of subtests selected by strategy
+	metaSubTests = "subtests"
+)
+
+// TaskResult result of a task passed between tasks
+type TaskResult struct {
+	Status  TaskStatus
+	Summary string
+	// Failed tests names
+	Failed   []string
+	Duration time.Duration
+	// Meta arbitrary task data
+	Meta map[string]interface{}
+}
+
+// NewTaskResult returns result with status and summary
+func NewTaskResult(status TaskStatus, summary string) *TaskResult {
+	return &TaskResult{
+		Status:  status,
+		Summary: summary,
+		Meta:    map[string]interface{}{},
+	}
+}
+
+// Success returns true if task succeeded
+func (r *TaskResult) Success() bool {
+	return r != nil && r.Status == TaskSuccess
+}
+
+// TestsReport returns tests report stored in Meta or nil
+func (r *TaskResult) TestsReport() *TestsReport {
+	if r == nil {
+		return nil
+	}
+	report, _ := r.Meta[metaTestsReport].(*TestsReport)
+	return report
+}
+
+func (r *TaskResult) String() string {
+	if r == nil {
+		return ""
+	}
+	return r.Summary
+}
+
+// prevTaskResult returns result of previous task from context
+// or nil if it is the first task
+func prevTaskResult(ctx context.Context) *TaskResult {
+	res, _ := ctx.Value(prevTaskOutputKey).(*TaskResult)
+	return res
+}
+
+// depTaskResults returns results of task dependencies
+// by task ID from context
+func depTaskResults(ctx context.Context) map[string]*TaskResult {
+	res, _ := ctx.Value(depTaskResultsKey).(map[string]*TaskResult)
+	return res
+}
+
+// NewTask adaptor for func to run as the Task
+func NewTask(id string,
+	fn func(*log.Logger, context.Context) (*TaskResult, error),
+	logger *log.Logger,
+) Task {
+	return taskAdapter{id, fn, logger}
+}
+
+var _ Task = (*taskAdapter)(nil)
+
+// taskAdapter struct implements Task interface
+// works as container for func
+type taskAdapter struct {
+	id  string
+	fn  func(*log.Logger, context.Context) (*TaskResult, error)
+	log *log.Logger
+}
+
+func (ta taskAdapter) ID() string {
+	return ta.id
+}
+
+func (ta taskAdapter) Run(ctx context.Context) (*TaskResult, error) {
+	return ta.fn(ta.log, ctx)
+}
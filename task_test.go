@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"testing"
+)
+
+func TestTaskResultPassedBetweenTasks(t *testing.T) {
+	logger := log.New(os.Stdout, "gtr-test:", log.Ltime)
+	report := &TestsReport{Run: "TestA$", Pass: true}
+	task := NewTask("task", func(log *log.Logger, ctx context.Context) (*TaskResult, error) {
+		prev := prevTaskResult(ctx)
+		if !prev.Success() {
+			return NewTaskResult(TaskSkipped, "previous task failed"), nil
+		}
+		res := NewTaskResult(TaskSuccess, "after "+prev.Summary)
+		res.Meta[metaTestsReport] = prev.TestsReport()
+		return res, nil
+	}, logger)
+
+	cases := []struct {
+		desc    string
+		prev    *TaskResult
+		status  TaskStatus
+		summary string
+	}{
+		{desc: "first task", prev: nil, status: TaskSkipped, summary: "previous task failed"},
+		{desc: "previous task failed", prev: NewTaskResult(TaskFailure, "Tests FAIL"),
+			status: TaskSkipped, summary: "previous task failed"},
+		{desc: "previous task succeeded", prev: &TaskResult{
+			Status: TaskSuccess, Summary: "Tests PASS",
+			Meta: map[string]interface{}{metaTestsReport: report}},
+			status: TaskSuccess, summary: "after Tests PASS"},
+	}
+	for i, tc := range cases {
+		ctx := context.Background()
+		if tc.prev != nil {
+			ctx = context.WithValue(ctx, prevTaskOutputKey, tc.prev)
+		}
+		res, err := task.Run(ctx)
+		if isUnexpectedErr(t, i, tc.desc, nil, err) {
+			continue
+		}
+		if res.Status != tc.status || res.Summary != tc.summary {
+			t.Errorf("case [%d] %s\nexpected %s %q, got %s %q",
+				i, tc.desc, tc.status, tc.summary, res.Status, res.Summary)
+		}
+		if res.Success() && res.TestsReport() != report {
+			t.Errorf("case [%d] %s\nexpected tests report in meta", i, tc.desc)
+		}
+	}
+}
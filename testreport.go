@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TestEvent is an event of go test -json output
+// see go doc cmd/test2json
+type TestEvent struct {
+	Time        time.Time
+	Action      string
+	Package     string
+	ImportPath  string // of build events
+	Test        string
+	Elapsed     float64 // seconds
+	Output      string
+	FailedBuild string
+}
+
+// TestStatus status of a test or a package
+type TestStatus string
+
+const (
+	// TestRun test started but not finished
+	TestRun TestStatus = "run"
+	// TestPass test passed
+	TestPass TestStatus = "pass"
+	// TestFail test failed
+	TestFail TestStatus = "fail"
+	// TestSkip test skipped
+	TestSkip TestStatus = "skip"
+)
+
+// TestResult result of a test or a subtest
+// subtest names separated by "/"
+type TestResult struct {
+	Package string
+	Name    string
+	Status  TestStatus
+	Elapsed time.Duration
+	Output  []string
+}
+
+// PackageResult result of a package test binary
+type PackageResult struct {
+	Name        string
+	Status      TestStatus
+	Elapsed     time.Duration
+	BuildFailed bool
+	Output      []string
+}
+
+// TestsReport collects results of go test -json runs
+type TestsReport struct {
+	// Run is -run arg of executed tests
+	Run      string
+	Pass     bool
+	Tests    []*TestResult
+	Packages []*PackageResult
+	// BuildOutput none json lines and build-output
+	// events of go test like build errors
+	BuildOutput []string
+
+	// guards results, written by stdout
+	// and stderr writers concurrently
+	mu    sync.Mutex
+	tests map[string]*TestResult
+	pkgs  map[string]*PackageResult
+}
+
+// NewTestsReport returns empty report
+func NewTestsReport() *TestsReport {
+	return &TestsReport{
+		tests: map[string]*TestResult{},
+		pkgs:  map[string]*PackageResult{},
+	}
+}
+
+// AddEvent updates report with test2json event
+func (r *TestsReport) AddEvent(e TestEvent) {
+	name := e.Package
+	if name == "" {
+		name = buildEventPkg(e.ImportPath)
+	}
+	if name == "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	pkg := r.pkgs[name]
+	if pkg == nil {
+		pkg = &PackageResult{Name: name, Status: TestRun}
+		r.pkgs[name] = pkg
+		r.Packages = append(r.Packages, pkg)
+	}
+	if e.FailedBuild != "" {
+		pkg.BuildFailed = true
+	}
+	switch e.Action {
+	case "build-output":
+		r.BuildOutput = append(r.BuildOutput, e.Output)
+		return
+	case "build-fail":
+		pkg.BuildFailed = true
+		pkg.Status = TestFail
+		return
+	}
+	if e.Test == "" {
+		switch e.Action {
+		case "output":
+			pkg.Output = append(pkg.Output, e.Output)
+			if strings.Contains(e.Output, "[build failed]") ||
+				strings.Contains(e.Output, "[setup failed]") {
+				pkg.BuildFailed = true
+			}
+		case "pass", "fail", "skip":
+			pkg.Status = TestStatus(e.Action)
+			pkg.Elapsed = toDuration(e.Elapsed)
+		}
+		return
+	}
+	key := e.Package + " " + e.Test
+	test := r.tests[key]
+	if test == nil {
+		test = &TestResult{Package: e.Package, Name: e.Test, Status: TestRun}
+		r.tests[key] = test
+		r.Tests = append(r.Tests, test)
+	}
+	switch e.Action {
+	case "output":
+		test.Output = append(test.Output, e.Output)
+	case "pass", "fail", "skip":
+		test.Status = TestStatus(e.Action)
+		test.Elapsed = toDuration(e.Elapsed)
+	}
+}
+
+// Failed returns failed tests and subtests
+func (r *TestsReport) Failed() []*TestResult {
+	return r.testsWithStatus(TestFail)
+}
+
+// Skipped returns skipped tests and subtests
+func (r *TestsReport) Skipped() []*TestResult {
+	return r.testsWithStatus(TestSkip)
+}
+
+// Passed returns passed tests and subtests
+func (r *TestsReport) Passed() []*TestResult {
+	return r.testsWithStatus(TestPass)
+}
+
+// BuildFailed returns packages failed to build
+func (r *TestsReport) BuildFailed() []*PackageResult {
+	var out []*PackageResult
+	for _, pkg := range r.Packages {
+		if pkg.BuildFailed {
+			out = append(out, pkg)
+		}
+	}
+	return out
+}
+
+// FailedNames returns sorted names of failed tests
+// prefixed with package name if tests from different packages
+func (r *TestsReport) FailedNames() []string {
+	var out []string
+	for _, t := range r.Failed() {
+		if len(r.Packages) > 1 {
+			out = append(out, t.Package+"."+t.Name)
+		} else {
+			out = append(out, t.Name)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (r *TestsReport) String() string {
+	if r.Pass {
+		return "Tests PASS: " + r.Run
+	}
+	if pkgs := r.BuildFailed(); len(pkgs) > 0 {
+		names := make([]string, len(pkgs))
+		for i := range pkgs {
+			names[i] = pkgs[i].Name
+		}
+		return "Build Failed: " + strings.Join(names, " ")
+	}
+	if failed := r.FailedNames(); len(failed) > 0 {
+		return "Tests FAIL: " + strings.Join(failed, " ")
+	}
+	return "Tests FAIL: " + r.Run
+}
+
+func (r *TestsReport) testsWithStatus(status TestStatus) []*TestResult {
+	var out []*TestResult
+	for _, t := range r.Tests {
+		if t.Status == status {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// Writer returns writer which parses go test -json output
+// into report and writes tests output to out
+func (r *TestsReport) Writer(out io.Writer) io.WriteCloser {
+	return &testEventWriter{report: r, out: out}
+}
+
+// testEventWriter splits written data by lines and
+// decodes test2json events, none json lines
+// are stored as build output
+type testEventWriter struct {
+	report *TestsReport
+	out    io.Writer
+	buf    []byte
+}
+
+func (w *testEventWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		id := bytes.IndexByte(w.buf, '\n')
+		if id == -1 {
+			break
+		}
+		w.processLine(w.buf[:id+1])
+		w.buf = w.buf[id+1:]
+	}
+	return len(p), nil
+}
+
+// Close processes last not terminated line
+func (w *testEventWriter) Close() error {
+	if len(w.buf) > 0 {
+		w.processLine(w.buf)
+		w.buf = nil
+	}
+	return nil
+}
+
+func (w *testEventWriter) processLine(line []byte) {
+	var e TestEvent
+	if len(line) > 0 && line[0] == '{' && json.Unmarshal(line, &e) == nil {
+		w.report.AddEvent(e)
+		if (e.Action == "output" || e.Action == "build-output") && w.out != nil {
+			_, _ = io.WriteString(w.out, e.Output)
+		}
+		return
+	}
+	w.report.addBuildOutput(string(line))
+	if w.out != nil {
+		_, _ = w.out.Write(line)
+	}
+}
+
+// addBuildOutput stores none json line of go test
+func (r *TestsReport) addBuildOutput(line string) {
+	r.mu.Lock()
+	r.BuildOutput = append(r.BuildOutput, line)
+	r.mu.Unlock()
+}
+
+// buildEventPkg returns package of import path of build
+// event, test variant "p [p.test]" is test of package p
+func buildEventPkg(importPath string) string {
+	idx := strings.Index(importPath, " [")
+	if idx == -1 {
+		return importPath
+	}
+	variant := strings.TrimSuffix(importPath[idx+2:], "]")
+	if strings.HasSuffix(variant, ".test") {
+		return strings.TrimSuffix(variant, ".test")
+	}
+	return importPath[:idx]
+}
+
+func toDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTestsReportWriter(t *testing.T) {
+	events := `{"Action":"run","Package":"mod/pkga","Test":"TestA"}
+{"Action":"output","Package":"mod/pkga","Test":"TestA","Output":"=== RUN   TestA\n"}
+{"Action":"run","Package":"mod/pkga","Test":"TestA/sub_1"}
+{"Action":"output","Package":"mod/pkga","Test":"TestA/sub_1","Output":"    a_test.go:10: unexpected result\n"}
+{"Action":"fail","Package":"mod/pkga","Test":"TestA/sub_1","Elapsed":0.5}
+{"Action":"fail","Package":"mod/pkga","Test":"TestA","Elapsed":1}
+{"Action":"run","Package":"mod/pkga","Test":"TestB"}
+{"Action":"skip","Package":"mod/pkga","Test":"TestB","Elapsed":0}
+{"Action":"fail","Package":"mod/pkga","Elapsed":1.2}
+# mod/pkgb
+pkgb/b.go:3:1: syntax error
+{"Action":"output","Package":"mod/pkgb","Output":"FAIL\tmod/pkgb [build failed]\n"}
+{"Action":"fail","Package":"mod/pkgb","Elapsed":0}
+{"ImportPath":"mod/pkgd_test [mod/pkgd.test]","Action":"build-output","Output":"# mod/pkgd_test [mod/pkgd.test]\n"}
+{"ImportPath":"mod/pkgd_test [mod/pkgd.test]","Action":"build-output","Output":"./d_test.go:5:28: undefined: x\n"}
+{"ImportPath":"mod/pkgd_test [mod/pkgd.test]","Action":"build-fail"}
+{"Action":"start","Package":"mod/pkgd"}
+{"Action":"output","Package":"mod/pkgd","Output":"FAIL\tmod/pkgd [build failed]\n","OutputType":"frame"}
+{"Action":"fail","Package":"mod/pkgd","Elapsed":0,"FailedBuild":"mod/pkgd_test [mod/pkgd.test]"}
+{"Action":"run","Package":"mod/pkgc","Test":"TestC"}
+{"Action":"pass","Package":"mod/pkgc","Test":"TestC","Elapsed":0.01}`
+
+	var out bytes.Buffer
+	report := NewTestsReport()
+	wr := report.Writer(&out)
+	// write in chunks to split lines
+	data := []byte(events)
+	for len(data) > 0 {
+		n := 7
+		if n > len(data) {
+			n = len(data)
+		}
+		_, _ = wr.Write(data[:n])
+		data = data[n:]
+	}
+	wr.Close()
+
+	if len(report.Tests) != 4 {
+		t.Fatalf("expected 4 tests, got %d", len(report.Tests))
+	}
+	failed := report.FailedNames()
+	expected := []string{"mod/pkga.TestA", "mod/pkga.TestA/sub_1"}
+	if !reflect.DeepEqual(expected, failed) {
+		t.Errorf("expected failed %v, got %v", expected, failed)
+	}
+	if len(report.Skipped()) != 1 || report.Skipped()[0].Name != "TestB" {
+		t.Errorf("expected skipped TestB, got %+v", report.Skipped())
+	}
+	if len(report.Passed()) != 1 || report.Passed()[0].Elapsed != 10*time.Millisecond {
+		t.Errorf("expected passed TestC 10ms, got %+v", report.Passed())
+	}
+	sub := report.Failed()[1]
+	if len(sub.Output) != 1 || sub.Output[0] != "    a_test.go:10: unexpected result\n" {
+		t.Errorf("unexpected subtest output %q", sub.Output)
+	}
+	buildFailed := report.BuildFailed()
+	if len(buildFailed) != 2 || buildFailed[0].Name != "mod/pkgb" || buildFailed[1].Name != "mod/pkgd" {
+		t.Errorf("expected mod/pkgb and mod/pkgd build failure, got %+v", buildFailed)
+	}
+	if len(report.BuildOutput) != 4 || report.BuildOutput[3] != "./d_test.go:5:28: undefined: x\n" {
+		t.Errorf("expected 4 build output lines, got %q", report.BuildOutput)
+	}
+	if report.String() != "Build Failed: mod/pkgb mod/pkgd" {
+		t.Errorf("unexpected report message %q", report.String())
+	}
+	if !bytes.Contains(out.Bytes(), []byte("=== RUN   TestA\n")) ||
+		!bytes.Contains(out.Bytes(), []byte("pkgb/b.go:3:1: syntax error\n")) ||
+		!bytes.Contains(out.Bytes(), []byte("./d_test.go:5:28: undefined: x\n")) {
+		t.Errorf("expected tests output, got %q", out.String())
+	}
+}
+
+func TestTestsReportConcurrentWriters(t *testing.T) {
+	report := NewTestsReport()
+	var wg sync.WaitGroup
+	// stdout and stderr of go test
+	for _, stream := range []string{"out", "err"} {
+		wg.Add(1)
+		go func(stream string) {
+			defer wg.Done()
+			wr := report.Writer(nil)
+			for i := 0; i < 1000; i++ {
+				fmt.Fprintf(wr, `{"Action":"pass","Package":"mod/%s","Test":"Test%d"}`+"\n", stream, i)
+				fmt.Fprintf(wr, "%s line %d\n", stream, i)
+			}
+			wr.Close()
+		}(stream)
+	}
+	wg.Wait()
+	if len(report.Passed()) != 2000 || len(report.Packages) != 2 {
+		t.Errorf("expected 2000 passed tests of 2 packages, got %d of %d",
+			len(report.Passed()), len(report.Packages))
+	}
+	if len(report.BuildOutput) != 2000 {
+		t.Errorf("expected 2000 build output lines, got %d", len(report.BuildOutput))
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 var _ Task = (*GoTestRunner)(nil)
@@ -52,37 +53,31 @@ func (tr *GoTestRunner) ID() string {
 }
 
 // Run method implements Task interface
-// runs go tests
-func (tr *GoTestRunner) Run(ctx context.Context) (string, error) {
+// runs go tests, *TestsReport stored in result Meta
+func (tr *GoTestRunner) Run(ctx context.Context) (*TaskResult, error) {
+	start := time.Now()
 	runAll, tests, subTests, err := tr.strategy.TestsToRun(ctx)
 	if err != nil {
 		if err == ErrBuildFailed {
-			return "Build Failed", nil
+			return NewTaskResult(TaskFailure, "Build Failed"), nil
 		}
-		return "", fmt.Errorf("strategy error %v", err)
+		return nil, fmt.Errorf("strategy error %v", err)
 	}
 	if len(tests) == 0 && len(subTests) == 0 {
-		return "No test found to run", nil
+		return NewTaskResult(TaskSkipped, "No test found to run"), nil
 	}
-
-	var listArg []string
-	pkgPaths := map[string][]string{}
-	for _, tname := range tests {
-		id := strings.LastIndexByte(tname, '.')
-		pkgPath := tname[:id]
-		if pkgPath == "" {
-			pkgPath = "."
-		}
-		pkgPaths[pkgPath] = append(pkgPaths[pkgPath], tname[id+1:])
+	if ex, ok := tr.strategy.(Explainer); ok && len(ex.Explanations()) > 0 {
+		logExplanations(tr.log, ex.Explanations())
 	}
 
+	pkgPaths := testsByPkg(tests)
+
 	// run tests
 	// do not wait process to finish
 	// in case of console blocking programs
 	// -vet=off to improve speed
-	// TODO handle run all
-	msg := ""
-	testParams := []string{"test", "-v", "-vet", "off", "-failfast",
+	report := NewTestsReport()
+	testParams := []string{"test", "-json", "-vet", "off", "-failfast",
 		"-cpu", strconv.Itoa(runtime.GOMAXPROCS(0))}
 
 	logStrList(tr.log, "Tests to run", tests, true)
@@ -94,7 +89,9 @@ func (tr *GoTestRunner) Run(ctx context.Context) (string, error) {
 		pkgList = append(pkgList, k)
 		testNames = append(testNames, pkgtests...)
 	}
+	sort.Strings(pkgList)
 	testsFormated := tr.joinTestAndSubtest(testNames, subTests)
+	report.Run = testsFormated
 	var cmd CommandExecutor
 	// TODO refactor
 	if runAll {
@@ -104,7 +101,7 @@ func (tr *GoTestRunner) Run(ctx context.Context) (string, error) {
 		}
 		testParams = append(testParams, "-run")
 		testParams = append(testParams, testsFormated)
-		testParams = append(testParams, listArg...)
+		testParams = append(testParams, pkgList...)
 		if len(tr.args) > 0 {
 			testParams = append(testParams, "-args")
 			testParams = append(testParams, tr.args)
@@ -112,17 +109,14 @@ func (tr *GoTestRunner) Run(ctx context.Context) (string, error) {
 		cmd = tr.cmd(ctx, "go", testParams...)
 		tr.log.Println(">>", strings.Join(cmd.GetArgs(), " "))
 
-		cmd.SetStdout(os.Stdout)
-		cmd.SetStderr(os.Stderr)
-		cmd.SetEnv(os.Environ())
-		cmd.Run()
+		tr.runCmd(cmd, report)
 	} else {
 		// run cmd for each test and skip subtests to have separation between tests
 	OUTER:
 		for pkg, pkgtests := range pkgPaths {
 			for _, tname := range pkgtests {
 				// run all tests
-				testParams := []string{"test", "-v", "-vet", "off",
+				testParams := []string{"test", "-json", "-vet", "off",
 					"-cpu", strconv.Itoa(runtime.GOMAXPROCS(0))}
 
 				if tr.strategy.CoverageEnabled() {
@@ -149,10 +143,7 @@ func (tr *GoTestRunner) Run(ctx context.Context) (string, error) {
 				cmd = tr.cmd(ctx, "go", testParams...)
 				tr.log.Println(">>", strings.Join(cmd.GetArgs(), " "))
 
-				cmd.SetStdout(os.Stdout)
-				cmd.SetStderr(os.Stderr)
-				cmd.SetEnv(os.Environ())
-				cmd.Run()
+				tr.runCmd(cmd, report)
 				if !cmd.Success() {
 					// stop on failed test
 					break OUTER
@@ -161,18 +152,60 @@ func (tr *GoTestRunner) Run(ctx context.Context) (string, error) {
 		}
 	}
 
-	if cmd.Success() {
-		msg = "Tests PASS: " + testsFormated
+	report.Pass = cmd != nil && cmd.Success() &&
+		len(report.Failed()) == 0 && len(report.BuildFailed()) == 0
+	if report.Pass {
 		tr.log.Println("\033[32mTests PASS\033[39m")
 	} else {
-		msg = "Tests FAIL: " + testsFormated
 		tr.log.Println("\033[31mTests FAIL\033[39m")
+		if failed := report.FailedNames(); len(failed) > 0 {
+			logStrList(tr.log, "Failed tests", failed, false)
+		}
+	}
+	res := NewTaskResult(TaskFailure, report.String())
+	if report.Pass {
+		res.Status = TaskSuccess
+	}
+	res.Failed = report.FailedNames()
+	res.Duration = time.Since(start)
+	res.Meta[metaTestsReport] = report
+	res.Meta[metaTests] = tests
+	res.Meta[metaSubTests] = subTests
+	return res, nil
+}
+
+// runCmd runs go test -json command and collects
+// test events to the report
+func (tr *GoTestRunner) runCmd(cmd CommandExecutor, report *TestsReport) {
+	stdout := report.Writer(os.Stdout)
+	stderr := report.Writer(os.Stderr)
+	cmd.SetStdout(stdout)
+	cmd.SetStderr(stderr)
+	cmd.SetEnv(os.Environ())
+	_ = cmd.Run()
+	stdout.Close()
+	stderr.Close()
+}
+
+// testsByPkg groups test names by package path
+func testsByPkg(tests []string) map[string][]string {
+	pkgPaths := map[string][]string{}
+	for _, tname := range tests {
+		id := strings.LastIndexByte(tname, '.')
+		pkgPath := tname[:id]
+		if pkgPath == "" {
+			pkgPath = "."
+		}
+		pkgPaths[pkgPath] = append(pkgPaths[pkgPath], tname[id+1:])
 	}
-	return msg, nil
+	return pkgPaths
 }
 
 // joinTestAndSubtest joins and format tests according to go test -run arg format
 func (tr *GoTestRunner) joinTestAndSubtest(tests, subTests []string) string {
+	// names of strategy are kept for next tasks
+	tests = append([]string{}, tests...)
+	subTests = append([]string{}, subTests...)
 	sort.Strings(tests)
 	sort.Strings(subTests)
 	out := strings.Join(tests, "$|")
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"log"
 	"os"
+	"reflect"
 	"testing"
 )
 
@@ -34,13 +35,16 @@ func TestGoTestRunnerRun(t *testing.T) {
 		coverageEnabled    bool
 		tests              []string
 		subTests           []string
+		cmdOutput          string
 		output             string
+		status             TaskStatus
 		err                error
 	}{
 		{
 			desc:       "No file changes",
 			cmdSuccess: true,
 			output:     "No test found to run",
+			status:     TaskSkipped,
 			err:        nil,
 		},
 		{
@@ -50,6 +54,7 @@ func TestGoTestRunnerRun(t *testing.T) {
 			coverageEnabled: true,
 			tests:           []string{"module/pkga.TestZ", "module.TestC"},
 			output:          "Tests PASS: TestC$|TestZ$",
+			status:          TaskSuccess,
 		},
 		{
 			desc:       "1 top level test and 2 subtests pass",
@@ -57,6 +62,7 @@ func TestGoTestRunnerRun(t *testing.T) {
 			tests:      []string{"module.TestZ"},
 			subTests:   []string{"b 1", "z 2"},
 			output:     "Tests PASS: TestZ$/(b_1|z_2)",
+			status:     TaskSuccess,
 		},
 		{
 			desc:        "Strategy error",
@@ -72,6 +78,21 @@ func TestGoTestRunnerRun(t *testing.T) {
 			tests:      []string{"module.TestZ"},
 			subTests:   []string{"b1"},
 			output:     "Tests FAIL: TestZ$/(b1)",
+			status:     TaskFailure,
+		},
+		{
+			desc:       "Tests failed with json output",
+			cmdSuccess: false,
+			runAll:     true,
+			tests:      []string{"module.TestZ", "module.TestC"},
+			cmdOutput: `{"Action":"run","Package":"module","Test":"TestC"}
+{"Action":"pass","Package":"module","Test":"TestC","Elapsed":0.01}
+{"Action":"run","Package":"module","Test":"TestZ"}
+{"Action":"fail","Package":"module","Test":"TestZ","Elapsed":0.02}
+{"Action":"fail","Package":"module","Elapsed":0.03}
+`,
+			output: "Tests FAIL: TestZ",
+			status: TaskFailure,
 		},
 		{
 			desc:            "Subtests on runAll false",
@@ -81,6 +102,7 @@ func TestGoTestRunnerRun(t *testing.T) {
 			tests:           []string{"module.TestZ"},
 			subTests:        []string{"group"},
 			output:          "Tests PASS: TestZ$/(group)",
+			status:          TaskSuccess,
 		},
 	}
 	logger := log.New(os.Stdout, "TestGoTestRunnerRun:", log.Ltime)
@@ -92,14 +114,24 @@ func TestGoTestRunnerRun(t *testing.T) {
 		ds.runAll = tc.runAll
 		ds.coverageEnabled = tc.coverageEnabled
 		mockCmd := NewMockCommand(nil, tc.cmdSuccess)
+		mockCmd.output = []byte(tc.cmdOutput)
 		runner := NewGoTestRunner(&ds, mockCmd.New, "", logger)
-		out, err := runner.Run(context.TODO())
+		res, err := runner.Run(context.TODO())
 
 		if isUnexpectedErr(t, i, tc.desc, tc.err, err) {
 			continue
 		}
-		if tc.output != out {
-			t.Errorf("case [%d] %s\nexpected \"%s\", got \"%s\"", i, tc.desc, tc.output, out)
+		if res == nil {
+			if tc.err == nil {
+				t.Errorf("case [%d] %s\nunexpected nil result", i, tc.desc)
+			}
+			continue
+		}
+		if tc.output != res.Summary {
+			t.Errorf("case [%d] %s\nexpected \"%s\", got \"%s\"", i, tc.desc, tc.output, res.Summary)
+		}
+		if tc.status != res.Status {
+			t.Errorf("case [%d] %s\nexpected status %s, got %s", i, tc.desc, tc.status, res.Status)
 		}
 
 	}
@@ -119,10 +151,14 @@ func TestGoTestRunnerJoinTestAndSubtest(t *testing.T) {
 		{[]string{"TestZ", "TestC", "TestB"}, []string{"b 1", "z2"}, "TestB$|TestC$|TestZ$/(b_1|z2)"},
 	}
 	for i, tc := range cases {
-		out := runner.joinTestAndSubtest(tc.tests, tc.subTests)
+		subTests := append([]string(nil), tc.subTests...)
+		out := runner.joinTestAndSubtest(tc.tests, subTests)
 		if tc.output != out {
 			t.Errorf("case [%d]expected %s, got %s", i, tc.output, out)
 		}
+		if !reflect.DeepEqual(tc.subTests, subTests) {
+			t.Errorf("case [%d]expected subtests not modified %v, got %v", i, tc.subTests, subTests)
+		}
 
 	}
 
@@ -18,24 +18,20 @@ type TaskCtxKey string
 const (
 	changedFileNameKey TaskCtxKey = "changed_file_name_key"
 	prevTaskOutputKey  TaskCtxKey = "prev_task_output_key"
+	depTaskResultsKey  TaskCtxKey = "dep_task_results_key"
 )
 
-// Task interface for Watcher to execute
-type Task interface {
-	ID() string
-	Run(ctx context.Context) (msg string, err error)
-}
-
 // Watcher watches recursively directories and
-// executes provided Tasks
+// executes provided pipeline of Tasks
 type Watcher struct {
 	wt                  *fsnotify.Watcher
 	workDir             string
 	dirs                map[string]bool
-	tasks               []Task
+	pipeline            *Pipeline
 	delay               time.Duration
 	excludeFilePrefixes []string
 	excludeDirs         []string
+	gitLockWait         bool
 	quit                chan bool
 	log                 *log.Logger
 }
@@ -43,7 +39,7 @@ type Watcher struct {
 // NewWatcher returns constructed Watcher
 func NewWatcher(
 	workDir string,
-	tasks []Task,
+	pipeline *Pipeline,
 	delay int,
 	excludeFilePrefixes []string,
 	excludeDirs []string,
@@ -54,15 +50,22 @@ func NewWatcher(
 		wt:                  watcher,
 		workDir:             workDir,
 		dirs:                make(map[string]bool),
-		tasks:               tasks,
+		pipeline:            pipeline,
 		delay:               time.Duration(delay) * time.Millisecond,
 		excludeFilePrefixes: excludeFilePrefixes,
 		excludeDirs:         excludeDirs,
+		gitLockWait:         true,
 		quit:                make(chan bool),
 		log:                 logger,
 	}, err
 }
 
+// SetGitLockWait sets if tasks wait for git index lock of
+// other processes, not needed if git is read in process
+func (w *Watcher) SetGitLockWait(wait bool) {
+	w.gitLockWait = wait
+}
+
 // Run watcher, blocks
 func (w *Watcher) Run() error {
 	w.log.Println("watcher running...")
@@ -91,7 +94,7 @@ func (w *Watcher) skipChange(
 			return true
 		}
 	}
-	if !strings.HasSuffix(e.Name, ".go") {
+	if !strings.HasSuffix(e.Name, ".go") && !isInputFile(w.workDir, e.Name) {
 		return true
 	}
 	name := path.Base(e.Name)
@@ -162,7 +165,9 @@ LOOP:
 			// add some delay, there is a race
 			// for git index lock in current dir, if some other process
 			// use git
-			time.Sleep(w.delay / 10)
+			if w.gitLockWait {
+				time.Sleep(w.delay / 10)
+			}
 			w.log.Println("File changed:", e.Name)
 			lastModFile = e.Name
 			lastModTime = time.Now()
@@ -173,22 +178,12 @@ LOOP:
 			ctx, cancel = context.WithCancel(
 				context.WithValue(context.Background(), changedFileNameKey, e.Name))
 			// do not block loop
-			go func() {
-				var output string
-				var err error
-				// run tasks in provided sequence
-				for _, task := range w.tasks {
-					ctx = context.WithValue(ctx, prevTaskOutputKey, output)
-					w.log.Printf("Run task.ID %+v\n", task.ID()) // output for debug
-					output, err = task.Run(ctx)
-					if err != nil {
-						w.log.Printf("stop pipeline Task.ID: %s returned\n", task.ID()) // output for debug
-						break
-					}
+			go func(ctx context.Context) {
+				if w.pipeline != nil {
+					w.pipeline.Run(ctx, w.log)
 				}
-				// add loging
 				w.log.Println("tasks executed")
-			}()
+			}(ctx)
 
 		case err := <-w.wt.Errors:
 			if err != nil {
@@ -238,29 +233,3 @@ func (w *Watcher) Stop() error {
 	defer close(w.quit)
 	return w.wt.Close()
 }
-
-// NewTask adaptor for func to run as the Task
-func NewTask(id string,
-	fn func(*log.Logger, context.Context) (string, error),
-	logger *log.Logger,
-) Task {
-	return taskAdapter{id, fn, logger}
-}
-
-var _ Task = (*taskAdapter)(nil)
-
-// taskAdapter struct implements Task interface
-// works as container for func
-type taskAdapter struct {
-	id  string
-	fn  func(*log.Logger, context.Context) (string, error)
-	log *log.Logger
-}
-
-func (ta taskAdapter) ID() string {
-	return ta.id
-}
-
-func (ta taskAdapter) Run(ctx context.Context) (string, error) {
-	return ta.fn(ta.log, ctx)
-}
@@ -125,6 +125,16 @@ func TestWatcherSkipChange(t *testing.T) {
 			event:  fsnotify.Event{"file.js", fsnotify.Write},
 			expect: true,
 		},
+		{
+			desc:   "go.mod file Write event",
+			event:  fsnotify.Event{Name: "go.mod", Op: fsnotify.Write},
+			expect: false,
+		},
+		{
+			desc:   "testdata file Write event",
+			event:  fsnotify.Event{Name: filepath.Join("pkga", "testdata", "in.json"), Op: fsnotify.Write},
+			expect: false,
+		},
 		{
 			desc:                "prefixfile.go skipped by prefix Write event",
 			excludeFilePrefixes: []string{"prefix", "otherprefix"},
@@ -137,6 +147,7 @@ func TestWatcherSkipChange(t *testing.T) {
 
 	for i, tc := range cases {
 		watcher := &Watcher{
+			workDir:             ".",
 			delay:               1000 * time.Millisecond,
 			excludeFilePrefixes: tc.excludeFilePrefixes,
 		}
@@ -182,13 +193,13 @@ func TestWatcherRunTasks(t *testing.T) {
 				if err != nil {
 					return err
 				}
-				task1 := NewTask("task1", func(log *log.Logger, ctx context.Context) (string, error) {
+				task1 := NewTask("task1", func(log *log.Logger, ctx context.Context) (*TaskResult, error) {
 					<-ctx.Done()
 					taskErr = taskCanceledErr
-					return taskErr.Error(), taskErr
+					return nil, taskErr
 
 				}, logger)
-				watcher, _ = NewWatcher(testDir, []Task{task1}, 0, nil, nil, logger)
+				watcher, _ = NewWatcher(testDir, SequentialPipeline(task1), 0, nil, nil, logger)
 				_ = watcher.addDirs()
 				// run tasks
 				go watcher.runTasks()
@@ -204,25 +215,25 @@ func TestWatcherRunTasks(t *testing.T) {
 		{
 			desc: "Run multiple tasks in order file > task1 > task2",
 			setup: func() error {
-				task1 := NewTask("task1", func(log *log.Logger, ctx context.Context) (string, error) {
+				task1 := NewTask("task1", func(log *log.Logger, ctx context.Context) (*TaskResult, error) {
 					fname, ok := ctx.Value(changedFileNameKey).(string)
 					if !ok {
-						return "", taskCanceledErr
+						return nil, taskCanceledErr
 					}
 					taskOutput = fname + ">task1"
-					return taskOutput, nil
+					return NewTaskResult(TaskSuccess, taskOutput), nil
 
 				}, logger)
-				task2 := NewTask("task2", func(log *log.Logger, ctx context.Context) (string, error) {
-					prevOut, ok := ctx.Value(prevTaskOutputKey).(string)
-					if !ok {
-						return "", taskCanceledErr
+				task2 := NewTask("task2", func(log *log.Logger, ctx context.Context) (*TaskResult, error) {
+					prevOut, ok := ctx.Value(prevTaskOutputKey).(*TaskResult)
+					if !ok || !prevOut.Success() {
+						return nil, taskCanceledErr
 					}
-					taskOutput = prevOut + ">task2"
-					return taskOutput, nil
+					taskOutput = prevOut.Summary + ">task2"
+					return NewTaskResult(TaskSuccess, taskOutput), nil
 
 				}, logger)
-				watcher, _ = NewWatcher(testDir, []Task{task1, task2}, 0, nil, nil, logger)
+				watcher, _ = NewWatcher(testDir, SequentialPipeline(task1, task2), 0, nil, nil, logger)
 				_ = watcher.addDirs()
 				// run tasks
 				go watcher.runTasks()
@@ -239,10 +250,10 @@ func TestWatcherRunTasks(t *testing.T) {
 		{
 			desc: "Do not trigger task on none go files",
 			setup: func() error {
-				gotask := NewTask("gotask", func(log *log.Logger, ctx context.Context) (string, error) {
-					return "should not run", errors.New("should not run")
+				gotask := NewTask("gotask", func(log *log.Logger, ctx context.Context) (*TaskResult, error) {
+					return nil, errors.New("should not run")
 				}, logger)
-				watcher, _ = NewWatcher(testDir, []Task{gotask}, 0, nil, nil, logger)
+				watcher, _ = NewWatcher(testDir, SequentialPipeline(gotask), 0, nil, nil, logger)
 				_ = watcher.addDirs()
 				// run tasks
 				go watcher.runTasks()
@@ -258,17 +269,17 @@ func TestWatcherRunTasks(t *testing.T) {
 		{
 			desc: "Add new directory to a watch list",
 			setup: func() error {
-				task := NewTask("task", func(log *log.Logger, ctx context.Context) (string, error) {
+				task := NewTask("task", func(log *log.Logger, ctx context.Context) (*TaskResult, error) {
 					fname := ctx.Value(changedFileNameKey).(string)
 
 					_, file := filepath.Split(fname)
 					if file != "file_in_new_dir.go" {
-						return "unexpected file", taskCanceledErr
+						return nil, taskCanceledErr
 					}
 					taskOutput = "OK"
-					return taskOutput, nil
+					return NewTaskResult(TaskSuccess, taskOutput), nil
 				}, logger)
-				watcher, _ = NewWatcher(testDir, []Task{task}, 0, nil, nil, logger)
+				watcher, _ = NewWatcher(testDir, SequentialPipeline(task), 0, nil, nil, logger)
 				_ = watcher.addDirs()
 				// run tasks
 				go watcher.runTasks()
@@ -290,11 +301,11 @@ func TestWatcherRunTasks(t *testing.T) {
 		{
 			desc: "Remove deleted directory from a watch list",
 			setup: func() error {
-				task := NewTask("task_return_dirs", func(log *log.Logger, ctx context.Context) (string, error) {
+				task := NewTask("task_return_dirs", func(log *log.Logger, ctx context.Context) (*TaskResult, error) {
 					taskOutput = strconv.Itoa(len(watcher.dirs))
-					return taskOutput, nil
+					return NewTaskResult(TaskSuccess, taskOutput), nil
 				}, logger)
-				watcher, _ = NewWatcher(testDir, []Task{task}, 0, nil, nil, logger)
+				watcher, _ = NewWatcher(testDir, SequentialPipeline(task), 0, nil, nil, logger)
 				_ = watcher.addDirs()
 				// run tasks
 				go watcher.runTasks()